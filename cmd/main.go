@@ -1,25 +1,53 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"order-service/config"
 	infrastructure "order-service/infrastructure/log"
+	"order-service/infrastructure/tracing"
 	"order-service/internal/api"
+	"order-service/internal/auth"
+	"order-service/internal/consumer"
+	"order-service/internal/expiry"
+	"order-service/internal/outbox"
 	"order-service/internal/repository"
 	"order-service/internal/resource"
 	"order-service/internal/service"
+	"order-service/internal/sharding"
+	"order-service/internal/webhook"
 	reqMiddleware "order-service/middleware"
 	"order-service/msgBroker"
 	"order-service/routes"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"google.golang.org/grpc"
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second
+	defaultConsumerStop    = 10 * time.Second
+	defaultExpiryStop      = 10 * time.Second
+	defaultOutboxFlush     = 15 * time.Second
+	defaultCloseTimeout    = 5 * time.Second
+	defaultBodyLimit       = "1M"
+	signingMethodRS256     = "RS256"
 )
 
 func main() {
 
-	infrastructure.InitLogger()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	appConfig := config.LoadConfig(
 		config.WithConfigFolder([]string{"./files/config"}),
@@ -27,26 +55,330 @@ func main() {
 		config.WithConfigType("yaml"),
 	)
 
-	db := resource.InitDB(appConfig)
-	kafkaWriter := msgBroker.NewKafkaWriter(appConfig.Kafka.Brokers, appConfig.Kafka.Topic)
+	// Level and format can be overridden per environment, e.g. ORDER_APP_LOGLEVEL=warn ORDER_APP_LOGFORMAT=json.
+	infrastructure.InitLogger(appConfig.App)
+
+	shutdownTracer, err := tracing.InitTracer(ctx, appConfig.Tracing)
+	if err != nil {
+		infrastructure.Logger.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+
+	shards := resource.InitShardDBs(appConfig)
+	err = resource.Migrate(appConfig, shards)
+	if err != nil {
+		infrastructure.Logger.Fatal().Err(err).Msg("Failed to migrate database")
+	}
+	replicas := resource.InitShardReplicaDBs(appConfig)
+	rdb := resource.InitRedis(appConfig)
+	err = resource.Retry(appConfig.Startup, "kafka", func() error {
+		return msgBroker.Ping(ctx, appConfig.Kafka)
+	})
+	if err != nil {
+		infrastructure.Logger.Fatal().Err(err).Msg("Failed to connect to Kafka")
+	}
+	kafkaWriter, err := msgBroker.NewKafkaWriter(appConfig.Kafka, appConfig.Kafka.Topic)
+	if err != nil {
+		infrastructure.Logger.Fatal().Err(err).Msg("Failed to create Kafka writer")
+	}
 
-	orderRepo := repository.NewOrderRepository(db)
+	shardRouter := sharding.NewShardRouter(len(shards)).WithNodeID(appConfig.App.NodeID)
+	orderRepo := repository.NewShardedOrderRepositoryRW(shards, replicas, shardRouter)
+	cacheRepo := repository.NewCacheRepository(rdb)
+	// Outbox events are written in the order's transaction, so they live on the order's shard.
+	// The service only inserts through the transaction, so any shard's repository can serve it.
+	outboxRepos := make([]repository.OutboxRepository, len(shards))
+	for i, shard := range shards {
+		outboxRepos[i] = repository.NewOutboxRepository(shard)
+	}
+	var productClient service.ProductClient
+	var pricingClient service.PricingClient
+	var grpcConns []*grpc.ClientConn
+	if appConfig.Services.Protocol == service.ProtocolGRPC {
+		productConn, err := service.NewGRPCConn(appConfig.Services.ProductGRPC)
+		if err != nil {
+			infrastructure.Logger.Fatal().Err(err).Msg("Failed to connect to the product service")
+		}
+		pricingConn, err := service.NewGRPCConn(appConfig.Services.PricingGRPC)
+		if err != nil {
+			infrastructure.Logger.Fatal().Err(err).Msg("Failed to connect to the pricing service")
+		}
+		grpcConns = append(grpcConns, productConn, pricingConn)
+		productClient = service.NewGRPCProductClient(productConn, appConfig.Services)
+		pricingClient = service.NewGRPCPricingClient(pricingConn, appConfig.Services)
+	} else {
+		// The product and pricing clients share one connection pool.
+		downstreamClient := service.NewHTTPClient(appConfig.Services)
+		productClient = service.NewHTTPProductClient(downstreamClient, appConfig.Services)
+		pricingClient = service.NewHTTPPricingClient(downstreamClient, appConfig.Services)
+	}
 	orderService := service.NewOrderService(
 		orderRepo,
-		appConfig.Services.Product,
-		appConfig.Services.Pricing,
-		kafkaWriter,
+		cacheRepo,
+		outboxRepos[0],
+		productClient,
+		pricingClient,
+		appConfig.Services,
+		appConfig.Expiry.TTL,
+		appConfig.Kafka.PartitionKey,
 	)
 
-	orderHandler := api.NewOrderHandler(orderService)
+	// Background workers get their own contexts so they keep running while in-flight
+	// requests drain, and are stopped one group at a time once the HTTP server has shut down.
+	outboxCtx, stopOutbox := context.WithCancel(context.Background())
+	defer stopOutbox()
+	var outboxWorkers sync.WaitGroup
+
+	outboxPublishers := make([]*outbox.Publisher, len(outboxRepos))
+	for i, outboxRepo := range outboxRepos {
+		outboxPublisher := outbox.NewPublisher(
+			outboxRepo,
+			kafkaWriter,
+			appConfig.Outbox.PollInterval,
+			appConfig.Outbox.BatchSize,
+			appConfig.Outbox.MaxAttempts,
+			appConfig.Outbox.Workers,
+		)
+		outboxPublishers[i] = outboxPublisher
+
+		// Webhook deliveries are queued on the same shard as the events they carry.
+		if len(appConfig.Webhooks.Subscribers) > 0 {
+			dispatcher := webhook.NewDispatcher(repository.NewWebhookRepository(shards[i]), appConfig.Webhooks)
+			outboxPublisher.WithWebhooks(dispatcher)
+			outboxWorkers.Add(1)
+			go func() {
+				defer outboxWorkers.Done()
+				dispatcher.Start(outboxCtx)
+			}()
+		}
+		outboxWorkers.Add(1)
+		go func() {
+			defer outboxWorkers.Done()
+			outboxPublisher.Start(outboxCtx)
+		}()
+	}
+
+	consumerTopics := []string{appConfig.Kafka.ConsumerTopic}
+	if appConfig.Kafka.PricingTopic != "" {
+		consumerTopics = append(consumerTopics, appConfig.Kafka.PricingTopic)
+	}
+	kafkaReader, err := msgBroker.NewKafkaReader(appConfig.Kafka, consumerTopics)
+	if err != nil {
+		infrastructure.Logger.Fatal().Err(err).Msg("Failed to create Kafka reader")
+	}
+	var deadLetterWriter *kafka.Writer
+	if appConfig.Kafka.DeadLetterTopic != "" {
+		deadLetterWriter, err = msgBroker.NewKafkaWriter(appConfig.Kafka, appConfig.Kafka.DeadLetterTopic)
+		if err != nil {
+			infrastructure.Logger.Fatal().Err(err).Msg("Failed to create Kafka dead-letter writer")
+		}
+	}
+	orderConsumer := consumer.NewConsumer(
+		kafkaReader,
+		orderService,
+		deadLetterWriter,
+		cacheRepo,
+		appConfig.Consumer.MaxRetries,
+		appConfig.Consumer.RetryBackoff,
+		appConfig.Consumer.DedupTTL,
+	)
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	defer stopConsumer()
+	var consumerWorker sync.WaitGroup
+	consumerWorker.Add(1)
+	go func() {
+		defer consumerWorker.Done()
+		orderConsumer.Start(consumerCtx)
+	}()
+
+	expiryWorker := expiry.NewWorker(
+		orderService,
+		appConfig.Expiry.Interval,
+		appConfig.Expiry.TTL,
+		appConfig.Expiry.BatchSize,
+	)
+	expiryCtx, stopExpiry := context.WithCancel(context.Background())
+	defer stopExpiry()
+	var expiryRunner sync.WaitGroup
+	expiryRunner.Add(1)
+	go func() {
+		defer expiryRunner.Done()
+		expiryWorker.Start(expiryCtx)
+	}()
+
+	orderHandler := api.NewOrderHandler(orderService, appConfig.App.MaxBatchSize)
+	healthHandler := api.NewHealthHandler(shards, rdb, appConfig.Kafka.Brokers)
+	outboxHandler := api.NewOutboxHandler(outboxPublishers, appConfig.Outbox.RedriveMaxAttempts)
+
+	bodyLimit := appConfig.App.BodyLimit
+	if bodyLimit == "" {
+		bodyLimit = defaultBodyLimit
+	}
 
 	e := echo.New()
-	e.Use(middleware.Logger())
+	e.Use(otelecho.Middleware(appConfig.Tracing.ServiceName))
+	e.Use(reqMiddleware.RequestID())
+	e.Use(reqMiddleware.RequestLogger())
 	e.Use(middleware.Recover())
-	e.Use(middleware.RateLimiterWithConfig(reqMiddleware.GetRateLimiter()))
-	e.Use(middleware.ContextTimeout(15 * time.Second))
-	e.Use(echojwt.JWT(appConfig.Secret.JWTSecret))
+	e.Use(middleware.CORSWithConfig(reqMiddleware.GetCORSConfig(appConfig.App.CORS)))
+	e.Use(middleware.BodyLimit(bodyLimit))
+	e.Use(reqMiddleware.RequestTimeout(appConfig.App.Timeouts))
+
+	jwtMiddleware := echojwt.WithConfig(jwtConfig(appConfig.Secret))
+	rateLimiters := reqMiddleware.NewRateLimiters(appConfig.App.RateLimits)
+	routes.SetupRoutes(e, jwtMiddleware, rateLimiters, orderHandler, healthHandler, outboxHandler)
+
+	go func() {
+		err := e.Start(":" + appConfig.App.Port)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Error(err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	infrastructure.Logger.Info().Msg("Shutting down")
+
+	// Each stage stops what feeds the next one: requests and consumed events write outbox events,
+	// which are flushed before the connections they are published over are closed.
+	stages := appConfig.App.ShutdownStages
+	shutdownStage("http", durationOrDefault(appConfig.App.ShutdownTimeout, defaultShutdownTimeout), func(ctx context.Context) error {
+		// Shutdown closes the listener first, then waits for in-flight handlers.
+		err := e.Shutdown(ctx)
+		if err != nil {
+			_ = e.Close()
+			return err
+		}
+		return nil
+	})
+	shutdownStage("consumer", durationOrDefault(stages.Consumer, defaultConsumerStop), func(ctx context.Context) error {
+		stopConsumer()
+		return waitGroup(ctx, &consumerWorker)
+	})
+	shutdownStage("expiry", durationOrDefault(stages.Expiry, defaultExpiryStop), func(ctx context.Context) error {
+		stopExpiry()
+		return waitGroup(ctx, &expiryRunner)
+	})
+	shutdownStage("outbox", durationOrDefault(stages.Outbox, defaultOutboxFlush), func(ctx context.Context) error {
+		stopOutbox()
+		err := waitGroup(ctx, &outboxWorkers)
+		if err != nil {
+			return err
+		}
+		for _, outboxPublisher := range outboxPublishers {
+			outboxPublisher.Flush(ctx)
+		}
+		return ctx.Err()
+	})
+	shutdownStage("close", durationOrDefault(stages.Close, defaultCloseTimeout), func(ctx context.Context) error {
+		// Closing the reader also commits any offsets it still holds and leaves the consumer group.
+		err := kafkaReader.Close()
+		if err != nil {
+			infrastructure.Logger.Error().Err(err).Msg("Failed to close Kafka reader")
+		}
+		err = kafkaWriter.Close()
+		if err != nil {
+			infrastructure.Logger.Error().Err(err).Msg("Failed to close Kafka writer")
+		}
+		if deadLetterWriter != nil {
+			err = deadLetterWriter.Close()
+			if err != nil {
+				infrastructure.Logger.Error().Err(err).Msg("Failed to close Kafka dead-letter writer")
+			}
+		}
+		err = rdb.Close()
+		if err != nil {
+			infrastructure.Logger.Error().Err(err).Msg("Failed to close Redis client")
+		}
+		for _, conn := range grpcConns {
+			err = conn.Close()
+			if err != nil {
+				infrastructure.Logger.Error().Err(err).Str("target", conn.Target()).Msg("Failed to close gRPC connection")
+			}
+		}
+		for i, shard := range shards {
+			err = resource.CloseDB(shard)
+			if err != nil {
+				infrastructure.Logger.Error().Err(err).Int("shard", i).Msg("Failed to close database connection")
+			}
+		}
+		for i, replica := range replicas {
+			err = resource.CloseDB(replica)
+			if err != nil {
+				infrastructure.Logger.Error().Err(err).Int("shard", i).Msg("Failed to close replica database connection")
+			}
+		}
+		err = shutdownTracer(ctx)
+		if err != nil {
+			infrastructure.Logger.Error().Err(err).Msg("Failed to flush traces")
+		}
+		return nil
+	})
+
+	infrastructure.Logger.Info().Msg("Shutdown complete")
+}
+
+// jwtConfig configures the JWT middleware to verify tokens with the shared HMAC secret or, for
+// RS256, with the identity provider's JWKS.
+func jwtConfig(cfg config.SecreteConfig) echojwt.Config {
+	if cfg.SigningMethod == signingMethodRS256 {
+		jwks := auth.NewJWKS(cfg.JWKSURL, cfg.JWKSRefreshInterval)
+		return echojwt.Config{
+			KeyFunc: jwks.Keyfunc,
+		}
+	}
+
+	return echojwt.Config{
+		SigningKey: []byte(cfg.JWTSecret),
+	}
+}
+
+// shutdownStage runs one stage of the shutdown sequence bounded by timeout, logging when it starts
+// and how it ends. A stage that runs over is abandoned so the stages after it still run.
+func shutdownStage(name string, timeout time.Duration, stage func(ctx context.Context) error) {
+	logger := infrastructure.Logger.With().Str("stage", name).Logger()
+	logger.Info().Dur("timeout", timeout).Msg("Shutdown stage started")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- stage(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			logger.Error().Err(err).Dur("elapsed", time.Since(start)).Msg("Shutdown stage failed")
+			return
+		}
+		logger.Info().Dur("elapsed", time.Since(start)).Msg("Shutdown stage completed")
+	case <-ctx.Done():
+		logger.Warn().Dur("elapsed", time.Since(start)).Msg("Shutdown stage timed out, moving on")
+	}
+}
+
+// waitGroup waits for wg, giving up with the context's error once ctx is done.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	routes.SetupRoutes(e, orderHandler)
-	e.Logger.Fatal(e.Start(":" + appConfig.App.Port))
+// durationOrDefault returns d, or fallback when d is not set.
+func durationOrDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
 }
@@ -1,11 +1,20 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+)
 
 type option struct {
 	ConfigFolder []string
 	ConfigType   string
 	ConfigFile   string
+	EnvPrefix    string
 }
 
 type Option func(*option)
@@ -15,6 +24,7 @@ func LoadConfig(opts ...Option) Config {
 		ConfigFolder: getDefaultConfigFolder(),
 		ConfigFile:   getDefaultConfigFile(),
 		ConfigType:   getDefaultConfigType(),
+		EnvPrefix:    getDefaultEnvPrefix(),
 	}
 
 	for _, optFunc := range opts {
@@ -27,7 +37,14 @@ func LoadConfig(opts ...Option) Config {
 
 	viper.SetConfigName(opt.ConfigFile)
 	viper.SetConfigType(opt.ConfigType)
+	// Environment variables take precedence over the file. Nested keys are read with the prefix
+	// and dots replaced, e.g. ORDER_DB_PASSWORD for db.password.
+	viper.SetEnvPrefix(opt.EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
+	// AutomaticEnv only covers keys viper already knows from the file, so every key is bound
+	// explicitly and secrets such as db.password may be left out of the file entirely.
+	bindEnvKeys(reflect.TypeOf(Config{}), "")
 
 	err := viper.ReadInConfig()
 	if err != nil {
@@ -40,9 +57,63 @@ func LoadConfig(opts ...Option) Config {
 		panic(err)
 	}
 
+	err = validateConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+
 	return cfg
 }
 
+// bindEnvKeys binds the config key of every field of t, a struct type read through its mapstructure
+// tags, to its environment variable. Nested structs are bound field by field under prefix.
+func bindEnvKeys(t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+		if field.Type.Kind() == reflect.Struct {
+			bindEnvKeys(field.Type, key+".")
+			continue
+		}
+		_ = viper.BindEnv(key)
+	}
+}
+
+// validateConfig checks cfg against its validate tags. Failing fields are reported by their
+// config key, e.g. kafka.topic, so a missing setting is clear at startup.
+func validateConfig(cfg Config) error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+
+	err := validate.Struct(cfg)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return fmt.Errorf("failed to validate config: %w", err)
+	}
+
+	keys := make([]string, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		// Namespace is prefixed with the root struct name, e.g. Config.kafka.topic.
+		key := fieldErr.Namespace()
+		if i := strings.Index(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+		keys = append(keys, fmt.Sprintf("%s (%s)", key, fieldErr.Tag()))
+	}
+	return fmt.Errorf("invalid config, failed validation: %s", strings.Join(keys, ", "))
+}
+
 func getDefaultConfigFolder() []string {
 	return []string{"./files/config"}
 }
@@ -55,6 +126,10 @@ func getDefaultConfigType() string {
 	return "yaml"
 }
 
+func getDefaultEnvPrefix() string {
+	return "ORDER"
+}
+
 func WithConfigFolder(folder []string) Option {
 	return func(o *option) {
 		o.ConfigFolder = folder
@@ -72,3 +147,11 @@ func WithConfigType(configType string) Option {
 		o.ConfigType = configType
 	}
 }
+
+// WithEnvPrefix sets the prefix of the environment variables that override file values.
+// An empty prefix reads unprefixed variables such as DB_PASSWORD.
+func WithEnvPrefix(prefix string) Option {
+	return func(o *option) {
+		o.EnvPrefix = prefix
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// testConfig is the smallest config that passes validation, with db.password given as fmt verb
+// so tests can leave it out.
+const testConfig = `
+app:
+  port: "8080"
+db:
+  host: localhost
+  port: "3306"
+  user: order
+%s
+  name: orders
+  nameS1: orders-s1
+  nameS2: orders-s2
+redis:
+  host: localhost
+  port: "6379"
+secret:
+  jwtSecret: file-secret
+services:
+  product: http://product
+  pricing: http://pricing
+kafka:
+  brokers: ["localhost:9092"]
+  topic: %s
+  consumerTopic: inventory-topic
+  consumerGroup: order-service
+`
+
+// writeConfig writes a config file to a temporary folder and returns the option loading it.
+// LoadConfig configures the global viper instance, so it is reset once the test is done.
+func writeConfig(t *testing.T, password, topic string) Option {
+	t.Helper()
+	t.Cleanup(viper.Reset)
+
+	passwordLine := ""
+	if password != "" {
+		passwordLine = "  password: " + password
+	}
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(fmt.Sprintf(testConfig, passwordLine, topic)), 0o600)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return WithConfigFolder([]string{dir})
+}
+
+func TestLoadConfigReadsFile(t *testing.T) {
+	cfg := LoadConfig(writeConfig(t, "file-password", "order-topic"))
+
+	if cfg.DB.Password != "file-password" {
+		t.Errorf("db.password = %q, want file-password", cfg.DB.Password)
+	}
+	if cfg.Kafka.Topic != "order-topic" {
+		t.Errorf("kafka.topic = %q, want order-topic", cfg.Kafka.Topic)
+	}
+}
+
+func TestLoadConfigEnvOverridesFile(t *testing.T) {
+	option := writeConfig(t, "file-password", "order-topic")
+	t.Setenv("ORDER_DB_PASSWORD", "env-password")
+	t.Setenv("ORDER_SECRET_JWTSECRET", "env-secret")
+
+	cfg := LoadConfig(option)
+
+	if cfg.DB.Password != "env-password" {
+		t.Errorf("db.password = %q, want env-password", cfg.DB.Password)
+	}
+	if cfg.Secret.JWTSecret != "env-secret" {
+		t.Errorf("secret.jwtSecret = %q, want env-secret", cfg.Secret.JWTSecret)
+	}
+	if cfg.Kafka.Topic != "order-topic" {
+		t.Errorf("kafka.topic = %q, want the file value order-topic", cfg.Kafka.Topic)
+	}
+}
+
+func TestLoadConfigEnvSuppliesKeyMissingFromFile(t *testing.T) {
+	option := writeConfig(t, "", "order-topic")
+	t.Setenv("ORDER_DB_PASSWORD", "env-password")
+
+	cfg := LoadConfig(option)
+
+	if cfg.DB.Password != "env-password" {
+		t.Errorf("db.password = %q, want env-password", cfg.DB.Password)
+	}
+}
+
+func TestLoadConfigWithEnvPrefix(t *testing.T) {
+	option := writeConfig(t, "file-password", "order-topic")
+	t.Setenv("ORDER_DB_PASSWORD", "ignored")
+	t.Setenv("CUSTOM_DB_PASSWORD", "custom-password")
+
+	cfg := LoadConfig(option, WithEnvPrefix("CUSTOM"))
+
+	if cfg.DB.Password != "custom-password" {
+		t.Errorf("db.password = %q, want custom-password", cfg.DB.Password)
+	}
+}
+
+func TestLoadConfigRejectsMissingRequiredField(t *testing.T) {
+	option := writeConfig(t, "", `""`)
+
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			t.Fatal("LoadConfig did not panic on a config missing required fields")
+		}
+		message := fmt.Sprint(recovered)
+		for _, key := range []string{"db.password (required)", "kafka.topic (required)"} {
+			if !strings.Contains(message, key) {
+				t.Errorf("panic %q does not name %s", message, key)
+			}
+		}
+	}()
+	LoadConfig(option)
+}
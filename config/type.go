@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Config struct {
 	App      App           `mapstructure:"app" validate:"required"`
 	DB       DB            `mapstructure:"db" validate:"required"`
@@ -7,10 +9,74 @@ type Config struct {
 	Secret   SecreteConfig `mapstructure:"secret" validate:"required"`
 	Services Services      `mapstructure:"services" validate:"required"`
 	Kafka    Kafka         `mapstructure:"kafka" validate:"required"`
+	Outbox   Outbox        `mapstructure:"outbox"`
+	Expiry   Expiry        `mapstructure:"expiry"`
+	Tracing  Tracing       `mapstructure:"tracing"`
+	Consumer Consumer      `mapstructure:"consumer"`
+	Webhooks Webhooks      `mapstructure:"webhooks"`
+	Startup  Startup       `mapstructure:"startup"`
+}
+
+// Startup bounds how long the service waits for the database and Kafka to accept connections while
+// starting, so dependencies that come up later in a deploy do not make it crash-loop.
+type Startup struct {
+	MaxAttempts int           `mapstructure:"maxAttempts"` // Connection attempts per dependency before startup fails, defaults to 5
+	RetryDelay  time.Duration `mapstructure:"retryDelay"`  // Delay after the first failed attempt, doubled after each further one, defaults to 2s
+	MaxDelay    time.Duration `mapstructure:"maxDelay"`    // Longest delay between attempts, defaults to 30s
 }
 
 type App struct {
-	Port string `mapstructure:"port" validate:"required"`
+	Port            string         `mapstructure:"port" validate:"required"`
+	NodeID          int64          `mapstructure:"nodeId"`                                                          // Unique per running instance (0-1023), used to generate order IDs
+	ShutdownTimeout time.Duration  `mapstructure:"shutdownTimeout"`                                                 // Grace period for in-flight requests on shutdown, defaults to 30s
+	MaxBatchSize    int            `mapstructure:"maxBatchSize"`                                                    // Orders accepted per batch create request, defaults to 100
+	BodyLimit       string         `mapstructure:"bodyLimit"`                                                       // Maximum request body size, e.g. 1M, larger requests are rejected with 413, defaults to 1M
+	LogLevel        string         `mapstructure:"logLevel" validate:"omitempty,oneof=trace debug info warn error"` // Minimum level logged, defaults to info
+	LogFormat       string         `mapstructure:"logFormat" validate:"omitempty,oneof=json console"`               // json for production, console for human-readable output, defaults to console
+	CORS            CORS           `mapstructure:"cors"`
+	Timeouts        Timeouts       `mapstructure:"timeouts"`
+	ShutdownStages  ShutdownStages `mapstructure:"shutdownStages"`
+	RateLimits      RateLimits     `mapstructure:"rateLimits"`
+}
+
+// RateLimits configures the per-client rate limits of the route groups: write, read, admin and ops.
+// A group missing from Groups, or a field left unset, is limited like Default.
+type RateLimits struct {
+	Default RateLimit            `mapstructure:"default"` // Limit of groups without their own, defaults to 1 request per second with a burst of 5
+	Groups  map[string]RateLimit `mapstructure:"groups"`  // Limit per route group keyed by its name, e.g. write
+}
+
+// RateLimit is the token bucket each client IP gets for a route group.
+type RateLimit struct {
+	Rate      float64       `mapstructure:"rate"`      // Requests per second a client may sustain
+	Burst     int           `mapstructure:"burst"`     // Requests a client may send at once above the rate
+	ExpiresIn time.Duration `mapstructure:"expiresIn"` // How long an idle client's bucket is kept, defaults to 1m
+}
+
+// ShutdownStages bounds each stage of the shutdown sequence that follows the HTTP drain. A stage
+// that runs over is abandoned and shutdown moves on to the next one.
+type ShutdownStages struct {
+	Consumer time.Duration `mapstructure:"consumer"` // Wait for the in-flight message to be handled and its offset committed, defaults to 10s
+	Expiry   time.Duration `mapstructure:"expiry"`   // Wait for the running expiry batch to finish, defaults to 10s
+	Outbox   time.Duration `mapstructure:"outbox"`   // Last publish of the pending outbox events and stop of the webhook dispatchers, defaults to 15s
+	Close    time.Duration `mapstructure:"close"`    // Closing Kafka, Redis, gRPC and database connections and flushing traces, defaults to 5s
+}
+
+// Timeouts bounds how long a request may run. A client may ask for a different deadline with the
+// X-Timeout-Ms header, which is still clamped to Max.
+type Timeouts struct {
+	Default time.Duration            `mapstructure:"default"` // Deadline of requests without a route timeout, defaults to 15s
+	Max     time.Duration            `mapstructure:"max"`     // Longest deadline a route or header may set, defaults to 60s
+	Routes  map[string]time.Duration `mapstructure:"routes"`  // Deadline per route keyed by method and path, e.g. "POST /orders/batch": 60s
+}
+
+// CORS configures which browser origins may call the API. List values may also be
+// given as a comma-separated string, e.g. ORDER_APP_CORS_ALLOWORIGINS=https://a.com,https://b.com.
+type CORS struct {
+	AllowOrigins     []string `mapstructure:"allowOrigins"`     // Origins allowed to call the API, cross-origin requests are denied when empty
+	AllowMethods     []string `mapstructure:"allowMethods"`     // Methods allowed on cross-origin requests, defaults to GET, POST, PUT, PATCH and DELETE
+	AllowHeaders     []string `mapstructure:"allowHeaders"`     // Request headers allowed on cross-origin requests, defaults to the headers the API reads
+	AllowCredentials bool     `mapstructure:"allowCredentials"` // Whether browsers may send credentials such as cookies
 }
 
 type DB struct {
@@ -21,10 +87,27 @@ type DB struct {
 	Name     string `mapstructure:"name" validate:"required"`
 	NameS1   string `mapstructure:"nameS1" validate:"required"` // For sharding, e.g., db_name-s1
 	NameS2   string `mapstructure:"nameS2" validate:"required"` // For sharding, e.g., db_name-s2
+
+	ReplicaHost string `mapstructure:"replicaHost"` // Read replica host serving the same databases, reads use the primary when empty
+	ReplicaPort string `mapstructure:"replicaPort"` // Read replica port, defaults to Port
+
+	MaxOpenConns    int           `mapstructure:"maxOpenConns"`    // Open connections per database, defaults to 100
+	MaxIdleConns    int           `mapstructure:"maxIdleConns"`    // Idle connections kept per database, defaults to 10
+	ConnMaxLifetime time.Duration `mapstructure:"connMaxLifetime"` // Maximum age of a connection before it is recycled, defaults to 30m
+
+	StatementTimeout   time.Duration `mapstructure:"statementTimeout"`   // Longest a SELECT may run before MySQL aborts it, defaults to 10s
+	SlowQueryThreshold time.Duration `mapstructure:"slowQueryThreshold"` // Queries running longer are logged as slow with their SQL, defaults to 200ms
+
+	Migrations string `mapstructure:"migrations" validate:"omitempty,oneof=up auto"` // Schema migration run at startup: up applies versioned migrations, auto migrates from the entities for development, empty runs none
 }
 
+// SecreteConfig configures how request JWTs are verified: with the shared HMAC secret, or for
+// RS256 with the keys an identity provider publishes as a JWKS.
 type SecreteConfig struct {
-	JWTSecret string `mapstructure:"jwtSecret" validate:"required"`
+	JWTSecret           string        `mapstructure:"jwtSecret" validate:"required_unless=SigningMethod RS256"`         // Shared secret of HS256 tokens
+	SigningMethod       string        `mapstructure:"signingMethod" validate:"omitempty,oneof=HS256 RS256"`             // HS256 or RS256, defaults to HS256
+	JWKSURL             string        `mapstructure:"jwksUrl" validate:"required_if=SigningMethod RS256,omitempty,url"` // Where the identity provider publishes its RS256 keys
+	JWKSRefreshInterval time.Duration `mapstructure:"jwksRefreshInterval"`                                              // How long fetched keys are used before the JWKS is refetched, defaults to 1h
 }
 
 type Redis struct {
@@ -34,11 +117,108 @@ type Redis struct {
 }
 
 type Services struct {
-	Product string `mapstructure:"product" validate:"required"`
-	Pricing string `mapstructure:"pricing" validate:"required"`
+	Protocol            string          `mapstructure:"protocol" validate:"omitempty,oneof=http grpc"`    // Transport used to call the product and pricing services: http (default) or grpc
+	Product             string          `mapstructure:"product" validate:"required_unless=Protocol grpc"` // Base URL of the product service over HTTP
+	Pricing             string          `mapstructure:"pricing" validate:"required_unless=Protocol grpc"` // Base URL of the pricing service over HTTP
+	ProductGRPC         string          `mapstructure:"productGrpc" validate:"required_if=Protocol grpc"` // host:port of the product service over gRPC
+	PricingGRPC         string          `mapstructure:"pricingGrpc" validate:"required_if=Protocol grpc"` // host:port of the pricing service over gRPC
+	Timeout             time.Duration   `mapstructure:"timeout"`                                          // Per-request timeout for downstream calls, defaults to 5s
+	MaxIdleConnsPerHost int             `mapstructure:"maxIdleConnsPerHost"`                              // Idle connections kept per downstream host, defaults to 100
+	IdleConnTimeout     time.Duration   `mapstructure:"idleConnTimeout"`                                  // How long idle connections are kept, defaults to 90s
+	MaxRetries          int             `mapstructure:"maxRetries"`                                       // Retries on 5xx and network errors, 0 disables retrying
+	BaseDelay           time.Duration   `mapstructure:"baseDelay"`                                        // Initial retry backoff, doubled on each attempt, defaults to 100ms
+	BaseCurrency        string          `mapstructure:"baseCurrency" validate:"omitempty,len=3"`          // ISO 4217 currency of prices returned without one, defaults to USD
+	PricingCacheTTL     time.Duration   `mapstructure:"pricingCacheTTL"`                                  // How long product pricing is cached in Redis, defaults to 5s
+	StockLockTTL        time.Duration   `mapstructure:"stockLockTTL"`                                     // Expiry of the per-product lock held while reserving stock, defaults to 5s
+	MaxLineItems        int             `mapstructure:"maxLineItems"`                                     // Product lines accepted in one order, larger orders are rejected with 400, defaults to 100
+	MaxConcurrency      int             `mapstructure:"maxConcurrency"`                                   // Concurrent stock and pricing calls made for one order, defaults to 10
+	CircuitBreaker      CircuitBreaker  `mapstructure:"circuitBreaker"`
+	PricingFallback     PricingFallback `mapstructure:"pricingFallback"`
+	PurchaseLimits      PurchaseLimits  `mapstructure:"purchaseLimits"`
+}
+
+// PurchaseLimits caps how many units of a product one user may order across their orders, so a
+// flash-sale item cannot be bought out by a single buyer. Cancelled and expired orders do not count.
+type PurchaseLimits struct {
+	Default  int64           `mapstructure:"default"`  // Units of any product one user may order, 0 for no limit
+	Products map[int64]int64 `mapstructure:"products"` // Limit by product ID, overriding Default, e.g. 42: 2
+}
+
+// PricingFallback configures pricing orders at the last known price while the pricing service is
+// unavailable, instead of failing them. Orders priced this way are flagged with pricing_estimated.
+type PricingFallback struct {
+	Enabled          bool          `mapstructure:"enabled"`          // Use the last known price when the pricing service is down, disabled by default
+	LastKnownTTL     time.Duration `mapstructure:"lastKnownTTL"`     // How long a fetched price may serve as the fallback, defaults to 24h
+	CriticalProducts []int64       `mapstructure:"criticalProducts"` // Products that are never priced from the fallback and fail instead
+}
+
+// CircuitBreaker configures the breakers wrapping each downstream service.
+type CircuitBreaker struct {
+	FailureThreshold float64       `mapstructure:"failureThreshold"` // Failure ratio that opens the breaker, defaults to 0.5
+	MinRequests      int           `mapstructure:"minRequests"`      // Requests needed in the window before tripping, defaults to 20
+	Window           time.Duration `mapstructure:"window"`           // Rolling window length, defaults to 10s
+	Cooldown         time.Duration `mapstructure:"cooldown"`         // Time spent open before a trial call, defaults to 30s
 }
 
 type Kafka struct {
-	Brokers []string `mapstructure:"brokers" validate:"required"`
-	Topic   string   `mapstructure:"topic" validate:"required"`
+	Brokers         []string `mapstructure:"brokers" validate:"required"`
+	Topic           string   `mapstructure:"topic" validate:"required"`
+	ConsumerTopic   string   `mapstructure:"consumerTopic" validate:"required"` // Topic of inventory events consumed by the service
+	ConsumerGroup   string   `mapstructure:"consumerGroup" validate:"required"`
+	PricingTopic    string   `mapstructure:"pricingTopic"`                                             // Topic of pricing events used to invalidate cached pricing, optional
+	DeadLetterTopic string   `mapstructure:"deadLetterTopic"`                                          // Topic receiving consumed messages that could not be processed, optional
+	PartitionKey    string   `mapstructure:"partitionKey" validate:"omitempty,oneof=order user event"` // Key of published order events: order (default), user or event, events sharing a key stay ordered
+
+	RequiredAcks    string        `mapstructure:"requiredAcks" validate:"omitempty,oneof=all one none"` // Acknowledgments a write waits for: all in-sync replicas (default), the leader only, or none
+	MaxAttempts     int           `mapstructure:"maxAttempts"`                                          // Attempts per write before it fails, defaults to 10
+	RetryBackoffMin time.Duration `mapstructure:"retryBackoffMin"`                                      // Delay before the first retry of a write, defaults to 100ms
+	RetryBackoffMax time.Duration `mapstructure:"retryBackoffMax"`                                      // Longest delay between retries of a write, defaults to 1s
+
+	TLS           bool   `mapstructure:"tls"`                                             // Connect to the brokers over TLS, disable for local development
+	SASLMechanism string `mapstructure:"saslMechanism"`                                   // PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512, connections are unauthenticated when empty
+	Username      string `mapstructure:"username" validate:"required_with=SASLMechanism"` // SASL username
+	Password      string `mapstructure:"password" validate:"required_with=SASLMechanism"` // SASL password
+}
+
+type Outbox struct {
+	PollInterval       time.Duration `mapstructure:"pollInterval"`       // How often the outbox is polled, defaults to 1s
+	BatchSize          int           `mapstructure:"batchSize"`          // Events read per poll, defaults to 100
+	MaxAttempts        int           `mapstructure:"maxAttempts"`        // Publish attempts before an event is marked failed, defaults to 10
+	Workers            int           `mapstructure:"workers"`            // Publisher goroutines per shard, each owning a share of the orders, defaults to 1
+	RedriveMaxAttempts int           `mapstructure:"redriveMaxAttempts"` // Lifetime attempts after which POST /admin/outbox/redrive no longer requeues a failed event, defaults to 50
+}
+
+type Consumer struct {
+	MaxRetries   int           `mapstructure:"maxRetries"`   // Processing retries before a message is dead-lettered, defaults to 3
+	RetryBackoff time.Duration `mapstructure:"retryBackoff"` // Initial delay between retries, doubled on each retry, defaults to 500ms
+	DedupTTL     time.Duration `mapstructure:"dedupTTL"`     // How long processed event IDs are remembered to drop redelivered events, defaults to 24h
+}
+
+// Webhooks configures delivery of order events to HTTP subscribers that cannot consume Kafka.
+type Webhooks struct {
+	PollInterval time.Duration       `mapstructure:"pollInterval"` // How often due deliveries are polled, defaults to 1s
+	BatchSize    int                 `mapstructure:"batchSize"`    // Deliveries attempted per poll, defaults to 100
+	MaxAttempts  int                 `mapstructure:"maxAttempts"`  // Attempts before a delivery is marked failed, defaults to 10
+	BaseDelay    time.Duration       `mapstructure:"baseDelay"`    // Delay before the first retry, doubled on each attempt up to 1h, defaults to 1s
+	Timeout      time.Duration       `mapstructure:"timeout"`      // Per-request timeout, defaults to 5s
+	Subscribers  []WebhookSubscriber `mapstructure:"subscribers" validate:"dive"`
+}
+
+type WebhookSubscriber struct {
+	Name   string   `mapstructure:"name" validate:"required"`    // Unique name, recorded on each delivery
+	URL    string   `mapstructure:"url" validate:"required,url"` // Endpoint events are POSTed to
+	Secret string   `mapstructure:"secret" validate:"required"`  // Key of the HMAC-SHA256 X-Signature header
+	Events []string `mapstructure:"events"`                      // Event types delivered, e.g. order.created, every type when empty
+}
+
+type Expiry struct {
+	Interval  time.Duration `mapstructure:"interval"`  // How often unpaid orders are checked, defaults to 1m
+	TTL       time.Duration `mapstructure:"ttl"`       // How long an order may stay unpaid before it expires, defaults to 15m
+	BatchSize int           `mapstructure:"batchSize"` // Orders expired per batch, defaults to 100
+}
+
+type Tracing struct {
+	Endpoint    string  `mapstructure:"endpoint"`    // OTLP/HTTP collector URL, tracing spans are not exported when empty
+	ServiceName string  `mapstructure:"serviceName"` // Service name attached to spans, defaults to order-service
+	SampleRatio float64 `mapstructure:"sampleRatio"` // Fraction of new traces sampled, defaults to 1
 }
@@ -0,0 +1,38 @@
+package buildinfo
+
+import "net/http"
+
+// ServiceName identifies this service to the services it calls.
+const ServiceName = "order-service"
+
+// CallerServiceHeader names the calling service on outbound requests.
+const CallerServiceHeader = "X-Caller-Service"
+
+// Version is the release of the service. It is injected at build time, e.g.
+//
+//	go build -ldflags "-X order-service/infrastructure/buildinfo.Version=1.4.0" ./cmd
+var Version = "dev"
+
+// UserAgent returns the User-Agent sent on outbound requests, e.g. order-service/1.4.0.
+func UserAgent() string {
+	return ServiceName + "/" + Version
+}
+
+// identityTransport sets the identity headers on every request before passing it to next.
+type identityTransport struct {
+	next http.RoundTripper
+}
+
+// IdentifyRequests wraps next so every request carries the User-Agent and X-Caller-Service headers,
+// letting downstream teams attribute traffic to this service.
+func IdentifyRequests(next http.RoundTripper) http.RoundTripper {
+	return &identityTransport{next: next}
+}
+
+func (t *identityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request, so the headers are set on a copy.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", UserAgent())
+	req.Header.Set(CallerServiceHeader, ServiceName)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// RequestIDHeader carries the request ID on incoming requests, responses and outgoing calls.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// WithRequestID returns a context carrying the request ID together with a logger that
+// adds it to every line, so all logs of one request can be tied together.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	logger := Logger.With().Str("requestID", requestID).Logger()
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return context.WithValue(ctx, loggerKey{}, &logger)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID, or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext returns the request-scoped logger stored by WithRequestID, or the global Logger.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zerolog.Logger); ok {
+		return logger
+	}
+	return Logger
+}
+
+type userIDKey struct{}
+
+type orderIDKey struct{}
+
+// WithUserID returns a context whose logger adds the user ID to every line.
+// It is a no-op when the context already carries the same user ID.
+func WithUserID(ctx context.Context, userID int64) context.Context {
+	if current, ok := ctx.Value(userIDKey{}).(int64); ok && current == userID {
+		return ctx
+	}
+	logger := FromContext(ctx).With().Int64("userID", userID).Logger()
+	ctx = context.WithValue(ctx, userIDKey{}, userID)
+	return context.WithValue(ctx, loggerKey{}, &logger)
+}
+
+// WithOrderID returns a context whose logger adds the order ID to every line, so the service
+// and repository logs of one order share the field without annotating each call.
+// It is a no-op when the context already carries the same order ID.
+func WithOrderID(ctx context.Context, orderID int64) context.Context {
+	if current, ok := ctx.Value(orderIDKey{}).(int64); ok && current == orderID {
+		return ctx
+	}
+	logger := FromContext(ctx).With().Int64("orderID", orderID).Logger()
+	ctx = context.WithValue(ctx, orderIDKey{}, orderID)
+	return context.WithValue(ctx, loggerKey{}, &logger)
+}
@@ -1,14 +1,34 @@
 package log
 
 import (
-	"github.com/rs/zerolog"
+	"order-service/config"
 	"os"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
 )
 
 var Logger *zerolog.Logger
 
-func InitLogger() {
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
+// InitLogger configures the global Logger from the app config. The level defaults to info and the
+// format to console; production should log JSON so lines can be parsed by the log pipeline.
+func InitLogger(cfg config.App) {
+	level, err := zerolog.ParseLevel(cfg.LogLevel)
+	if err != nil || level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+
+	var logger zerolog.Logger
+	if cfg.LogFormat == FormatJSON {
+		logger = zerolog.New(os.Stdout)
+	} else {
+		logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout})
+	}
+	logger = logger.With().Timestamp().Logger()
 	Logger = &logger
 }
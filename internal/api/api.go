@@ -1,9 +1,13 @@
 package api
 
 import (
+	"fmt"
+	"net/http"
+	"order-service/internal/auth"
 	"order-service/internal/entity"
 	"order-service/internal/service"
 	"strconv"
+	"time"
 
 	_ "github.com/golang-jwt/jwt/v5"
 	_ "github.com/labstack/echo-jwt/v4"
@@ -14,47 +18,281 @@ import (
 
 type OrderHandler interface {
 	CreateOrder(c echo.Context) error
+	CreateOrders(c echo.Context) error
+	QuoteOrder(c echo.Context) error
 	UpdateOrder(c echo.Context) error
+	PatchOrder(c echo.Context) error
+	PayOrder(c echo.Context) error
 	CancelOrder(c echo.Context) error
+	DeleteOrder(c echo.Context) error
+	ReleaseOrder(c echo.Context) error
+	RepriceOrder(c echo.Context) error
+	GetOrder(c echo.Context) error
+	GetOrderHistory(c echo.Context) error
+	GetOrderReceipt(c echo.Context) error
+	ListOrders(c echo.Context) error
+	ListAllOrders(c echo.Context) error
+	CancelOrders(c echo.Context) error
+	PurgeOrder(c echo.Context) error
+	GetPricing(c echo.Context) error
 }
 
+const defaultMaxBatchSize = 100
+
 type orderHandler struct {
 	OrderService service.OrderService
+	MaxBatchSize int // Maximum number of orders accepted by CreateOrders
 }
 
-func NewOrderHandler(orderService service.OrderService) OrderHandler {
+// NewOrderHandler creates an order handler. A maxBatchSize of zero falls back to 100 orders per batch.
+func NewOrderHandler(orderService service.OrderService, maxBatchSize int) OrderHandler {
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultMaxBatchSize
+	}
+
 	return &orderHandler{
 		OrderService: orderService,
+		MaxBatchSize: maxBatchSize,
 	}
 }
 
+// CancelOrdersRequest is the body of a bulk cancel: the filter of the orders to cancel and, to resume
+// a cancel that was cut short, the next_cursor it returned.
+type CancelOrdersRequest struct {
+	entity.OrderCancelFilter
+	Cursor string `json:"cursor"`
+}
+
+// BatchOrderResult is the outcome of one order in a CreateOrders request.
+type BatchOrderResult struct {
+	Index  int           `json:"index"`
+	Status int           `json:"status"`
+	Order  *entity.Order `json:"order,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	Code   string        `json:"code,omitempty"`
+	Fields []FieldError  `json:"fields,omitempty"`
+}
+
 func (oh *orderHandler) CreateOrder(c echo.Context) error {
 	var request entity.Order
 	ctx := c.Request().Context()
 	err := c.Bind(&request)
 	if err != nil {
-		return c.JSON(400, map[string]string{"error": "Invalid order data"})
+		return bindErrorJSON(c, err)
+	}
+
+	if denied, err := oh.setRequestUser(c, &request); denied {
+		return err
+	}
+
+	// Validate before calling the service so invalid orders never reach downstream services.
+	if invalid, err := validationErrorJSON(c, &request); invalid {
+		return err
+	}
+
+	idempotencyKey := c.Request().Header.Get("Idempotency-Key")
+	if idempotencyKey != "" {
+		order, replayed, err := oh.OrderService.CreateOrderIdempotent(ctx, &request, idempotencyKey)
+		if err != nil {
+			return serviceErrorJSON(c, err, "Failed to create order")
+		}
+		if replayed {
+			return c.JSON(200, order)
+		}
+		return c.JSON(201, order)
 	}
 
 	order, err := oh.OrderService.CreateOrder(ctx, &request)
 	if err != nil {
-		return c.JSON(500, map[string]string{"error": "Failed to create order"})
+		return serviceErrorJSON(c, err, "Failed to create order")
 	}
 
 	return c.JSON(201, order)
 }
 
+// CreateOrders creates a batch of orders for admin tooling, so its route requires the admin role.
+// Every order is validated before any is created, and the response reports the outcome of each
+// order by its index in the request.
+func (oh *orderHandler) CreateOrders(c echo.Context) error {
+	var requests []entity.Order
+	ctx := c.Request().Context()
+	err := c.Bind(&requests)
+	if err != nil {
+		return bindErrorJSON(c, err)
+	}
+
+	if len(requests) == 0 {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Batch must contain at least one order")
+	}
+	if len(requests) > oh.MaxBatchSize {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("Batch must not contain more than %d orders", oh.MaxBatchSize))
+	}
+
+	results := make([]BatchOrderResult, len(requests))
+	orders := make([]*entity.Order, len(requests))
+	for i := range requests {
+		results[i].Index = i
+		if denied, err := oh.setRequestUser(c, &requests[i]); denied {
+			return err
+		}
+		if invalid := validationErrorResponse(&requests[i]); invalid != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = invalid.Error
+			results[i].Code = invalid.Code
+			results[i].Fields = invalid.Fields
+			continue
+		}
+		orders[i] = &requests[i]
+	}
+
+	created := 0
+	for i, result := range oh.OrderService.CreateOrders(ctx, orders) {
+		switch {
+		case result.Err != nil:
+			status, response := serviceErrorResponse(result.Err, "Failed to create order")
+			results[i].Status = status
+			results[i].Error = response.Error
+			results[i].Code = response.Code
+		case result.Order != nil:
+			results[i].Status = http.StatusCreated
+			results[i].Order = result.Order
+			created++
+		}
+	}
+
+	return c.JSON(200, map[string]interface{}{
+		"results": results,
+		"created": created,
+		"failed":  len(requests) - created,
+	})
+}
+
 func (oh *orderHandler) UpdateOrder(c echo.Context) error {
 	var request entity.Order
 	ctx := c.Request().Context()
 	err := c.Bind(&request)
 	if err != nil {
-		return c.JSON(400, map[string]string{"error": "Invalid order data"})
+		return bindErrorJSON(c, err)
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, request.ID); denied {
+		return err
+	}
+	// Owners may not hand their order to another user through the body.
+	if denied, err := oh.checkRequestUser(c, request.UserID); denied {
+		return err
 	}
 
 	order, err := oh.OrderService.UpdateOrder(ctx, &request)
 	if err != nil {
-		return c.JSON(500, map[string]string{"error": "Failed to update order"})
+		return serviceErrorJSON(c, err, "Failed to update order")
+	}
+
+	return c.JSON(200, order)
+}
+
+// PatchOrder changes only the fields present in the request body.
+func (oh *orderHandler) PatchOrder(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	var patch entity.OrderPatch
+	err = c.Bind(&patch)
+	if err != nil {
+		return bindErrorJSON(c, err)
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
+	}
+
+	order, err := oh.OrderService.PatchOrder(ctx, orderId, patch)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to update order")
+	}
+
+	return c.JSON(200, order)
+}
+
+// PayOrder confirms payment of an order with the payment reference in the request body.
+func (oh *orderHandler) PayOrder(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	var request entity.PaymentRequest
+	err = c.Bind(&request)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid payment data")
+	}
+
+	if invalid, err := validationErrorJSON(c, &request); invalid {
+		return err
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
+	}
+
+	order, err := oh.OrderService.PayOrder(ctx, orderId, request.PaymentReference)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to pay order")
+	}
+
+	return c.JSON(200, order)
+}
+
+// QuoteOrder returns the pricing an order would be created with, without creating it, for cart previews.
+func (oh *orderHandler) QuoteOrder(c echo.Context) error {
+	var request entity.Order
+	ctx := c.Request().Context()
+	err := c.Bind(&request)
+	if err != nil {
+		return bindErrorJSON(c, err)
+	}
+
+	if denied, err := oh.setRequestUser(c, &request); denied {
+		return err
+	}
+
+	if invalid, err := validationErrorJSON(c, &request); invalid {
+		return err
+	}
+
+	quote, err := oh.OrderService.QuoteOrder(ctx, &request)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to quote order")
+	}
+
+	return c.JSON(200, quote)
+}
+
+// RepriceOrder recomputes the totals of an unpaid order from the current pricing.
+func (oh *orderHandler) RepriceOrder(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
+	}
+
+	order, err := oh.OrderService.RepriceOrder(ctx, orderId)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to reprice order")
 	}
 
 	return c.JSON(200, order)
@@ -66,13 +304,324 @@ func (oh *orderHandler) CancelOrder(c echo.Context) error {
 
 	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
 	if err != nil {
-		return c.JSON(400, map[string]string{"error": "Invalid order ID"})
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
 	}
 
 	order, err := oh.OrderService.CancelOrder(ctx, orderId)
 	if err != nil {
-		return c.JSON(500, map[string]string{"error": "Failed to cancel order"})
+		return serviceErrorJSON(c, err, "Failed to cancel order")
+	}
+
+	return c.JSON(200, order)
+}
+
+// DeleteOrder soft deletes an order the caller owns. The order keeps its status and stays in
+// storage, but is hidden from every read; DELETE /order/:id/purge removes it for good.
+func (oh *orderHandler) DeleteOrder(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
+	}
+
+	err = oh.OrderService.DeleteOrder(ctx, orderId)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to delete order")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ReleaseOrder gives back the stock reserved by an unpaid order without cancelling it.
+func (oh *orderHandler) ReleaseOrder(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
+	}
+
+	order, err := oh.OrderService.ReleaseOrder(ctx, orderId)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to release order")
+	}
+
+	return c.JSON(200, order)
+}
+
+func (oh *orderHandler) GetOrder(c echo.Context) error {
+	orderIdStr := c.Param("id")
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	order, denied, err := oh.authorizeOrder(c, orderId)
+	if denied {
+		return err
+	}
+
+	// Pollers revalidate with If-None-Match and get an empty 304 while the order is unchanged.
+	etag := orderETag(order)
+	c.Response().Header().Set(headerETag, etag)
+	c.Response().Header().Set(echo.HeaderCacheControl, "private, no-cache")
+	if etagMatches(c.Request().Header.Get(headerIfNoneMatch), etag) {
+		return c.NoContent(http.StatusNotModified)
 	}
 
 	return c.JSON(200, order)
 }
+
+// GetOrderHistory returns the status transitions of an order, oldest first.
+func (oh *orderHandler) GetOrderHistory(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
+	}
+
+	history, err := oh.OrderService.GetOrderHistory(ctx, orderId)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to get order history")
+	}
+
+	return c.JSON(200, history)
+}
+
+// GetOrderReceipt returns a printable receipt of an order: its line items with their markup and
+// discount, and the order totals.
+func (oh *orderHandler) GetOrderReceipt(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	if _, denied, err := oh.authorizeOrder(c, orderId); denied {
+		return err
+	}
+
+	receipt, err := oh.OrderService.GetOrderReceipt(ctx, orderId)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to get order receipt")
+	}
+
+	return c.JSON(http.StatusOK, receipt)
+}
+
+func (oh *orderHandler) ListOrders(c echo.Context) error {
+	var filter entity.OrderFilter
+	ctx := c.Request().Context()
+
+	err := echo.QueryParamsBinder(c).
+		Int64("user_id", &filter.UserID).
+		String("status", &filter.Status).
+		Int("limit", &filter.Limit).
+		Int("offset", &filter.Offset).
+		BindError()
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid query parameters")
+	}
+	if invalid, err := timeQueryParam(c, "created_after", &filter.CreatedAfter); invalid {
+		return err
+	}
+	if invalid, err := timeQueryParam(c, "created_before", &filter.CreatedBefore); invalid {
+		return err
+	}
+
+	// Only admins may view every user's orders; everyone else only sees their own.
+	if !hasRole(c, RoleAdmin) {
+		user, err := auth.UserFromContext(c)
+		if err != nil {
+			return errorJSON(c, http.StatusForbidden, codeForbidden, "Token does not identify a user")
+		}
+		filter.UserID = user.UserID
+	}
+
+	orders, total, err := oh.OrderService.ListOrders(ctx, filter)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to list orders")
+	}
+
+	return c.JSON(200, map[string]interface{}{
+		"orders": orders,
+		"total":  total,
+	})
+}
+
+// timeQueryParam parses the optional RFC 3339 timestamp in the named query parameter into dest, in UTC.
+// Timestamps without a UTC offset, such as a bare date, are rejected rather than read in the server's
+// zone: the 400 response naming the parameter has then already been written and invalid is true.
+func timeQueryParam(c echo.Context, name string, dest **time.Time) (bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		message := fmt.Sprintf("Invalid %s: expected an RFC 3339 timestamp with a UTC offset, e.g. 2024-05-01T00:00:00Z", name)
+		return true, errorJSON(c, http.StatusBadRequest, codeInvalidRequest, message)
+	}
+	parsed = parsed.UTC()
+	*dest = &parsed
+	return false, nil
+}
+
+// ListAllOrders returns a page of every user's orders, newest first. The next page is requested
+// by passing the returned next_cursor as the cursor query parameter.
+func (oh *orderHandler) ListAllOrders(c echo.Context) error {
+	var cursor string
+	var limit int
+	ctx := c.Request().Context()
+
+	err := echo.QueryParamsBinder(c).
+		String("cursor", &cursor).
+		Int("limit", &limit).
+		BindError()
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid query parameters")
+	}
+
+	page, err := oh.OrderService.ListAllOrders(ctx, cursor, limit)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to list orders")
+	}
+
+	return c.JSON(200, page)
+}
+
+// CancelOrders cancels every order matching the filter in the body, the kill switch of an aborted sale.
+// A cancel cut short by the request deadline returns a next_cursor to resume from; repeating the
+// request is safe either way, since orders already cancelled no longer match.
+func (oh *orderHandler) CancelOrders(c echo.Context) error {
+	var request CancelOrdersRequest
+	ctx := c.Request().Context()
+	err := c.Bind(&request)
+	if err != nil {
+		return bindErrorJSON(c, err)
+	}
+
+	if invalid, err := validationErrorJSON(c, &request); invalid {
+		return err
+	}
+
+	result, err := oh.OrderService.CancelOrders(ctx, request.OrderCancelFilter, request.Cursor)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to cancel orders")
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (oh *orderHandler) PurgeOrder(c echo.Context) error {
+	orderIdStr := c.Param("id")
+	ctx := c.Request().Context()
+
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order ID")
+	}
+
+	err = oh.OrderService.PurgeOrder(ctx, orderId)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to purge order")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetPricing returns the pricing the service would apply to a product, and whether it was served
+// from the pricing cache, to help debug pricing discrepancies without creating an order.
+func (oh *orderHandler) GetPricing(c echo.Context) error {
+	productIdStr := c.Param("productId")
+	ctx := c.Request().Context()
+
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil || productId <= 0 {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid product ID")
+	}
+
+	lookup, err := oh.OrderService.GetPricing(ctx, productId)
+	if err != nil {
+		return serviceErrorJSON(c, err, "Failed to get pricing")
+	}
+
+	return c.JSON(http.StatusOK, lookup)
+}
+
+// authorizeOrder loads the order and checks that the caller owns it or has the admin role.
+// When access is denied or the order cannot be loaded, the error response has already been
+// written and denied is true; the returned error should be passed straight back to echo.
+func (oh *orderHandler) authorizeOrder(c echo.Context, orderId int64) (*entity.Order, bool, error) {
+	order, err := oh.OrderService.GetOrder(c.Request().Context(), orderId)
+	if err != nil {
+		return nil, true, serviceErrorJSON(c, err, "Failed to get order")
+	}
+
+	if hasRole(c, RoleAdmin) {
+		return order, false, nil
+	}
+
+	user, err := auth.UserFromContext(c)
+	if err != nil || user.UserID != order.UserID {
+		return nil, true, errorJSON(c, http.StatusForbidden, codeForbidden, "Order does not belong to the caller")
+	}
+
+	return order, false, nil
+}
+
+// setRequestUser sets the owner of an order being created to the caller, so the user ID in the body
+// is only honoured for callers with the admin role. When the token does not identify a user, the
+// 403 response has already been written and denied is true.
+func (oh *orderHandler) setRequestUser(c echo.Context, order *entity.Order) (bool, error) {
+	if hasRole(c, RoleAdmin) {
+		return false, nil
+	}
+
+	user, err := auth.UserFromContext(c)
+	if err != nil {
+		return true, errorJSON(c, http.StatusForbidden, codeForbidden, "Token does not identify a user")
+	}
+	order.UserID = user.UserID
+	return false, nil
+}
+
+// checkRequestUser checks that a user_id sent in a request body is the caller's own, unless the
+// caller has the admin role. A zero userID means the body did not set one. When the user ID is
+// rejected, the 403 response has already been written and denied is true.
+func (oh *orderHandler) checkRequestUser(c echo.Context, userID int64) (bool, error) {
+	if userID == 0 || hasRole(c, RoleAdmin) {
+		return false, nil
+	}
+
+	user, err := auth.UserFromContext(c)
+	if err != nil || user.UserID != userID {
+		return true, errorJSON(c, http.StatusForbidden, codeForbidden, "user_id does not match the caller")
+	}
+	return false, nil
+}
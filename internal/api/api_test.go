@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/service"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	logger := zerolog.Nop()
+	log.Logger = &logger
+	os.Exit(m.Run())
+}
+
+// stubOrderService serves one stored order. Methods a test does not override panic through the
+// nil embedded interface.
+type stubOrderService struct {
+	service.OrderService
+	order       entity.Order
+	updateOrder func(ctx context.Context, order *entity.Order) (*entity.Order, error)
+}
+
+func (s *stubOrderService) GetOrder(ctx context.Context, orderId int64) (*entity.Order, error) {
+	if orderId != s.order.ID {
+		return nil, fmt.Errorf("%w: ID %d", service.ErrOrderNotFound, orderId)
+	}
+	order := s.order
+	return &order, nil
+}
+
+func (s *stubOrderService) UpdateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+	return s.updateOrder(ctx, order)
+}
+
+// newRequestContext returns an echo context for a JSON request made with a token carrying claims,
+// as the JWT middleware would leave it.
+func newRequestContext(method, target, body string, claims jwt.MapClaims) (echo.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	recorder := httptest.NewRecorder()
+	c := echo.New().NewContext(request, recorder)
+	c.Set("user", &jwt.Token{Claims: claims})
+	return c, recorder
+}
+
+func TestUpdateOrderReturnsConflictForStaleVersion(t *testing.T) {
+	orderService := &stubOrderService{
+		order: entity.Order{ID: 1, UserID: 7, Status: entity.OrderStatusReleased, Version: 2},
+		updateOrder: func(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+			// Another writer already moved the order past the version this client read.
+			return nil, fmt.Errorf("failed to update order: %w: ID %d at version %d", service.ErrConcurrentUpdate, order.ID, order.Version)
+		},
+	}
+	handler := NewOrderHandler(orderService, 0)
+
+	c, recorder := newRequestContext(http.MethodPut, "/order", `{"id":"1","status":"cancelled","version":1}`, jwt.MapClaims{"sub": "7"})
+	err := handler.UpdateOrder(c)
+	if err != nil {
+		t.Fatalf("UpdateOrder: %v", err)
+	}
+
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusConflict)
+	}
+	var response ErrorResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.Code != codeConcurrentUpdate {
+		t.Errorf("code = %q, want %q", response.Code, codeConcurrentUpdate)
+	}
+}
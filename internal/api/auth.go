@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/infrastructure/log"
+	"order-service/internal/auth"
+	"order-service/internal/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RoleAdmin is the role required for admin-only operations such as purging orders.
+const RoleAdmin = "admin"
+
+// hasRole reports whether the JWT validated by the echojwt middleware grants the given role,
+// either in its "role" claim or in its "roles" list claim.
+func hasRole(c echo.Context, role string) bool {
+	user, err := auth.UserFromContext(c)
+	return err == nil && user.HasRole(role)
+}
+
+// RequireRole returns middleware that rejects requests with 403 unless the caller's JWT grants
+// the given role. It must run after the JWT middleware.
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !hasRole(c, role) {
+				return errorJSON(c, http.StatusForbidden, codeForbidden, fmt.Sprintf("Role %q required", role))
+			}
+			return next(c)
+		}
+	}
+}
+
+// SetActor returns middleware that records the caller's user ID from the JWT as the actor of
+// order changes made by the request, e.g. "user:42", and adds it to the request's log lines.
+// It must run after the JWT middleware.
+func SetActor() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if user, err := auth.UserFromContext(c); err == nil {
+				ctx := service.WithActor(c.Request().Context(), fmt.Sprintf("user:%d", user.UserID))
+				ctx = log.WithUserID(ctx, user.UserID)
+				c.SetRequest(c.Request().WithContext(ctx))
+			}
+			return next(c)
+		}
+	}
+}
+
+// ScopeTenant returns middleware that scopes the request's orders to the tenant in the caller's JWT,
+// so orders of other tenants are reported as not found. Admins without a tenant claim are not
+// scoped; anyone else without one is rejected with 403 rather than seeing every tenant's orders.
+// It must run after the JWT middleware.
+func ScopeTenant() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := auth.UserFromContext(c)
+			if err == nil && user.TenantID != "" {
+				c.SetRequest(c.Request().WithContext(service.WithTenant(c.Request().Context(), user.TenantID)))
+				return next(c)
+			}
+			if err != nil || !user.HasRole(RoleAdmin) {
+				return errorJSON(c, http.StatusForbidden, codeForbidden, "Token does not identify a tenant")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	codeInvalidRequest     = "invalid_request"
+	codeNotFound           = "not_found"
+	codeForbidden          = "forbidden"
+	codeInsufficientStock  = "insufficient_stock"
+	codeInvalidTransition  = "invalid_status_transition"
+	codeConcurrentUpdate   = "concurrent_update"
+	codeNotRepriceable     = "order_not_repriceable"
+	codeTooManyLineItems   = "too_many_line_items"
+	codeMixedCurrency      = "mixed_currency"
+	codePurchaseLimit      = "purchase_limit_exceeded"
+	codeIdempotencyKey     = "idempotency_key_conflict"
+	codeReservationExpired = "reservation_expired"
+	codeServiceUnavailable = "service_unavailable"
+	codeDownstreamProtocol = "downstream_protocol_error"
+	codeInternalError      = "internal_error"
+)
+
+// ErrorResponse is the body returned for every failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func errorJSON(c echo.Context, status int, code, message string) error {
+	return c.JSON(status, ErrorResponse{Error: message, Code: code})
+}
+
+// serviceErrorJSON maps a service error to its HTTP status and error code.
+// Errors that are not part of the service error model are reported as 500 with the fallback message.
+func serviceErrorJSON(c echo.Context, err error, fallbackMessage string) error {
+	status, response := serviceErrorResponse(err, fallbackMessage)
+	return c.JSON(status, response)
+}
+
+// serviceErrorResponse returns the HTTP status and error body for a service error.
+func serviceErrorResponse(err error, fallbackMessage string) (int, ErrorResponse) {
+	switch {
+	case errors.Is(err, service.ErrInsufficientStock):
+		return http.StatusConflict, ErrorResponse{Error: err.Error(), Code: codeInsufficientStock}
+	case errors.Is(err, service.ErrInvalidStatusTransition):
+		return http.StatusConflict, ErrorResponse{Error: err.Error(), Code: codeInvalidTransition}
+	case errors.Is(err, service.ErrConcurrentUpdate):
+		return http.StatusConflict, ErrorResponse{Error: err.Error(), Code: codeConcurrentUpdate}
+	case errors.Is(err, service.ErrOrderNotRepriceable):
+		return http.StatusConflict, ErrorResponse{Error: err.Error(), Code: codeNotRepriceable}
+	case errors.Is(err, service.ErrTooManyLineItems):
+		return http.StatusBadRequest, ErrorResponse{Error: err.Error(), Code: codeTooManyLineItems}
+	case errors.Is(err, service.ErrPurchaseLimitExceeded):
+		return http.StatusConflict, ErrorResponse{Error: err.Error(), Code: codePurchaseLimit}
+	case errors.Is(err, service.ErrReservationExpired):
+		return http.StatusConflict, ErrorResponse{Error: err.Error(), Code: codeReservationExpired}
+	case errors.Is(err, service.ErrIdempotencyKeyConflict):
+		return http.StatusConflict, ErrorResponse{Error: err.Error(), Code: codeIdempotencyKey}
+	case errors.Is(err, service.ErrMixedCurrency):
+		return http.StatusBadRequest, ErrorResponse{Error: err.Error(), Code: codeMixedCurrency}
+	case errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrOffsetTooLarge):
+		return http.StatusBadRequest, ErrorResponse{Error: err.Error(), Code: codeInvalidRequest}
+	case errors.Is(err, service.ErrOrderNotFound):
+		return http.StatusNotFound, ErrorResponse{Error: err.Error(), Code: codeNotFound}
+	case errors.Is(err, service.ErrProductServiceDown), errors.Is(err, service.ErrPricingServiceDown):
+		return http.StatusServiceUnavailable, ErrorResponse{Error: err.Error(), Code: codeServiceUnavailable}
+	case errors.Is(err, service.ErrDownstreamProtocol):
+		return http.StatusBadGateway, ErrorResponse{Error: err.Error(), Code: codeDownstreamProtocol}
+	default:
+		return http.StatusInternalServerError, ErrorResponse{Error: fallbackMessage, Code: codeInternalError}
+	}
+}
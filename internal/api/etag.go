@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"order-service/internal/entity"
+	"strings"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// orderETag returns a weak ETag of the order. Every update increments the version and
+// moves updated_at, so the tag changes whenever the order does.
+func orderETag(order *entity.Order) string {
+	return fmt.Sprintf(`W/"%d-%d-%d"`, order.ID, order.Version, order.UpdatedAt.UnixNano())
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag, using the weak
+// comparison required for If-None-Match. The header may be "*" or a comma-separated list of tags.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
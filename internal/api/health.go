@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+type HealthHandler interface {
+	Liveness(c echo.Context) error
+	Readiness(c echo.Context) error
+}
+
+type healthHandler struct {
+	DBs          []*gorm.DB
+	Redis        *redis.Client
+	KafkaBrokers []string
+}
+
+func NewHealthHandler(dbs []*gorm.DB, rdb *redis.Client, kafkaBrokers []string) HealthHandler {
+	return &healthHandler{
+		DBs:          dbs,
+		Redis:        rdb,
+		KafkaBrokers: kafkaBrokers,
+	}
+}
+
+// Liveness reports that the process is up. It does not touch any dependency.
+func (hh *healthHandler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness verifies that every database shard, Redis and Kafka are reachable. Each shard is
+// reported separately as shard_<index>, so an outage of a single shard is visible.
+// It responds 503 listing the failed dependencies if any check fails.
+func (hh *healthHandler) Readiness(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessCheckTimeout)
+	defer cancel()
+
+	checks := hh.checkShards(ctx)
+	checks["redis"] = hh.checkRedis(ctx)
+	checks["kafka"] = hh.checkKafka(ctx)
+
+	status := http.StatusOK
+	failed := []string{}
+	for name, result := range checks {
+		if result != "ok" {
+			status = http.StatusServiceUnavailable
+			failed = append(failed, name)
+		}
+	}
+
+	return c.JSON(status, map[string]interface{}{
+		"checks": checks,
+		"failed": failed,
+	})
+}
+
+// checkShards pings every shard concurrently, so a shard that hangs does not use up the timeout
+// of the others. The result of each shard is keyed by its index.
+func (hh *healthHandler) checkShards(ctx context.Context) map[string]string {
+	results := make([]string, len(hh.DBs))
+	var wg sync.WaitGroup
+	for i, db := range hh.DBs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = checkDatabase(ctx, db)
+		}()
+	}
+	wg.Wait()
+
+	checks := make(map[string]string, len(results)+2)
+	for i, result := range results {
+		checks[fmt.Sprintf("shard_%d", i)] = result
+	}
+	return checks
+}
+
+func checkDatabase(ctx context.Context, db *gorm.DB) string {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err.Error()
+	}
+	err = sqlDB.PingContext(ctx)
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func (hh *healthHandler) checkRedis(ctx context.Context) string {
+	err := hh.Redis.Ping(ctx).Err()
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+// checkKafka succeeds if any of the configured brokers accepts a connection.
+func (hh *healthHandler) checkKafka(ctx context.Context) string {
+	var lastErr error
+	for _, broker := range hh.KafkaBrokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_ = conn.Close()
+		return "ok"
+	}
+	if lastErr == nil {
+		return "no brokers configured"
+	}
+	return lastErr.Error()
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"order-service/infrastructure/log"
+	"order-service/internal/outbox"
+
+	"github.com/labstack/echo/v4"
+)
+
+const defaultRedriveMaxAttempts = 50
+
+type OutboxHandler interface {
+	RedriveOutbox(c echo.Context) error
+	CountFailedOutbox(c echo.Context) error
+}
+
+type outboxHandler struct {
+	Publishers         []*outbox.Publisher // Outbox publisher of every shard
+	RedriveMaxAttempts int                 // Lifetime attempts after which a failed event is no longer redriven
+}
+
+// NewOutboxHandler creates the handler of the outbox admin endpoints. A redriveMaxAttempts of zero
+// falls back to redriving events that failed fewer than 50 times.
+func NewOutboxHandler(publishers []*outbox.Publisher, redriveMaxAttempts int) OutboxHandler {
+	if redriveMaxAttempts <= 0 {
+		redriveMaxAttempts = defaultRedriveMaxAttempts
+	}
+
+	return &outboxHandler{
+		Publishers:         publishers,
+		RedriveMaxAttempts: redriveMaxAttempts,
+	}
+}
+
+// OutboxRedriveResult is the outcome of a RedriveOutbox request.
+type OutboxRedriveResult struct {
+	Requeued int64 `json:"requeued"` // Failed events moved back to pending
+	Failed   int64 `json:"failed"`   // Failed events left, past the max_attempts threshold
+}
+
+// RedriveOutbox moves the failed outbox events of every shard back to pending and wakes the
+// publishers to publish them. Only events that failed fewer than the max_attempts query parameter
+// times over their lifetime are requeued, so an event that can never be published is not retried forever.
+// Redriving is idempotent, so a request that fails part way through can simply be repeated.
+func (oh *outboxHandler) RedriveOutbox(c echo.Context) error {
+	maxAttempts := oh.RedriveMaxAttempts
+	ctx := c.Request().Context()
+
+	err := echo.QueryParamsBinder(c).
+		Int("max_attempts", &maxAttempts).
+		BindError()
+	if err != nil || maxAttempts <= 0 {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid query parameters")
+	}
+
+	var result OutboxRedriveResult
+	for _, publisher := range oh.Publishers {
+		requeued, err := publisher.Redrive(ctx, maxAttempts)
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Int64("requeued", result.Requeued).Msg("Failed to redrive outbox events")
+			return errorJSON(c, http.StatusInternalServerError, codeInternalError, "Failed to redrive outbox events")
+		}
+		result.Requeued += requeued
+	}
+
+	result.Failed, err = oh.countFailed(c)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, codeInternalError, "Failed to count failed outbox events")
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// CountFailedOutbox returns the number of outbox events, across every shard, that gave up publishing.
+func (oh *outboxHandler) CountFailedOutbox(c echo.Context) error {
+	failed, err := oh.countFailed(c)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, codeInternalError, "Failed to count failed outbox events")
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{"failed": failed})
+}
+
+// countFailed sums the failed outbox events of every shard.
+func (oh *outboxHandler) countFailed(c echo.Context) (int64, error) {
+	ctx := c.Request().Context()
+
+	var failed int64
+	for _, publisher := range oh.Publishers {
+		count, err := publisher.CountFailed(ctx)
+		if err != nil {
+			return 0, err
+		}
+		failed += count
+	}
+	return failed, nil
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+var validate = newValidator()
+
+// newValidator returns a validator that names fields by their JSON names, so reported
+// field paths match the request body, e.g. product_requests[2].quantity.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// FieldError describes a single field that failed validation.
+type FieldError struct {
+	Field   string      `json:"field"`           // JSON path of the field, e.g. product_requests[2].quantity
+	Rule    string      `json:"rule"`            // Validation rule that failed, e.g. gt
+	Value   interface{} `json:"value,omitempty"` // Rejected value, omitted for lists and objects
+	Message string      `json:"message"`
+}
+
+// ValidationErrorResponse is the body returned when a request payload fails validation.
+type ValidationErrorResponse struct {
+	ErrorResponse
+	Fields []FieldError `json:"fields"`
+}
+
+// validationErrorJSON validates payload against its validate tags and writes a 400 listing
+// every invalid field. It returns ok=false if the payload is valid and nothing was written.
+func validationErrorJSON(c echo.Context, payload interface{}) (bool, error) {
+	response := validationErrorResponse(payload)
+	if response == nil {
+		return false, nil
+	}
+
+	return true, c.JSON(http.StatusBadRequest, response)
+}
+
+// bindErrorJSON writes the 400 for a request body that could not be bound. A value of the wrong
+// type is reported like a validation error on its field; any other error as malformed data.
+func bindErrorJSON(c echo.Context, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return errorJSON(c, http.StatusBadRequest, codeInvalidRequest, "Invalid order data")
+	}
+
+	return c.JSON(http.StatusBadRequest, &ValidationErrorResponse{
+		ErrorResponse: ErrorResponse{Error: "Invalid order data", Code: codeInvalidRequest},
+		Fields: []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("must be a %s, got %s", typeErr.Type, typeErr.Value),
+		}},
+	})
+}
+
+// validationErrorResponse validates payload against its validate tags and returns the body
+// listing every invalid field, or nil if the payload is valid.
+func validationErrorResponse(payload interface{}) *ValidationErrorResponse {
+	err := validate.Struct(payload)
+	if err == nil {
+		return nil
+	}
+
+	response := &ValidationErrorResponse{
+		ErrorResponse: ErrorResponse{Error: "Invalid order data", Code: codeInvalidRequest},
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return response
+	}
+
+	response.Fields = make([]FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		response.Fields = append(response.Fields, FieldError{
+			Field:   fieldPath(fieldErr),
+			Rule:    fieldErr.Tag(),
+			Value:   rejectedValue(fieldErr),
+			Message: fieldErrorMessage(fieldErr),
+		})
+	}
+	return response
+}
+
+// fieldPath returns the JSON path of the field, without the name of the validated struct.
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, found := strings.Cut(fieldErr.Namespace(), ".")
+	if !found {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+// rejectedValue returns the value that failed validation, or nil for lists, maps and structs
+// whose whole content would not help point at the bad input.
+func rejectedValue(fieldErr validator.FieldError) interface{} {
+	switch fieldErr.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Pointer, reflect.Interface, reflect.Invalid:
+		return nil
+	default:
+		return fieldErr.Value()
+	}
+}
+
+func fieldErrorMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must contain at least %s item(s)", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param())
+	case "unique":
+		return fmt.Sprintf("must not contain duplicate %s values", fieldErr.Param())
+	default:
+		return fmt.Sprintf("failed %q validation", fieldErr.Tag())
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// tokenKey is the echo context key the echojwt middleware stores the validated token under.
+const tokenKey = "user"
+
+// userKey is the echo context key the extracted UserClaims are cached under.
+const userKey = "auth.user"
+
+var (
+	// ErrMissingToken is returned when the request carries no token validated by the JWT middleware.
+	ErrMissingToken = errors.New("missing token")
+	// ErrInvalidClaims is returned when the token's claims do not identify a user.
+	ErrInvalidClaims = errors.New("invalid token claims")
+)
+
+// UserClaims is the caller's identity taken from the JWT.
+type UserClaims struct {
+	UserID   int64    // From the "user_id" claim, or the "sub" claim when it holds a numeric ID
+	Subject  string   // The standard "sub" claim, may be empty when "user_id" is set
+	Roles    []string // From the "role" and "roles" claims
+	TenantID string   // From the "tenant_id" claim, empty for tokens without a tenant
+}
+
+// HasRole reports whether the user was granted role.
+func (u UserClaims) HasRole(role string) bool {
+	for _, granted := range u.Roles {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
+
+// UserFromContext returns the identity of the caller from the JWT validated by the echojwt
+// middleware. The claims are parsed once per request and cached in c, so every handler and
+// middleware sees the same identity.
+//
+// Returns:
+//   - The caller's UserClaims.
+//   - ErrMissingToken if the request has no validated token, or ErrInvalidClaims if its claims
+//     do not identify a user or hold roles or a tenant of the wrong type.
+func UserFromContext(c echo.Context) (UserClaims, error) {
+	if user, ok := c.Get(userKey).(UserClaims); ok {
+		return user, nil
+	}
+
+	token, ok := c.Get(tokenKey).(*jwt.Token)
+	if !ok {
+		return UserClaims{}, ErrMissingToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return UserClaims{}, fmt.Errorf("%w: unsupported claims type %T", ErrInvalidClaims, token.Claims)
+	}
+
+	user, err := parseClaims(claims)
+	if err != nil {
+		return UserClaims{}, err
+	}
+	c.Set(userKey, user)
+	return user, nil
+}
+
+func parseClaims(claims jwt.MapClaims) (UserClaims, error) {
+	var user UserClaims
+
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return UserClaims{}, fmt.Errorf("%w: %w", ErrInvalidClaims, err)
+	}
+	user.Subject = subject
+
+	userID, err := parseUserID(claims["user_id"], subject)
+	if err != nil {
+		return UserClaims{}, err
+	}
+	user.UserID = userID
+
+	if role, ok := claims["role"]; ok {
+		roleName, ok := role.(string)
+		if !ok {
+			return UserClaims{}, fmt.Errorf("%w: role must be a string", ErrInvalidClaims)
+		}
+		user.Roles = append(user.Roles, roleName)
+	}
+	if roles, ok := claims["roles"]; ok {
+		roleList, ok := roles.([]interface{})
+		if !ok {
+			return UserClaims{}, fmt.Errorf("%w: roles must be a list", ErrInvalidClaims)
+		}
+		for _, role := range roleList {
+			roleName, ok := role.(string)
+			if !ok {
+				return UserClaims{}, fmt.Errorf("%w: roles must be strings", ErrInvalidClaims)
+			}
+			user.Roles = append(user.Roles, roleName)
+		}
+	}
+
+	if tenant, ok := claims["tenant_id"]; ok {
+		tenantID, ok := tenant.(string)
+		if !ok {
+			return UserClaims{}, fmt.Errorf("%w: tenant_id must be a string", ErrInvalidClaims)
+		}
+		user.TenantID = tenantID
+	}
+
+	return user, nil
+}
+
+// parseUserID reads the user ID from the "user_id" claim, falling back to the subject when it
+// holds a numeric ID.
+func parseUserID(claim interface{}, subject string) (int64, error) {
+	switch v := claim.(type) {
+	case float64:
+		if v > 0 && v == float64(int64(v)) {
+			return int64(v), nil
+		}
+	case string:
+		if id, err := strconv.ParseInt(v, 10, 64); err == nil && id > 0 {
+			return id, nil
+		}
+	case nil:
+		if id, err := strconv.ParseInt(subject, 10, 64); err == nil && id > 0 {
+			return id, nil
+		}
+		return 0, fmt.Errorf("%w: token does not identify a user", ErrInvalidClaims)
+	}
+	return 0, fmt.Errorf("%w: user_id must be a positive integer", ErrInvalidClaims)
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"order-service/infrastructure/log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	defaultJWKSTimeout         = 10 * time.Second
+	// minJWKSRefreshInterval bounds how often a token with an unknown key ID may trigger a refetch,
+	// so tokens with made-up key IDs cannot make every request call the identity provider.
+	minJWKSRefreshInterval = 30 * time.Second
+	// maxJWKSSize bounds how much of the JWKS response is read.
+	maxJWKSSize = 1 << 20
+)
+
+var (
+	// ErrUnknownKey is returned when a token is signed with a key that is not in the JWKS.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrUnexpectedSigningMethod is returned when a token is not signed with RS256.
+	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+)
+
+// jwk is one key of a JSON Web Key Set. Only the fields needed for RSA signature keys are read.
+type jwk struct {
+	Kty string `json:"kty"` // Key type, only RSA keys are used
+	Use string `json:"use"` // Intended use, keys for encryption ("enc") are skipped
+	Kid string `json:"kid"` // Key ID matched against the token's kid header
+	N   string `json:"n"`   // Base64url encoded RSA modulus
+	E   string `json:"e"`   // Base64url encoded RSA public exponent
+}
+
+// JWKS verifies RS256 tokens against the keys an identity provider publishes at a JWKS URL.
+// The keys are cached and refetched once they are older than RefreshInterval, or as soon as a
+// token names a key ID that is not cached, so tokens signed after a key rotation are accepted.
+type JWKS struct {
+	URL             string        // Where the JSON Web Key Set is published
+	RefreshInterval time.Duration // How long fetched keys are used before they are refetched
+	Client          *http.Client  // Client the keys are fetched with
+
+	mu        sync.RWMutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	// refreshMu serializes fetches, so concurrent requests with a new key ID fetch the set once.
+	refreshMu sync.Mutex
+}
+
+// NewJWKS creates a JWKS for the key set at url. A zero refreshInterval falls back to 1h.
+// The keys are fetched on first use.
+func NewJWKS(url string, refreshInterval time.Duration) *JWKS {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJWKSRefreshInterval
+	}
+
+	return &JWKS{
+		URL:             url,
+		RefreshInterval: refreshInterval,
+		Client:          &http.Client{Timeout: defaultJWKSTimeout},
+	}
+}
+
+// Keyfunc returns the public key a token is verified with, for use as the jwt.Keyfunc of the
+// JWT middleware. Tokens without a kid header are accepted only while the set holds a single key.
+//
+// Returns:
+//   - The *rsa.PublicKey matching the token's kid header.
+//   - ErrUnexpectedSigningMethod if the token is not signed with RS256, ErrUnknownKey if no key
+//     matches, or an error if the keys cannot be fetched.
+func (j *JWKS) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedSigningMethod, token.Method.Alg())
+	}
+	kid, _ := token.Header["kid"].(string)
+
+	if key, fresh := j.lookup(kid); key != nil && fresh {
+		return key, nil
+	}
+
+	// The cached key is still used when a stale set cannot be refetched, so an identity
+	// provider outage does not reject tokens signed with keys we already know.
+	err := j.refresh(context.Background(), kid)
+	key, _ := j.lookup(kid)
+	if key != nil {
+		if err != nil {
+			log.Logger.Warn().Err(err).Str("url", j.URL).Msg("Failed to refresh JWKS, using cached keys")
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+// lookup returns the cached key for kid, or the only cached key when kid is empty, and whether
+// the cached set is younger than RefreshInterval.
+func (j *JWKS) lookup(kid string) (*rsa.PublicKey, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	fresh := time.Since(j.fetchedAt) < j.RefreshInterval
+	if kid == "" {
+		if len(j.keys) != 1 {
+			return nil, fresh
+		}
+		for _, key := range j.keys {
+			return key, fresh
+		}
+	}
+	return j.keys[kid], fresh
+}
+
+// refresh refetches the key set unless another caller did while this one waited, or unless the
+// set was fetched within minJWKSRefreshInterval.
+func (j *JWKS) refresh(ctx context.Context, kid string) error {
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+
+	j.mu.RLock()
+	_, known := j.keys[kid]
+	sinceFetch := time.Since(j.fetchedAt)
+	j.mu.RUnlock()
+	if (known || kid == "") && sinceFetch < j.RefreshInterval {
+		return nil
+	}
+	if sinceFetch < minJWKSRefreshInterval {
+		return nil
+	}
+
+	keys, err := j.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+
+	log.Logger.Info().Str("url", j.URL).Int("keys", len(keys)).Msg("Fetched JWKS")
+	return nil
+}
+
+// fetch downloads the key set and decodes its RSA signature keys by key ID.
+func (j *JWKS) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	response, err := j.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %d", response.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err = json.NewDecoder(io.LimitReader(response.Body, maxJWKSSize)).Decode(&set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		publicKey, err := key.rsaPublicKey()
+		if err != nil {
+			log.Logger.Warn().Err(err).Str("kid", key.Kid).Msg("Skipping invalid JWKS key")
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("failed to fetch JWKS: no RSA signature keys")
+	}
+	return keys, nil
+}
+
+// rsaPublicKey decodes the key's modulus and exponent.
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 2 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA key")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
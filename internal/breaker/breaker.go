@@ -0,0 +1,249 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrServiceUnavailable is returned without calling the downstream service while the breaker is open.
+var ErrServiceUnavailable = errors.New("service unavailable: circuit breaker is open")
+
+// ErrOutcomeIgnored marks a call whose outcome says nothing about the health of the downstream
+// service, such as one cancelled by its caller. Calls returning an error that wraps it count as
+// neither a success nor a failure.
+var ErrOutcomeIgnored = errors.New("circuit breaker: outcome ignored")
+
+const (
+	defaultFailureThreshold = 0.5
+	defaultMinRequests      = 20
+	defaultWindow           = 10 * time.Second
+	defaultCooldown         = 30 * time.Second
+
+	numBuckets = 10
+)
+
+// State is the current state of a circuit breaker.
+type State int
+
+const (
+	// StateClosed lets every call through and records its outcome.
+	StateClosed State = iota
+	// StateOpen rejects every call until the cooldown elapses.
+	StateOpen
+	// StateHalfOpen lets a single trial call through to decide whether to close or reopen.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Config holds the thresholds of a circuit breaker. Zero values fall back to
+// a 50% failure threshold over at least 20 requests in a 10s window, with a 30s cooldown.
+type Config struct {
+	FailureThreshold float64       // Failure ratio in the window at which the breaker opens
+	MinRequests      int           // Minimum requests in the window before the ratio is evaluated
+	Window           time.Duration // Length of the rolling window
+	Cooldown         time.Duration // How long the breaker stays open before allowing a trial call
+}
+
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// CircuitBreaker short-circuits calls to a failing downstream service.
+// Outcomes are counted in a rolling window split into buckets.
+type CircuitBreaker struct {
+	name          string
+	config        Config
+	mu            sync.Mutex
+	state         State
+	openedAt      time.Time
+	trialInFlight bool
+	buckets       [numBuckets]bucket
+	onStateChange func(name string, from, to State)
+	now           func() time.Time
+}
+
+// New creates a closed circuit breaker identified by name.
+func New(name string, config Config) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = defaultMinRequests
+	}
+	if config.Window <= 0 {
+		config.Window = defaultWindow
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = defaultCooldown
+	}
+
+	return &CircuitBreaker{
+		name:   name,
+		config: config,
+		state:  StateClosed,
+		now:    time.Now,
+	}
+}
+
+// Name returns the name the breaker was created with.
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// State returns the current state of the breaker.
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.refreshState()
+	return cb.state
+}
+
+// OnStateChange registers a callback invoked on every state transition.
+// The callback runs while the breaker is locked and must not call back into it.
+func (cb *CircuitBreaker) OnStateChange(fn func(name string, from, to State)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.onStateChange = fn
+}
+
+// Execute runs fn if the breaker allows it and records the outcome.
+// It returns ErrServiceUnavailable without running fn while the breaker is open.
+// An error wrapping ErrOutcomeIgnored is returned without being recorded, and a half-open
+// breaker lets the next call through as its trial instead.
+func (cb *CircuitBreaker) Execute(fn func() error) error {
+	err := cb.allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if errors.Is(err, ErrOutcomeIgnored) {
+		cb.release()
+		return err
+	}
+	cb.record(err == nil)
+	return err
+}
+
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.refreshState()
+	switch cb.state {
+	case StateOpen:
+		return ErrServiceUnavailable
+	case StateHalfOpen:
+		if cb.trialInFlight {
+			return ErrServiceUnavailable
+		}
+		cb.trialInFlight = true
+	}
+	return nil
+}
+
+// release gives back the trial slot of a half-open breaker without deciding its state.
+func (cb *CircuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == StateHalfOpen {
+		cb.trialInFlight = false
+	}
+}
+
+func (cb *CircuitBreaker) record(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == StateHalfOpen {
+		cb.trialInFlight = false
+		if success {
+			cb.resetBuckets()
+			cb.setState(StateClosed)
+		} else {
+			cb.openedAt = cb.now()
+			cb.setState(StateOpen)
+		}
+		return
+	}
+
+	b := cb.currentBucket()
+	if success {
+		b.successes++
+	} else {
+		b.failures++
+	}
+
+	if cb.state == StateClosed && cb.shouldOpen() {
+		cb.openedAt = cb.now()
+		cb.setState(StateOpen)
+	}
+}
+
+// refreshState moves an open breaker to half-open once the cooldown has elapsed.
+func (cb *CircuitBreaker) refreshState() {
+	if cb.state == StateOpen && cb.now().Sub(cb.openedAt) >= cb.config.Cooldown {
+		cb.setState(StateHalfOpen)
+	}
+}
+
+func (cb *CircuitBreaker) shouldOpen() bool {
+	var successes, failures int
+	cutoff := cb.now().Add(-cb.config.Window)
+	for _, b := range cb.buckets {
+		if b.start.After(cutoff) {
+			successes += b.successes
+			failures += b.failures
+		}
+	}
+
+	total := successes + failures
+	if total < cb.config.MinRequests {
+		return false
+	}
+	return float64(failures)/float64(total) >= cb.config.FailureThreshold
+}
+
+func (cb *CircuitBreaker) currentBucket() *bucket {
+	bucketSize := cb.config.Window / numBuckets
+	now := cb.now()
+	start := now.Truncate(bucketSize)
+	b := &cb.buckets[(start.UnixNano()/int64(bucketSize))%numBuckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+func (cb *CircuitBreaker) resetBuckets() {
+	cb.buckets = [numBuckets]bucket{}
+}
+
+func (cb *CircuitBreaker) setState(state State) {
+	if cb.state == state {
+		return
+	}
+	from := cb.state
+	cb.state = state
+	if cb.onStateChange != nil {
+		cb.onStateChange(cb.name, from, state)
+	}
+}
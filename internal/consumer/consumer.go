@@ -0,0 +1,227 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/repository"
+	"order-service/internal/service"
+	"order-service/msgBroker"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	stockReplenishedPrefix = "stock.replenished."
+	pricingUpdatedPrefix   = "pricing.updated."
+
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	defaultDedupTTL     = 24 * time.Hour
+)
+
+// errUnparseable marks messages that can never be processed and are not retried.
+var errUnparseable = errors.New("unparseable message")
+
+// Consumer reads events from Kafka and dispatches them to the order service by key prefix.
+type Consumer struct {
+	Reader       *kafka.Reader
+	OrderService service.OrderService
+	DeadLetter   *kafka.Writer              // Receives messages that could not be processed, nil disables dead-lettering
+	Dedup        repository.CacheRepository // Remembers the IDs of processed events
+	MaxRetries   int
+	RetryBackoff time.Duration
+	DedupTTL     time.Duration // How long a processed event ID is remembered
+}
+
+// NewConsumer creates an order event consumer. Zero values fall back to 3 retries starting
+// 500ms apart and event IDs remembered for 24h. With a nil deadLetter writer, failed messages
+// are left uncommitted.
+func NewConsumer(reader *kafka.Reader, orderService service.OrderService, deadLetter *kafka.Writer, dedup repository.CacheRepository, maxRetries int, retryBackoff time.Duration, dedupTTL time.Duration) *Consumer {
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	if retryBackoff <= 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+	if dedupTTL <= 0 {
+		dedupTTL = defaultDedupTTL
+	}
+
+	return &Consumer{
+		Reader:       reader,
+		OrderService: orderService,
+		DeadLetter:   deadLetter,
+		Dedup:        dedup,
+		MaxRetries:   maxRetries,
+		RetryBackoff: retryBackoff,
+		DedupTTL:     dedupTTL,
+	}
+}
+
+// Start consumes messages until ctx is cancelled. A message is retried up to MaxRetries times,
+// unless it is unparseable. Offsets are committed once a message is processed or dead-lettered,
+// so one bad message does not block its partition.
+func (c *Consumer) Start(ctx context.Context) {
+	for {
+		msg, err := c.Reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Logger.Error().Err(err).Msg("Failed to fetch message from Kafka")
+			continue
+		}
+
+		// Events carrying the request ID of the request that caused them are logged under it.
+		msgCtx := ctx
+		if requestID := headerValue(msg, msgBroker.HeaderRequestID); requestID != "" {
+			msgCtx = log.WithRequestID(ctx, requestID)
+		}
+
+		err = c.process(msgCtx, msg)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger := log.FromContext(msgCtx)
+			logger.Error().Err(err).Str("key", string(msg.Key)).Str("topic", msg.Topic).Int("partition", msg.Partition).Int64("offset", msg.Offset).Msg("Failed to process message")
+
+			if c.DeadLetter == nil {
+				continue
+			}
+			dlqErr := c.publishDeadLetter(msgCtx, msg, err)
+			if dlqErr != nil {
+				logger.Error().Err(dlqErr).Str("key", string(msg.Key)).Int64("offset", msg.Offset).Msg("Failed to publish message to dead-letter topic")
+				continue
+			}
+			logger.Warn().Str("key", string(msg.Key)).Int64("offset", msg.Offset).Msg("Message sent to dead-letter topic")
+		}
+
+		// A message handled while shutting down is still committed, so it is not redelivered.
+		err = c.Reader.CommitMessages(context.WithoutCancel(ctx), msg)
+		if err != nil {
+			log.Logger.Error().Err(err).Str("key", string(msg.Key)).Int64("offset", msg.Offset).Msg("Failed to commit message offset")
+		}
+	}
+}
+
+// process handles msg, retrying failures with a doubling backoff. Unparseable messages fail immediately.
+// An event whose ID was already processed is skipped, so redelivered and duplicated events are no-ops.
+func (c *Consumer) process(ctx context.Context, msg kafka.Message) error {
+	eventID := messageEventID(msg)
+	if eventID != "" {
+		claimed, err := c.Dedup.SetNX(ctx, processedEventKey(eventID), msg.Offset, c.DedupTTL)
+		if err != nil {
+			// Processing the event twice is safer than losing it while Redis is unavailable.
+			log.FromContext(ctx).Warn().Err(err).Str("eventID", eventID).Msg("Failed to check event for duplicates, processing it")
+		} else if !claimed {
+			log.FromContext(ctx).Info().Str("eventID", eventID).Str("key", string(msg.Key)).Msg("Skipping duplicate event")
+			return nil
+		}
+	}
+
+	err := c.handleWithRetry(ctx, msg)
+	if err != nil && eventID != "" {
+		// The event was not applied, so a redelivery must not be skipped as a duplicate.
+		releaseErr := c.Dedup.Delete(context.WithoutCancel(ctx), processedEventKey(eventID))
+		if releaseErr != nil {
+			log.FromContext(ctx).Warn().Err(releaseErr).Str("eventID", eventID).Msg("Failed to release event ID")
+		}
+	}
+	return err
+}
+
+// handleWithRetry handles msg, retrying failures with a doubling backoff. Unparseable messages fail immediately.
+func (c *Consumer) handleWithRetry(ctx context.Context, msg kafka.Message) error {
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := handleMessage(ctx, msg, c.OrderService)
+		if err == nil || errors.Is(err, errUnparseable) || attempt >= c.MaxRetries {
+			return err
+		}
+
+		log.FromContext(ctx).Warn().Err(err).Str("key", string(msg.Key)).Int("attempt", attempt+1).Msg("Retrying message")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// publishDeadLetter writes msg and the reason it failed to the dead-letter topic, keeping the
+// original key and headers.
+func (c *Consumer) publishDeadLetter(ctx context.Context, msg kafka.Message, cause error) error {
+	payload, err := json.Marshal(entity.DeadLetterEvent{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Value:     msg.Value,
+		Error:     cause.Error(),
+		FailedAt:  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode dead-letter event: %w", err)
+	}
+
+	return c.DeadLetter.WriteMessages(ctx, kafka.Message{
+		Key:     msg.Key,
+		Value:   payload,
+		Headers: msg.Headers,
+	})
+}
+
+func handleMessage(ctx context.Context, msg kafka.Message, svc service.OrderService) error {
+	key := string(msg.Key)
+	switch {
+	case strings.HasPrefix(key, stockReplenishedPrefix):
+		var event entity.StockReplenishedEvent
+		err := json.Unmarshal(msg.Value, &event)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errUnparseable, err)
+		}
+		return svc.HandleStockReplenished(ctx, event)
+	case strings.HasPrefix(key, pricingUpdatedPrefix):
+		var event entity.PricingUpdatedEvent
+		err := json.Unmarshal(msg.Value, &event)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errUnparseable, err)
+		}
+		return svc.HandlePricingUpdated(ctx, event)
+	default:
+		return fmt.Errorf("%w: unknown key %q", errUnparseable, key)
+	}
+}
+
+// messageEventID returns the event_id of the event in msg, or "" if it has none or is not JSON.
+func messageEventID(msg kafka.Message) string {
+	var event struct {
+		EventID string `json:"event_id"`
+	}
+	if json.Unmarshal(msg.Value, &event) != nil {
+		return ""
+	}
+	return event.EventID
+}
+
+func processedEventKey(eventID string) string {
+	return fmt.Sprintf("event:processed:%s", eventID)
+}
+
+// headerValue returns the value of the first message header with the given key, or "" if there is none.
+func headerValue(msg kafka.Message, key string) string {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
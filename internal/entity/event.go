@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// StockReplenishedEvent is consumed from the product service when a product's stock is increased.
+type StockReplenishedEvent struct {
+	EventID   string `json:"event_id"` // Identifies the event across redeliveries, duplicates are not detected when empty
+	ProductID int64  `json:"product_id"`
+	Quantity  int64  `json:"quantity"` // Number of units added to the stock
+}
+
+// PricingUpdatedEvent is consumed from the pricing service when a product's price changes.
+type PricingUpdatedEvent struct {
+	EventID   string `json:"event_id"` // Identifies the event across redeliveries, duplicates are not detected when empty
+	ProductID int64  `json:"product_id"`
+}
+
+// DeadLetterEvent is published to the dead-letter topic for a consumed message that could not be processed.
+type DeadLetterEvent struct {
+	Topic     string    `json:"topic"` // Topic the message was consumed from
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       string    `json:"key"`
+	Value     []byte    `json:"value"` // Raw message value, base64 encoded in JSON
+	Error     string    `json:"error"` // Error from the last processing attempt
+	FailedAt  time.Time `json:"failed_at"`
+}
@@ -1,27 +1,126 @@
 package entity
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Order struct {
-	ID              int64          `json:"id"`
-	UserID          int64          `json:"user_id"`
-	ProductRequests []OrderRequest `json:"product_requests"` // List of products in the order
-	Quantity        int            `json:"quantity"`
-	TotalPrice      float64        `json:"total_price"`
-	Status          string         `json:"status"` // e.g., "pending", "completed", "cancelled"
-	HashValue       string         `json:"hash_value"`
+	ID               int64          `json:"id,string"` // Snowflake ID, sent as a JSON string since it does not fit the 53 bits of a JavaScript number
+	UserID           int64          `json:"user_id" validate:"required" gorm:"uniqueIndex:idx_orders_idempotency,priority:2"`
+	TenantID         string         `json:"tenant_id" gorm:"size:64;index;uniqueIndex:idx_orders_idempotency,priority:1"`                                          // Seller the order belongs to, set from the caller's token and never by clients
+	ProductRequests  []OrderRequest `json:"product_requests" validate:"required,min=1,unique=ProductID,dive" gorm:"constraint:OnUpdate:RESTRICT,OnDelete:CASCADE"` // List of products in the order, deleted with it
+	Quantity         int            `json:"quantity"`
+	TotalPrice       float64        `json:"total_price" gorm:"column:total"`                                     // Sum of the line totals, computed from the pricing service
+	Currency         string         `json:"currency" gorm:"size:3"`                                              // ISO 4217 code of the totals, shared by every line
+	Status           string         `json:"status" gorm:"size:50;index:idx_orders_status_created_at,priority:1"` // e.g., "pending", "completed", "cancelled"
+	HashValue        string         `json:"hash_value"`
+	CreatedAt        time.Time      `json:"created_at" gorm:"index;index:idx_orders_status_created_at,priority:2"`
+	UpdatedAt        time.Time      `json:"updated_at"`                                                                   // Set by GORM on every create and update
+	Version          int            `json:"version" gorm:"not null;default:0"`                                            // Starts at 1 and is incremented on every update, used for optimistic locking
+	PaymentReference string         `json:"payment_reference" gorm:"size:255;default:null"`                               // Reference of the payment, set when the order is paid
+	PricingEstimated bool           `json:"pricing_estimated" gorm:"not null;default:false"`                              // Set when a line was priced at its last known price because the pricing service was down
+	AllowBackorder   bool           `json:"allow_backorder" gorm:"not null;default:false"`                                // Lets every line be backordered when its product is short of stock
+	IdempotencyKey   string         `json:"-" gorm:"size:255;uniqueIndex:idx_orders_idempotency,priority:3;default:null"` // Client-supplied key used to deduplicate the caller's retried creates, unique per tenant and user
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`                                                               // Set when the order is soft deleted; soft-deleted orders are excluded from queries
+
+	ReservationExpiresAt *time.Time `json:"reservation_expires_at,omitempty" gorm:"-"` // When an unpaid order expires and its reserved stock is released, unset once it leaves "created"
+}
+
+// OrderPatch holds the order fields a client may change with a partial update.
+// Nil fields are left unchanged.
+type OrderPatch struct {
+	Status  *string `json:"status"`
+	Version *int    `json:"version"` // Version the client read, checked for optimistic locking
+}
+
+// OrderFilter holds the optional criteria used to list orders.
+// Zero values are ignored when building the query.
+type OrderFilter struct {
+	UserID        int64
+	Status        string
+	Limit         int
+	Offset        int
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// OrderCancelFilter selects the orders a bulk cancel applies to. Only orders that may still be
+// cancelled, unpaid orders in the "created" or "released" status, can be matched.
+type OrderCancelFilter struct {
+	Status        string     `json:"status" validate:"omitempty,oneof=created released"` // Status of the orders to cancel, defaults to created
+	CreatedAfter  *time.Time `json:"created_after"`                                      // Only orders created at or after this time
+	CreatedBefore *time.Time `json:"created_before"`                                     // Only orders created before this time
+	ProductID     int64      `json:"product_id" validate:"omitempty,gt=0"`               // Only orders with a line for this product
+}
+
+// OrderCancelFailure is an order a bulk cancel failed to cancel.
+type OrderCancelFailure struct {
+	OrderID int64  `json:"order_id,string"`
+	Error   string `json:"error"`
+}
+
+// OrderCancelResult is the outcome of a bulk cancel.
+type OrderCancelResult struct {
+	Cancelled  int                  `json:"cancelled"`             // Orders cancelled by this call
+	Failed     int                  `json:"failed"`                // Orders that could not be cancelled, retried by running the filter again
+	Failures   []OrderCancelFailure `json:"failures"`              // The first failures, with their cause
+	NextCursor string               `json:"next_cursor,omitempty"` // Set when the call stopped before every match was handled; pass it back as cursor to resume
+}
+
+// OrderCursor is the position of an order in the (created_at, id) order used for cursor pagination.
+type OrderCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        int64     `json:"id"`
+}
+
+// OrderCursorPage is one page of orders listed newest first, together with the cursor of the next page.
+type OrderCursorPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor"` // Opaque cursor of the next page, empty on the last page
+}
+
+// OrderPage is one page of orders together with the total number of orders available.
+type OrderPage struct {
+	Orders []Order `json:"orders"`
+	Total  int64   `json:"total"` // Number of matching orders, ignoring pagination
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+// OrderQuote is the pricing an order would be created with, returned without creating it.
+type OrderQuote struct {
+	ProductRequests  []OrderRequest `json:"product_requests"`  // Lines priced for their quantity
+	TotalPrice       float64        `json:"total_price"`       // Sum of the line totals
+	Currency         string         `json:"currency"`          // ISO 4217 code of the totals
+	PricingEstimated bool           `json:"pricing_estimated"` // Whether a line was priced from the last known price
+}
+
+// PaymentRequest confirms payment of an order.
+type PaymentRequest struct {
+	PaymentReference string `json:"payment_reference" validate:"required,max=255"` // Reference of the payment from the payment provider
 }
 
 type OrderRequest struct {
-	ProductID  int64   `json:"product_id"`
-	Quantity   int64   `json:"quantity"`
-	MarkUp     float64 `json:"markup"`      // Percentage markup on the product price
-	Discount   float64 `json:"discount"`    // Percentage discount on the product price
-	FinalPrice float64 `json:"final_price"` // Final price after applying markup and discount
-	OrderID    int64   `json:"order_id"`
-	HashValue  string  `json:"hash_value"`
-}
-
-type AvailabilityChannel struct {
-	ProductID int64
-	Available bool
-	Error     error
+	ID               int64     `json:"id"`
+	ProductID        int64     `json:"product_id" validate:"required"`
+	Quantity         int64     `json:"quantity" validate:"gt=0"`
+	MarkUp           float64   `json:"markup"`                 // Percentage markup on the product price
+	Discount         float64   `json:"discount"`               // Percentage discount on the product price
+	FinalPrice       float64   `json:"final_price"`            // Final price after applying markup and discount
+	LineTotal        float64   `json:"line_total"`             // FinalPrice multiplied by Quantity
+	Currency         string    `json:"currency" gorm:"size:3"` // ISO 4217 code of the line's prices
+	AllowBackorder   bool      `json:"allow_backorder"`        // Reserve the available stock and backorder the rest when the product is short
+	BackorderedQty   int64     `json:"backordered_qty"`        // Units that could not be reserved and are backordered, set by the service
+	OrderID          int64     `json:"order_id,string"`
+	HashValue        string    `json:"hash_value"`
+	ReservationToken string    `json:"-"` // Token of the stock reservation held on the product service; never read from or written to clients
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName returns the table order lines are stored in.
+func (OrderRequest) TableName() string {
+	return "product_requests"
 }
@@ -0,0 +1,92 @@
+package entity
+
+import "time"
+
+// OrderEventSchemaVersion is the version of the order event envelope and data. It is bumped on
+// any incompatible change so consumers can branch on it.
+const OrderEventSchemaVersion = 2
+
+// Order event types, also used as the prefix of the Kafka message key.
+const (
+	OrderEventCreated   = "order.created"
+	OrderEventUpdated   = "order.updated"
+	OrderEventPaid      = "order.paid"
+	OrderEventCancelled = "order.cancelled"
+	OrderEventExpired   = "order.expired"
+	OrderEventRepriced  = "order.repriced"
+	OrderEventReleased  = "order.released"
+)
+
+// OrderEventEnvelope wraps every order event published to Kafka.
+type OrderEventEnvelope struct {
+	EventID       string         `json:"event_id"` // UUID identifying the event, kept when the event is published more than once
+	SchemaVersion int            `json:"schema_version"`
+	EventType     string         `json:"event_type"` // One of the OrderEvent constants
+	OccurredAt    time.Time      `json:"occurred_at"`
+	Data          OrderEventData `json:"data"`
+}
+
+// OrderEventData is the published view of an order. It is kept separate from Order so internal
+// changes to the entity do not change the event schema.
+type OrderEventData struct {
+	OrderID          int64            `json:"order_id,string"`
+	UserID           int64            `json:"user_id"`
+	TenantID         string           `json:"tenant_id,omitempty"`
+	Status           string           `json:"status"`
+	TotalPrice       float64          `json:"total_price"`
+	Currency         string           `json:"currency"`
+	Version          int              `json:"version"`
+	PaymentReference string           `json:"payment_reference,omitempty"` // Set once the order is paid
+	PricingEstimated bool             `json:"pricing_estimated"`           // Set when the total uses a last known price
+	CreatedAt        time.Time        `json:"created_at"`
+	Items            []OrderEventItem `json:"items"`
+}
+
+// OrderEventItem is the published view of an order line.
+type OrderEventItem struct {
+	ProductID      int64   `json:"product_id"`
+	Quantity       int64   `json:"quantity"`
+	FinalPrice     float64 `json:"final_price"`
+	LineTotal      float64 `json:"line_total"`
+	BackorderedQty int64   `json:"backordered_qty"` // Units of Quantity not reserved and awaiting restock
+}
+
+// NewOrderEventEnvelope builds the envelope for the event identified by eventID, of eventType about
+// order, occurring at occurredAt.
+func NewOrderEventEnvelope(eventID string, eventType string, order *Order, occurredAt time.Time) OrderEventEnvelope {
+	return OrderEventEnvelope{
+		EventID:       eventID,
+		SchemaVersion: OrderEventSchemaVersion,
+		EventType:     eventType,
+		OccurredAt:    occurredAt,
+		Data:          NewOrderEventData(order),
+	}
+}
+
+// NewOrderEventData maps an order to its published view.
+func NewOrderEventData(order *Order) OrderEventData {
+	items := make([]OrderEventItem, 0, len(order.ProductRequests))
+	for _, productRequest := range order.ProductRequests {
+		items = append(items, OrderEventItem{
+			ProductID:      productRequest.ProductID,
+			Quantity:       productRequest.Quantity,
+			FinalPrice:     productRequest.FinalPrice,
+			LineTotal:      productRequest.LineTotal,
+			BackorderedQty: productRequest.BackorderedQty,
+		})
+	}
+
+	return OrderEventData{
+		OrderID:          order.ID,
+		UserID:           order.UserID,
+		TenantID:         order.TenantID,
+		Status:           order.Status,
+		TotalPrice:       order.TotalPrice,
+		Currency:         order.Currency,
+		Version:          order.Version,
+		PaymentReference: order.PaymentReference,
+		PricingEstimated: order.PricingEstimated,
+		CreatedAt:        order.CreatedAt,
+		Items:            items,
+	}
+}
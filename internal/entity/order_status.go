@@ -0,0 +1,35 @@
+package entity
+
+const (
+	OrderStatusCreated   = "created"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusExpired   = "expired"
+	OrderStatusReleased  = "released" // Unpaid order whose stock reservations were given back, kept as a draft
+)
+
+// ValidTransitions lists, for each order status, the statuses it may move to.
+// Statuses without an entry are terminal.
+var ValidTransitions = map[string][]string{
+	OrderStatusCreated:  {OrderStatusPaid, OrderStatusCancelled, OrderStatusExpired, OrderStatusReleased},
+	OrderStatusReleased: {OrderStatusCancelled},
+	OrderStatusPaid:     {OrderStatusShipped},
+	OrderStatusShipped:  {OrderStatusDelivered},
+}
+
+// CanTransition reports whether an order may move from one status to another.
+// Staying in the same status is always allowed.
+func CanTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+
+	for _, allowed := range ValidTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// OrderStatusHistory records one status transition of an order, kept as an audit trail.
+type OrderStatusHistory struct {
+	ID         int64     `json:"id"`
+	OrderID    int64     `json:"order_id,string"`
+	FromStatus string    `json:"from_status"` // Empty for the status the order was created with
+	ToStatus   string    `json:"to_status"`
+	Actor      string    `json:"actor"` // Who made the change, e.g. "user:42", or "system" for background jobs
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName returns the table status transitions are stored in.
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+	OutboxStatusFailed    = "failed"
+)
+
+// OutboxEvent is an event written in the same transaction as the order change it describes,
+// and later published to Kafka by the outbox publisher.
+type OutboxEvent struct {
+	ID            int64      `json:"id"`
+	AggregateID   int64      `json:"aggregate_id"`   // ID of the order the event belongs to
+	EventKey      string     `json:"event_key"`      // Kafka message key
+	Payload       []byte     `json:"payload"`        // Kafka message value
+	Status        string     `json:"status"`         // "pending", "published" or "failed"
+	Attempts      int        `json:"attempts"`       // Failed attempts since the event was last queued
+	TotalAttempts int        `json:"total_attempts"` // Failed attempts over the event's lifetime, including before redrives
+	LastError     string     `json:"last_error"`
+	TraceContext  string     `json:"trace_context"` // W3C trace context of the request that wrote the event, as JSON
+	CreatedAt     time.Time  `json:"created_at"`
+	PublishedAt   *time.Time `json:"published_at"`
+}
+
+// TableName returns the table outbox events are stored in.
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
@@ -5,6 +5,16 @@ type Pricing struct {
 	MarkUp     float64 `json:"markup"`      // Percentage markup on the product price
 	Discount   float64 `json:"discount"`    // Percentage discount on the product price
 	FinalPrice float64 `json:"final_price"` // Final price after applying markup and discount
+	Currency   string  `json:"currency"`    // ISO 4217 code of the prices, e.g. USD
+	Estimated  bool    `json:"-"`           // Set when this is the last known pricing, served while the pricing service is down
+}
+
+// PricingLookup is the pricing the service would apply to a product, with where it was served from.
+type PricingLookup struct {
+	Pricing    Pricing `json:"pricing"`      // Pricing applied to new orders of the product
+	CacheHit   bool    `json:"cache_hit"`    // Whether the pricing was served from the Redis cache
+	CacheAgeMs int64   `json:"cache_age_ms"` // How long ago the cached pricing was fetched, zero on a cache miss
+	Estimated  bool    `json:"estimated"`    // Whether this is the last known pricing, served while the pricing service is down
 }
 
 type PricingChannel struct {
@@ -12,5 +22,7 @@ type PricingChannel struct {
 	FinalPrice float64
 	MarkUp     float64
 	Discount   float64
+	Currency   string
+	Estimated  bool
 	Error      error
 }
@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// OrderReceipt is a printable summary of an order, computed from the pricing stored on its lines.
+// Amounts are rounded to cents and the totals are the sums of the rounded line amounts.
+type OrderReceipt struct {
+	OrderID          int64         `json:"order_id,string"`
+	Status           string        `json:"status"`
+	Currency         string        `json:"currency"` // ISO 4217 code of every amount
+	Lines            []ReceiptLine `json:"lines"`
+	Subtotal         float64       `json:"subtotal"`                    // Lines at their product price, before markup and discount
+	TotalMarkup      float64       `json:"total_markup"`                // Markup added across every line
+	TotalDiscount    float64       `json:"total_discount"`              // Discount taken off across every line
+	GrandTotal       float64       `json:"grand_total"`                 // Subtotal plus markup minus discount, what the customer pays
+	PricingEstimated bool          `json:"pricing_estimated"`           // Whether a line was priced from the last known price
+	PaymentReference string        `json:"payment_reference,omitempty"` // Set once the order is paid
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+}
+
+// ReceiptLine is one line item of an OrderReceipt.
+type ReceiptLine struct {
+	ProductID      int64   `json:"product_id"`
+	Quantity       int64   `json:"quantity"`
+	BasePrice      float64 `json:"base_price"`       // Unit product price before markup and discount
+	UnitFinalPrice float64 `json:"unit_final_price"` // Unit price after markup and discount
+	Markup         float64 `json:"markup"`           // Markup added to the line
+	Discount       float64 `json:"discount"`         // Discount taken off the line
+	LineTotal      float64 `json:"line_total"`       // UnitFinalPrice multiplied by Quantity
+}
@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+const (
+	WebhookStatusPending   = "pending"
+	WebhookStatusDelivered = "delivered"
+	WebhookStatusFailed    = "failed"
+)
+
+// WebhookDelivery is one order event queued for delivery to one webhook subscriber.
+type WebhookDelivery struct {
+	ID            int64      `json:"id"`
+	Subscriber    string     `json:"subscriber"` // Name of the subscriber the event is delivered to
+	EventKey      string     `json:"event_key"`
+	EventType     string     `json:"event_type"`
+	Payload       []byte     `json:"payload"` // Event envelope, POSTed as the request body
+	Status        string     `json:"status"`  // "pending", "delivered" or "failed"
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"` // Earliest time of the next delivery attempt
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+}
+
+// TableName returns the table webhook deliveries are stored in.
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,79 @@
+package expiry
+
+import (
+	"context"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/service"
+	"time"
+)
+
+const (
+	defaultInterval  = 1 * time.Minute
+	defaultTTL       = service.DefaultReservationTTL
+	defaultBatchSize = 100
+)
+
+// Worker expires orders that stay unpaid for longer than the TTL, releasing the stock they hold.
+type Worker struct {
+	OrderService service.OrderService
+	Interval     time.Duration
+	TTL          time.Duration
+	BatchSize    int
+}
+
+// NewWorker creates an order expiration worker. Zero values fall back to checking every minute
+// for orders unpaid for 15 minutes, in batches of 100 orders.
+func NewWorker(orderService service.OrderService, interval, ttl time.Duration, batchSize int) *Worker {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &Worker{
+		OrderService: orderService,
+		Interval:     interval,
+		TTL:          ttl,
+		BatchSize:    batchSize,
+	}
+}
+
+// Start expires unpaid orders on every interval until ctx is cancelled.
+func (w *Worker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain walks the unpaid orders past the TTL batch by batch until the backlog is exhausted,
+// backing off to the next interval as soon as a batch fails. Orders that fail to expire are
+// stepped over and retried on the next interval.
+func (w *Worker) drain(ctx context.Context) {
+	olderThan := time.Now().Add(-w.TTL)
+	var after *entity.OrderCursor
+	for ctx.Err() == nil {
+		_, next, err := w.OrderService.ExpireOrders(ctx, olderThan, after, w.BatchSize)
+		if err != nil {
+			log.Logger.Error().Err(err).Msg("Failed to expire unpaid orders")
+			return
+		}
+		if next == nil {
+			return
+		}
+		after = next
+	}
+}
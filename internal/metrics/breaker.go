@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"order-service/internal/breaker"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// CircuitBreakerTransitions counts state transitions of the downstream circuit breakers.
+var CircuitBreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "circuit_breaker_transitions_total",
+	Help: "Number of circuit breaker state transitions, by service.",
+}, []string{"service", "from", "to"})
+
+var circuitBreakerStates = &breakerCollector{
+	desc: prometheus.NewDesc(
+		"circuit_breaker_state",
+		"Current state of each circuit breaker, 1 for the state it is in and 0 for the others.",
+		[]string{"service", "state"}, nil,
+	),
+	breakers: make(map[string]*breaker.CircuitBreaker),
+}
+
+func init() {
+	prometheus.MustRegister(circuitBreakerStates)
+}
+
+// RegisterCircuitBreaker exports the state of cb as circuit_breaker_state and counts its transitions.
+// The state is read on every scrape, so a breaker whose cooldown elapsed is reported half-open even
+// before the next call reaches it. A breaker registered under the name of an earlier one replaces it.
+func RegisterCircuitBreaker(cb *breaker.CircuitBreaker) {
+	circuitBreakerStates.mu.Lock()
+	defer circuitBreakerStates.mu.Unlock()
+
+	circuitBreakerStates.breakers[cb.Name()] = cb
+	// Transitions are initialized so that alerts on increase() see the first one.
+	for _, from := range breakerStates {
+		for _, to := range breakerStates {
+			if from != to {
+				CircuitBreakerTransitions.WithLabelValues(cb.Name(), from.String(), to.String())
+			}
+		}
+	}
+}
+
+var breakerStates = []breaker.State{breaker.StateClosed, breaker.StateOpen, breaker.StateHalfOpen}
+
+// breakerCollector reports the state of the registered breakers when scraped.
+type breakerCollector struct {
+	desc     *prometheus.Desc
+	mu       sync.Mutex
+	breakers map[string]*breaker.CircuitBreaker
+}
+
+func (c *breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, cb := range c.breakers {
+		current := cb.State()
+		for _, state := range breakerStates {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, name, state.String())
+		}
+	}
+}
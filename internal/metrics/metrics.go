@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// OrdersTotal counts orders entering each status.
+	OrdersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_total",
+		Help: "Number of orders that entered each status.",
+	}, []string{"status"})
+
+	// CreateOrderDuration tracks the end-to-end latency of creating an order,
+	// including stock reservation, pricing and the database transaction.
+	CreateOrderDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "create_order_duration_seconds",
+		Help:    "End-to-end latency of creating an order.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// DownstreamRequestDuration tracks the latency of calls to downstream services, retries included.
+	DownstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "downstream_request_duration_seconds",
+		Help:    "Latency of calls to downstream services, including retries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service"})
+
+	// DownstreamRequestErrors counts calls to downstream services that failed with a network error,
+	// a 5xx response or an open circuit breaker.
+	DownstreamRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "downstream_request_errors_total",
+		Help: "Number of failed calls to downstream services.",
+	}, []string{"service"})
+
+	// DownstreamConnectionsTotal counts connections obtained for downstream calls, by whether
+	// an idle keep-alive connection was reused or a new one had to be dialled.
+	DownstreamConnectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "downstream_connections_total",
+		Help: "Number of connections obtained for downstream calls, by host and reuse.",
+	}, []string{"host", "reused"})
+)
+
+// Handler serves the metrics of the default registry in the Prometheus exposition format.
+func Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.Handler())
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+)
+
+// connectionTransport records, for every request, whether its connection came from the idle pool.
+type connectionTransport struct {
+	next http.RoundTripper
+}
+
+// InstrumentConnections wraps next so each request counts towards DownstreamConnectionsTotal.
+// A low share of reused connections means the pool is too small or connections are not being drained.
+func InstrumentConnections(next http.RoundTripper) http.RoundTripper {
+	return &connectionTransport{next: next}
+}
+
+func (t *connectionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			DownstreamConnectionsTotal.WithLabelValues(host, strconv.FormatBool(info.Reused)).Inc()
+		},
+	}
+	ctx := httptrace.WithClientTrace(req.Context(), trace)
+	return t.next.RoundTrip(req.WithContext(ctx))
+}
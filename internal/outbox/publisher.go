@@ -0,0 +1,271 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/repository"
+	"order-service/internal/webhook"
+	"order-service/msgBroker"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPollInterval = 1 * time.Second
+	defaultBatchSize    = 100
+	defaultMaxAttempts  = 10
+	defaultWorkers      = 1
+)
+
+var tracer = otel.Tracer("order-service/internal/outbox")
+
+// Publisher drains pending outbox events to Kafka.
+type Publisher struct {
+	OutboxRepository repository.OutboxRepository
+	KafkaWriter      *kafka.Writer
+	PollInterval     time.Duration
+	BatchSize        int
+	MaxAttempts      int
+	Workers          int                 // Goroutines draining the outbox, each owning a partition of the orders
+	Webhooks         *webhook.Dispatcher // Queues published events for webhook subscribers, nil when there are none
+	nudges           []chan struct{}     // Wake each worker before its next poll, one per worker
+}
+
+// NewPublisher creates an outbox publisher. Zero values fall back to one worker polling every
+// second in batches of 100 events, giving up on an event after 10 failed attempts.
+func NewPublisher(outboxRepository repository.OutboxRepository, kafkaWriter *kafka.Writer, pollInterval time.Duration, batchSize, maxAttempts, workers int) *Publisher {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	nudges := make([]chan struct{}, workers)
+	for i := range nudges {
+		nudges[i] = make(chan struct{}, 1)
+	}
+
+	return &Publisher{
+		OutboxRepository: outboxRepository,
+		KafkaWriter:      kafkaWriter,
+		PollInterval:     pollInterval,
+		BatchSize:        batchSize,
+		MaxAttempts:      maxAttempts,
+		Workers:          workers,
+		nudges:           nudges,
+	}
+}
+
+// WithWebhooks queues every published event for delivery to the dispatcher's webhook subscribers.
+func (p *Publisher) WithWebhooks(dispatcher *webhook.Dispatcher) *Publisher {
+	p.Webhooks = dispatcher
+	return p
+}
+
+// Start runs Workers goroutines that poll the outbox and publish pending events until ctx is
+// cancelled, and returns once they have all stopped. Events are split between the workers by order,
+// and each partition is drained by one worker across all instances at a time, so the events of one
+// order are still published in the order they were written. Every instance must run the same number
+// of workers for the partitions to line up.
+func (p *Publisher) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for partition := range p.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.run(ctx, partition)
+		}()
+	}
+	wg.Wait()
+}
+
+// Flush makes one last pass over every partition, publishing the events still pending once Start
+// has returned on shutdown. It returns when the outbox is drained or ctx is done.
+func (p *Publisher) Flush(ctx context.Context) {
+	var wg sync.WaitGroup
+	for partition := range p.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.drain(ctx, partition)
+		}()
+	}
+	wg.Wait()
+}
+
+// run polls the outbox and publishes the pending events of one partition until ctx is cancelled.
+func (p *Publisher) run(ctx context.Context, partition int) {
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		p.drain(ctx, partition)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.nudges[partition]:
+		}
+	}
+}
+
+// Redrive moves the failed events that have failed fewer than maxTotalAttempts times over their
+// lifetime back to pending, and wakes the workers to publish them without waiting for the next poll.
+//
+// Parameters:
+//   - maxTotalAttempts: Only events with fewer lifetime attempts are requeued.
+//
+// Returns:
+//   - The number of events requeued.
+//   - An error if the events cannot be requeued.
+func (p *Publisher) Redrive(ctx context.Context, maxTotalAttempts int) (int64, error) {
+	requeued, err := p.OutboxRepository.RequeueFailedOutboxEvents(ctx, maxTotalAttempts)
+	if err != nil {
+		return 0, err
+	}
+
+	if requeued > 0 {
+		log.FromContext(ctx).Info().Int64("requeued", requeued).Msg("Requeued failed outbox events")
+		p.nudge()
+	}
+	return requeued, nil
+}
+
+// CountFailed returns the number of events that gave up publishing.
+func (p *Publisher) CountFailed(ctx context.Context) (int64, error) {
+	return p.OutboxRepository.CountFailedOutboxEvents(ctx)
+}
+
+// nudge wakes every worker to drain the outbox now. A worker that is already draining or
+// already nudged is not woken twice.
+func (p *Publisher) nudge() {
+	for _, nudge := range p.nudges {
+		select {
+		case nudge <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// drain publishes the pending events of a partition batch by batch until it is empty,
+// backing off to the next poll as soon as a batch has a failure.
+func (p *Publisher) drain(ctx context.Context, partition int) {
+	for ctx.Err() == nil {
+		read, err := p.publishBatch(ctx, partition)
+		if err != nil || read < p.BatchSize {
+			return
+		}
+	}
+}
+
+// publishBatch claims one batch of pending events of a partition and publishes it. The events stay
+// locked until their outcome is committed, so publishers of other instances skip them. Once an event
+// fails, the later events of its order in the batch are left pending untouched, so they are not
+// published ahead of it.
+// It returns how many events were read and the last error encountered, if any.
+func (p *Publisher) publishBatch(ctx context.Context, partition int) (int, error) {
+	var publishErr error
+	read, err := p.OutboxRepository.ClaimPendingOutboxEvents(ctx, p.BatchSize, partition, p.Workers, func(tx *gorm.DB, events []entity.OutboxEvent) error {
+		failedOrders := make(map[int64]bool)
+		for i := range events {
+			event := &events[i]
+			if failedOrders[event.AggregateID] {
+				continue
+			}
+
+			err := p.publish(ctx, event)
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int64("outboxID", event.ID).Int64("orderID", event.AggregateID).Msg("Failed to publish outbox event to Kafka")
+				_ = p.OutboxRepository.MarkOutboxEventAttemptFailedTx(ctx, tx, event, err, p.MaxAttempts)
+				failedOrders[event.AggregateID] = true
+				publishErr = err
+				continue
+			}
+
+			// A failure to queue webhooks is retried with the event, which may publish it to Kafka again.
+			if p.Webhooks != nil {
+				err = p.Webhooks.Enqueue(ctx, event)
+				if err != nil {
+					log.FromContext(ctx).Error().Err(err).Int64("outboxID", event.ID).Int64("orderID", event.AggregateID).Msg("Failed to queue outbox event for webhooks")
+					_ = p.OutboxRepository.MarkOutboxEventAttemptFailedTx(ctx, tx, event, err, p.MaxAttempts)
+					failedOrders[event.AggregateID] = true
+					publishErr = err
+					continue
+				}
+			}
+
+			_ = p.OutboxRepository.MarkOutboxEventPublishedTx(ctx, tx, event.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return read, err
+	}
+
+	return read, publishErr
+}
+
+// publish writes a single event to Kafka in a producer span that continues the trace of the
+// request which stored the event. The message headers carry the trace context, trace ID, request ID
+// and content type so consumers can join their logs to ours.
+func (p *Publisher) publish(ctx context.Context, event *entity.OutboxEvent) error {
+	var requestID string
+	if event.TraceContext != "" {
+		carrier := propagation.MapCarrier{}
+		if err := json.Unmarshal([]byte(event.TraceContext), &carrier); err == nil {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+			requestID = carrier.Get(log.RequestIDHeader)
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "outbox.publish", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", p.KafkaWriter.Topic),
+		attribute.String("messaging.kafka.message.key", event.EventKey),
+	)
+
+	headerCarrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier)
+	headers := make([]kafka.Header, 0, len(headerCarrier)+3)
+	for key, value := range headerCarrier {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	headers = append(headers, kafka.Header{Key: msgBroker.HeaderContentType, Value: []byte(msgBroker.ContentTypeJSON)})
+	if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+		headers = append(headers, kafka.Header{Key: msgBroker.HeaderTraceID, Value: []byte(spanContext.TraceID().String())})
+	}
+	if requestID != "" {
+		headers = append(headers, kafka.Header{Key: msgBroker.HeaderRequestID, Value: []byte(requestID)})
+	}
+
+	err := p.KafkaWriter.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(event.EventKey),
+		Value:   event.Payload,
+		Headers: headers,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to publish event")
+	}
+	return err
+}
@@ -0,0 +1,318 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.29.3
+// source: pricing.proto
+
+package pricingpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetPricingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProductId     int64                  `protobuf:"varint,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPricingRequest) Reset() {
+	*x = GetPricingRequest{}
+	mi := &file_pricing_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPricingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPricingRequest) ProtoMessage() {}
+
+func (x *GetPricingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pricing_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPricingRequest.ProtoReflect.Descriptor instead.
+func (*GetPricingRequest) Descriptor() ([]byte, []int) {
+	return file_pricing_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetPricingRequest) GetProductId() int64 {
+	if x != nil {
+		return x.ProductId
+	}
+	return 0
+}
+
+type GetPricingResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ProductId int64                  `protobuf:"varint,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	// Percentage markup on the product price.
+	Markup float64 `protobuf:"fixed64,2,opt,name=markup,proto3" json:"markup,omitempty"`
+	// Percentage discount on the product price.
+	Discount float64 `protobuf:"fixed64,3,opt,name=discount,proto3" json:"discount,omitempty"`
+	// Final price after applying markup and discount.
+	FinalPrice float64 `protobuf:"fixed64,4,opt,name=final_price,json=finalPrice,proto3" json:"final_price,omitempty"`
+	// ISO 4217 code of the prices, e.g. USD. Empty means the configured base currency.
+	Currency      string `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPricingResponse) Reset() {
+	*x = GetPricingResponse{}
+	mi := &file_pricing_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPricingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPricingResponse) ProtoMessage() {}
+
+func (x *GetPricingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pricing_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPricingResponse.ProtoReflect.Descriptor instead.
+func (*GetPricingResponse) Descriptor() ([]byte, []int) {
+	return file_pricing_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetPricingResponse) GetProductId() int64 {
+	if x != nil {
+		return x.ProductId
+	}
+	return 0
+}
+
+func (x *GetPricingResponse) GetMarkup() float64 {
+	if x != nil {
+		return x.Markup
+	}
+	return 0
+}
+
+func (x *GetPricingResponse) GetDiscount() float64 {
+	if x != nil {
+		return x.Discount
+	}
+	return 0
+}
+
+func (x *GetPricingResponse) GetFinalPrice() float64 {
+	if x != nil {
+		return x.FinalPrice
+	}
+	return 0
+}
+
+func (x *GetPricingResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type GetPricingBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProductIds    []int64                `protobuf:"varint,1,rep,packed,name=product_ids,json=productIds,proto3" json:"product_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPricingBatchRequest) Reset() {
+	*x = GetPricingBatchRequest{}
+	mi := &file_pricing_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPricingBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPricingBatchRequest) ProtoMessage() {}
+
+func (x *GetPricingBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pricing_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPricingBatchRequest.ProtoReflect.Descriptor instead.
+func (*GetPricingBatchRequest) Descriptor() ([]byte, []int) {
+	return file_pricing_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetPricingBatchRequest) GetProductIds() []int64 {
+	if x != nil {
+		return x.ProductIds
+	}
+	return nil
+}
+
+type GetPricingBatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Pricing of every requested product.
+	Pricings      []*GetPricingResponse `protobuf:"bytes,1,rep,name=pricings,proto3" json:"pricings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPricingBatchResponse) Reset() {
+	*x = GetPricingBatchResponse{}
+	mi := &file_pricing_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPricingBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPricingBatchResponse) ProtoMessage() {}
+
+func (x *GetPricingBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pricing_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPricingBatchResponse.ProtoReflect.Descriptor instead.
+func (*GetPricingBatchResponse) Descriptor() ([]byte, []int) {
+	return file_pricing_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetPricingBatchResponse) GetPricings() []*GetPricingResponse {
+	if x != nil {
+		return x.Pricings
+	}
+	return nil
+}
+
+var File_pricing_proto protoreflect.FileDescriptor
+
+const file_pricing_proto_rawDesc = "" +
+	"\n" +
+	"\rpricing.proto\x12\n" +
+	"pricing.v1\"2\n" +
+	"\x11GetPricingRequest\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\x03R\tproductId\"\xa4\x01\n" +
+	"\x12GetPricingResponse\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\x03R\tproductId\x12\x16\n" +
+	"\x06markup\x18\x02 \x01(\x01R\x06markup\x12\x1a\n" +
+	"\bdiscount\x18\x03 \x01(\x01R\bdiscount\x12\x1f\n" +
+	"\vfinal_price\x18\x04 \x01(\x01R\n" +
+	"finalPrice\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\"9\n" +
+	"\x16GetPricingBatchRequest\x12\x1f\n" +
+	"\vproduct_ids\x18\x01 \x03(\x03R\n" +
+	"productIds\"U\n" +
+	"\x17GetPricingBatchResponse\x12:\n" +
+	"\bpricings\x18\x01 \x03(\v2\x1e.pricing.v1.GetPricingResponseR\bpricings2\xb9\x01\n" +
+	"\x0ePricingService\x12K\n" +
+	"\n" +
+	"GetPricing\x12\x1d.pricing.v1.GetPricingRequest\x1a\x1e.pricing.v1.GetPricingResponse\x12Z\n" +
+	"\x0fGetPricingBatch\x12\".pricing.v1.GetPricingBatchRequest\x1a#.pricing.v1.GetPricingBatchResponseB%Z#order-service/internal/pb/pricingpbb\x06proto3"
+
+var (
+	file_pricing_proto_rawDescOnce sync.Once
+	file_pricing_proto_rawDescData []byte
+)
+
+func file_pricing_proto_rawDescGZIP() []byte {
+	file_pricing_proto_rawDescOnce.Do(func() {
+		file_pricing_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pricing_proto_rawDesc), len(file_pricing_proto_rawDesc)))
+	})
+	return file_pricing_proto_rawDescData
+}
+
+var file_pricing_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_pricing_proto_goTypes = []any{
+	(*GetPricingRequest)(nil),       // 0: pricing.v1.GetPricingRequest
+	(*GetPricingResponse)(nil),      // 1: pricing.v1.GetPricingResponse
+	(*GetPricingBatchRequest)(nil),  // 2: pricing.v1.GetPricingBatchRequest
+	(*GetPricingBatchResponse)(nil), // 3: pricing.v1.GetPricingBatchResponse
+}
+var file_pricing_proto_depIdxs = []int32{
+	1, // 0: pricing.v1.GetPricingBatchResponse.pricings:type_name -> pricing.v1.GetPricingResponse
+	0, // 1: pricing.v1.PricingService.GetPricing:input_type -> pricing.v1.GetPricingRequest
+	2, // 2: pricing.v1.PricingService.GetPricingBatch:input_type -> pricing.v1.GetPricingBatchRequest
+	1, // 3: pricing.v1.PricingService.GetPricing:output_type -> pricing.v1.GetPricingResponse
+	3, // 4: pricing.v1.PricingService.GetPricingBatch:output_type -> pricing.v1.GetPricingBatchResponse
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_pricing_proto_init() }
+func file_pricing_proto_init() {
+	if File_pricing_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pricing_proto_rawDesc), len(file_pricing_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pricing_proto_goTypes,
+		DependencyIndexes: file_pricing_proto_depIdxs,
+		MessageInfos:      file_pricing_proto_msgTypes,
+	}.Build()
+	File_pricing_proto = out.File
+	file_pricing_proto_goTypes = nil
+	file_pricing_proto_depIdxs = nil
+}
@@ -4,11 +4,19 @@ import (
 	"context"
 	"errors"
 	"github.com/go-redis/redis/v8"
+	"time"
 )
 
 type CacheRepository interface {
 	Set(ctx context.Context, key string, value interface{}) error
+	SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
 	Get(ctx context.Context, key string) (string, error)
+	// TTL returns how long key has left to live, or zero if it does not exist or never expires.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// DeleteIfEqual deletes key only if it still holds value, reporting whether it was deleted.
+	// The check and delete are atomic, so a lock is never released by a caller that no longer owns it.
+	DeleteIfEqual(ctx context.Context, key string, value string) (bool, error)
 	Delete(ctx context.Context, key string) error
 }
 
@@ -30,6 +38,22 @@ func (r *cacheRepository) Set(ctx context.Context, key string, value interface{}
 	return nil
 }
 
+func (r *cacheRepository) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	err := r.rdb.Set(ctx, key, value, ttl).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *cacheRepository) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	ok, err := r.rdb.SetNX(ctx, key, value, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
 func (r *cacheRepository) Get(ctx context.Context, key string) (string, error) {
 	value, err := r.rdb.Get(ctx, key).Result()
 	if err != nil {
@@ -41,6 +65,18 @@ func (r *cacheRepository) Get(ctx context.Context, key string) (string, error) {
 	return value, nil
 }
 
+func (r *cacheRepository) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Redis reports a missing key as -2 and a key without expiry as -1.
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
 func (r *cacheRepository) Delete(ctx context.Context, key string) error {
 	err := r.rdb.Del(ctx, key).Err()
 	if err != nil {
@@ -48,3 +84,19 @@ func (r *cacheRepository) Delete(ctx context.Context, key string) error {
 	}
 	return nil
 }
+
+// compareAndDeleteScript deletes KEYS[1] only if its value equals ARGV[1].
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r *cacheRepository) DeleteIfEqual(ctx context.Context, key string, value string) (bool, error) {
+	deleted, err := compareAndDeleteScript.Run(ctx, r.rdb, []string{key}, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
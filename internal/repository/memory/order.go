@@ -0,0 +1,472 @@
+// Package memory provides in-memory implementations of the repository interfaces, so code that
+// depends on them can be exercised in tests without a database.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"order-service/internal/entity"
+	"order-service/internal/repository"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// orderRepository is a map-backed OrderRepository. It keeps every order on a single shard and
+// mirrors the database implementation's results: missing orders are returned as nil, versioned
+// updates fail with repository.ErrVersionConflict, soft-deleted orders are hidden from every read
+// and reads are scoped to the tenant set with repository.WithTenant.
+//
+// Transactions are no-ops: WithTransaction passes a nil *gorm.DB to fn, and the Tx methods ignore
+// it and write straight to the maps, so writes made before fn returns an error are not rolled back.
+type orderRepository struct {
+	mu        sync.Mutex
+	nextID    int64
+	orders    map[int64]*entity.Order               // Orders by ID, without their product requests
+	requests  map[int64][]entity.OrderRequest       // Product requests by order ID
+	history   map[int64][]entity.OrderStatusHistory // Status transitions by order ID, oldest first
+	deleted   map[int64]bool                        // IDs of soft-deleted orders
+	historyID int64
+	requestID int64
+}
+
+// NewOrderRepository creates an empty in-memory OrderRepository.
+//
+// Returns:
+//   - An instance of OrderRepository.
+func NewOrderRepository() repository.OrderRepository {
+	return &orderRepository{
+		orders:   make(map[int64]*entity.Order),
+		requests: make(map[int64][]entity.OrderRequest),
+		history:  make(map[int64][]entity.OrderStatusHistory),
+		deleted:  make(map[int64]bool),
+	}
+}
+
+func (r *orderRepository) NewOrderID() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	return r.nextID
+}
+
+func (r *orderRepository) ShardOf(orderID int64) int {
+	return 0
+}
+
+// WithTransaction calls fn with a nil transaction. Errors returned by fn are passed through.
+func (r *orderRepository) WithTransaction(ctx context.Context, shardKey int64, fn func(tx *gorm.DB) error) error {
+	return fn(nil)
+}
+
+// WithSavepoint calls fn with the given transaction. Errors returned by fn are passed through.
+func (r *orderRepository) WithSavepoint(ctx context.Context, tx *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	return fn(tx)
+}
+
+// visible returns the stored order with the given ID if ctx may see it. The caller must hold r.mu.
+func (r *orderRepository) visible(ctx context.Context, id int64) *entity.Order {
+	order, ok := r.orders[id]
+	if !ok || r.deleted[id] {
+		return nil
+	}
+	if tenantID := repository.TenantFromContext(ctx); tenantID != "" && order.TenantID != tenantID {
+		return nil
+	}
+	return order
+}
+
+// header returns a copy of order without its product requests.
+func header(order *entity.Order) entity.Order {
+	copied := *order
+	copied.ProductRequests = nil
+	return copied
+}
+
+// withRequests returns a copy of order with a copy of its product requests. The caller must hold r.mu.
+func (r *orderRepository) withRequests(order *entity.Order) entity.Order {
+	copied := header(order)
+	copied.ProductRequests = append([]entity.OrderRequest{}, r.requests[order.ID]...)
+	return copied
+}
+
+func (r *orderRepository) GetOrderByID(ctx context.Context, id int64) (*entity.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.visible(ctx, id)
+	if order == nil {
+		return nil, nil
+	}
+	copied := r.withRequests(order)
+	return &copied, nil
+}
+
+func (r *orderRepository) GetOrderHeaderByID(ctx context.Context, id int64) (*entity.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.visible(ctx, id)
+	if order == nil {
+		return nil, nil
+	}
+	copied := header(order)
+	return &copied, nil
+}
+
+func (r *orderRepository) GetOrderByIdempotencyKey(ctx context.Context, userID int64, key string) (*entity.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := repository.TenantFromContext(ctx)
+	for id, order := range r.orders {
+		if order.TenantID == tenantID && order.UserID == userID && order.IdempotencyKey == key && r.visible(ctx, id) != nil {
+			copied := r.withRequests(order)
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *orderRepository) GetOrderRequests(ctx context.Context, orderID int64) ([]entity.OrderRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]entity.OrderRequest{}, r.requests[orderID]...), nil
+}
+
+// CreateOrder stores the order together with its product requests, assigning an ID if it has none.
+func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+	if order.ID == 0 {
+		order.ID = r.NewOrderID()
+	}
+
+	err := r.CreateOrderTx(ctx, nil, order)
+	if err != nil {
+		return nil, err
+	}
+	for i := range order.ProductRequests {
+		order.ProductRequests[i].OrderID = order.ID
+	}
+	err = r.CreateOrderRequestTx(ctx, nil, order.ProductRequests)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CreateOrderTx stores the order without its product requests, which are written separately with
+// CreateOrderRequestTx. Unset timestamps are set like GORM does.
+func (r *orderRepository) CreateOrderTx(ctx context.Context, tx *gorm.DB, order *entity.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.orders[order.ID]; ok {
+		return fmt.Errorf("%w: order %d", gorm.ErrDuplicatedKey, order.ID)
+	}
+	if order.IdempotencyKey != "" {
+		for _, existing := range r.orders {
+			if existing.TenantID == order.TenantID && existing.UserID == order.UserID && existing.IdempotencyKey == order.IdempotencyKey {
+				return fmt.Errorf("%w: idempotency key %q", gorm.ErrDuplicatedKey, order.IdempotencyKey)
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = now
+	}
+	if order.UpdatedAt.IsZero() {
+		order.UpdatedAt = now
+	}
+	stored := header(order)
+	r.orders[order.ID] = &stored
+	return nil
+}
+
+func (r *orderRepository) CreateOrderRequestTx(ctx context.Context, tx *gorm.DB, orderRequests []entity.OrderRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	for i := range orderRequests {
+		orderRequest := &orderRequests[i]
+		if _, ok := r.orders[orderRequest.OrderID]; !ok {
+			return fmt.Errorf("%w: order %d of product request", gorm.ErrForeignKeyViolated, orderRequest.OrderID)
+		}
+		if orderRequest.ID == 0 {
+			r.requestID++
+			orderRequest.ID = r.requestID
+		}
+		if orderRequest.CreatedAt.IsZero() {
+			orderRequest.CreatedAt = now
+		}
+		if orderRequest.UpdatedAt.IsZero() {
+			orderRequest.UpdatedAt = now
+		}
+		r.requests[orderRequest.OrderID] = append(r.requests[orderRequest.OrderID], *orderRequest)
+	}
+	return nil
+}
+
+func (r *orderRepository) UpdateOrderRequestPricingTx(ctx context.Context, tx *gorm.DB, orderRequests []entity.OrderRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, orderRequest := range orderRequests {
+		stored := r.requests[orderRequest.OrderID]
+		for i := range stored {
+			if stored[i].ID != orderRequest.ID {
+				continue
+			}
+			stored[i].MarkUp = orderRequest.MarkUp
+			stored[i].Discount = orderRequest.Discount
+			stored[i].FinalPrice = orderRequest.FinalPrice
+			stored[i].LineTotal = orderRequest.LineTotal
+			stored[i].Currency = orderRequest.Currency
+			stored[i].UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+func (r *orderRepository) CreateStatusHistoryTx(ctx context.Context, tx *gorm.DB, entry *entity.OrderStatusHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.historyID++
+	entry.ID = r.historyID
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	r.history[entry.OrderID] = append(r.history[entry.OrderID], *entry)
+	return nil
+}
+
+func (r *orderRepository) UpdateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+	err := r.UpdateOrderTx(ctx, nil, order)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// UpdateOrderTx saves the order if its stored version still matches order.Version, incrementing the
+// version, and returns repository.ErrVersionConflict otherwise. Like the database implementation it
+// keeps the stored tenant, creation time, idempotency key and product requests.
+func (r *orderRepository) UpdateOrderTx(ctx context.Context, tx *gorm.DB, order *entity.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.visible(ctx, order.ID)
+	if stored == nil || stored.Version != order.Version {
+		return repository.ErrVersionConflict
+	}
+
+	order.Version++
+	order.UpdatedAt = time.Now().UTC()
+	updated := header(order)
+	updated.TenantID = stored.TenantID
+	updated.CreatedAt = stored.CreatedAt
+	updated.IdempotencyKey = stored.IdempotencyKey
+	r.orders[order.ID] = &updated
+	return nil
+}
+
+func (r *orderRepository) UpdateOrderStatusTx(ctx context.Context, tx *gorm.DB, id int64, from, to string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order := r.visible(ctx, id)
+	if order == nil || order.Status != from {
+		return false, nil
+	}
+	order.Status = to
+	order.Version++
+	order.UpdatedAt = time.Now().UTC()
+	return true, nil
+}
+
+func (r *orderRepository) DeleteOrder(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.visible(ctx, id) == nil {
+		return gorm.ErrRecordNotFound
+	}
+	r.deleted[id] = true
+	return nil
+}
+
+// PurgeOrder removes the order with its product requests and status history, soft-deleted or not.
+func (r *orderRepository) PurgeOrder(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if tenantID := repository.TenantFromContext(ctx); tenantID != "" && order.TenantID != tenantID {
+		return gorm.ErrRecordNotFound
+	}
+
+	delete(r.orders, id)
+	delete(r.requests, id)
+	delete(r.history, id)
+	delete(r.deleted, id)
+	return nil
+}
+
+// matching returns a copy of every order ctx may see that satisfies match, without product requests.
+// The caller must hold r.mu.
+func (r *orderRepository) matching(ctx context.Context, match func(order *entity.Order) bool) []entity.Order {
+	orders := []entity.Order{}
+	for id := range r.orders {
+		order := r.visible(ctx, id)
+		if order != nil && match(order) {
+			orders = append(orders, header(order))
+		}
+	}
+	return orders
+}
+
+func (r *orderRepository) ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := r.matching(ctx, func(order *entity.Order) bool {
+		return (filter.UserID == 0 || order.UserID == filter.UserID) &&
+			(filter.Status == "" || order.Status == filter.Status) &&
+			(filter.CreatedAfter == nil || !order.CreatedAt.Before(*filter.CreatedAfter)) &&
+			(filter.CreatedBefore == nil || order.CreatedAt.Before(*filter.CreatedBefore))
+	})
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID > orders[j].ID
+	})
+
+	return page(orders, filter.Offset, filter.Limit), int64(len(orders)), nil
+}
+
+func (r *orderRepository) ListOrdersByCursor(ctx context.Context, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := r.matching(ctx, func(order *entity.Order) bool {
+		return after == nil || order.CreatedAt.Before(after.CreatedAt) ||
+			(order.CreatedAt.Equal(after.CreatedAt) && order.ID < after.ID)
+	})
+	sortNewestFirst(orders)
+
+	return page(orders, 0, limit), nil
+}
+
+func (r *orderRepository) GetOrdersByUserID(ctx context.Context, userID int64, limit, offset int) (*entity.OrderPage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := r.matching(ctx, func(order *entity.Order) bool {
+		return order.UserID == userID
+	})
+	sortNewestFirst(orders)
+
+	result := &entity.OrderPage{Orders: page(orders, offset, limit), Total: int64(len(orders)), Limit: limit, Offset: offset}
+	for i := range result.Orders {
+		result.Orders[i].ProductRequests = append([]entity.OrderRequest{}, r.requests[result.Orders[i].ID]...)
+	}
+	return result, nil
+}
+
+func (r *orderRepository) SumUserProductQuantities(ctx context.Context, userID int64, productIDs []int64) (map[int64]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := r.matching(ctx, func(order *entity.Order) bool {
+		return order.UserID == userID && order.Status != entity.OrderStatusCancelled && order.Status != entity.OrderStatusExpired
+	})
+
+	quantities := make(map[int64]int64)
+	for _, order := range orders {
+		for _, orderRequest := range r.requests[order.ID] {
+			if slices.Contains(productIDs, orderRequest.ProductID) {
+				quantities[orderRequest.ProductID] += orderRequest.Quantity
+			}
+		}
+	}
+	return quantities, nil
+}
+
+func (r *orderRepository) GetOrdersByStatusOlderThan(ctx context.Context, status string, cutoff time.Time, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	return r.GetOrdersByCancelFilter(ctx, entity.OrderCancelFilter{Status: status, CreatedBefore: &cutoff}, after, limit)
+}
+
+func (r *orderRepository) GetOrdersByCancelFilter(ctx context.Context, filter entity.OrderCancelFilter, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := r.matching(ctx, func(order *entity.Order) bool {
+		if order.Status != filter.Status ||
+			(filter.CreatedAfter != nil && order.CreatedAt.Before(*filter.CreatedAfter)) ||
+			(filter.CreatedBefore != nil && !order.CreatedAt.Before(*filter.CreatedBefore)) {
+			return false
+		}
+		if after != nil && !(order.CreatedAt.After(after.CreatedAt) || (order.CreatedAt.Equal(after.CreatedAt) && order.ID > after.ID)) {
+			return false
+		}
+		if filter.ProductID == 0 {
+			return true
+		}
+		return slices.ContainsFunc(r.requests[order.ID], func(orderRequest entity.OrderRequest) bool {
+			return orderRequest.ProductID == filter.ProductID
+		})
+	})
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	orders = page(orders, 0, limit)
+	for i := range orders {
+		orders[i].ProductRequests = append([]entity.OrderRequest{}, r.requests[orders[i].ID]...)
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if repository.TenantFromContext(ctx) != "" && r.visible(ctx, orderID) == nil {
+		return []entity.OrderStatusHistory{}, nil
+	}
+	return append([]entity.OrderStatusHistory{}, r.history[orderID]...), nil
+}
+
+// sortNewestFirst orders by (created_at, id) descending, like the cursor listings.
+func sortNewestFirst(orders []entity.Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		}
+		return orders[i].ID > orders[j].ID
+	})
+}
+
+// page returns the orders from offset, at most limit of them.
+func page(orders []entity.Order, offset, limit int) []entity.Order {
+	if offset >= len(orders) {
+		return []entity.Order{}
+	}
+	end := len(orders)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return orders[offset:end]
+}
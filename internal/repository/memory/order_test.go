@@ -0,0 +1,68 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"order-service/internal/entity"
+	"order-service/internal/repository"
+	"order-service/internal/repository/memory"
+	"sync"
+	"testing"
+)
+
+func TestUpdateOrderTxRejectsConcurrentWriterAtSameVersion(t *testing.T) {
+	orderRepository := memory.NewOrderRepository()
+	ctx := context.Background()
+
+	created, err := orderRepository.CreateOrder(ctx, &entity.Order{UserID: 1, Status: entity.OrderStatusCreated})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	// Both writers read the order at the same version before either saves.
+	first, err := orderRepository.GetOrderByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	second := *first
+	first.Status = entity.OrderStatusPaid
+	second.Status = entity.OrderStatusCancelled
+
+	start := make(chan struct{})
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, order := range []*entity.Order{first, &second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = orderRepository.UpdateOrderTx(ctx, nil, order)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded, conflicted := -1, -1
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded = i
+		case errors.Is(err, repository.ErrVersionConflict):
+			conflicted = i
+		default:
+			t.Fatalf("writer %d: unexpected error %v", i, err)
+		}
+	}
+	if succeeded == -1 || conflicted == -1 {
+		t.Fatalf("want one writer to succeed and one to get ErrVersionConflict, got %v", errs)
+	}
+
+	stored, err := orderRepository.GetOrderByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	winner := []*entity.Order{first, &second}[succeeded]
+	if stored.Status != winner.Status || stored.Version != created.Version+1 {
+		t.Errorf("stored status %q at version %d, want %q at version %d", stored.Status, stored.Version, winner.Status, created.Version+1)
+	}
+}
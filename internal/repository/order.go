@@ -1,18 +1,45 @@
 package repository
 
 import (
+	"container/heap"
 	"context"
 	"errors"
+	"fmt"
 	"order-service/infrastructure/log"
 	"order-service/internal/entity"
+	"order-service/internal/sharding"
+	"sort"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var tracer = otel.Tracer("order-service/internal/repository")
+
+// ErrVersionConflict is returned when an order was changed by someone else since it was read.
+var ErrVersionConflict = errors.New("order version conflict")
+
+// ErrSavepoint is returned when a savepoint cannot be set or rolled back to, leaving the
+// transaction in a state that must be rolled back as a whole.
+var ErrSavepoint = errors.New("savepoint failed")
+
+// ErrOffsetTooLarge is returned when an offset-paginated listing asks for a page past MaxListOffset.
+var ErrOffsetTooLarge = errors.New("offset too large")
+
+// MaxListOffset is the largest offset accepted by the offset-paginated listings. Every shard reads
+// offset+limit rows for them, so deeper pages must use the keyset cursor of ListAllOrders instead.
+const MaxListOffset = 10000
+
 // OrderRepository defines the interface for managing orders in the repository layer.
 // It provides methods to retrieve, create, update, and delete orders.
 type OrderRepository interface {
-	// GetOrderByID retrieves an order by its ID.
+	// GetOrderByID retrieves an order by its ID together with its product requests.
+	// It reads from the shard's replica unless ctx is marked with WithPrimary.
 	//
 	// Parameters:
 	//   - id: The unique identifier of the order to retrieve.
@@ -22,6 +49,41 @@ type OrderRepository interface {
 	//   - An error if the retrieval process fails or the order is not found.
 	GetOrderByID(ctx context.Context, id int64) (*entity.Order, error)
 
+	// GetOrderHeaderByID retrieves an order by its ID without its product requests, for callers
+	// that only need the order's own fields. It reads from the shard's replica unless ctx is marked
+	// with WithPrimary.
+	//
+	// Parameters:
+	//   - id: The unique identifier of the order to retrieve.
+	//
+	// Returns:
+	//   - A pointer to the Order entity if found, with ProductRequests left nil, or nil if not found.
+	//   - An error if the retrieval process fails.
+	GetOrderHeaderByID(ctx context.Context, id int64) (*entity.Order, error)
+
+	// GetOrderByIdempotencyKey retrieves an order by the idempotency key it was created with.
+	// Keys are scoped to the tenant of ctx and the user, so another caller's key never matches.
+	//
+	// Parameters:
+	//   - userID: The ID of the user who created the order.
+	//   - key: The idempotency key supplied when the order was created.
+	//
+	// Returns:
+	//   - A pointer to the Order entity if found, or nil if the user created no order with the key.
+	//   - An error if the retrieval process fails.
+	GetOrderByIdempotencyKey(ctx context.Context, userID int64, key string) (*entity.Order, error)
+
+	// GetOrderRequests retrieves the product requests of an order.
+	// It reads from the shard's replica unless ctx is marked with WithPrimary.
+	//
+	// Parameters:
+	//   - orderID: The ID of the order whose product requests are retrieved.
+	//
+	// Returns:
+	//   - A slice of the order's OrderRequest entities, empty if it has none.
+	//   - An error if the retrieval process fails.
+	GetOrderRequests(ctx context.Context, orderID int64) ([]entity.OrderRequest, error)
+
 	// CreateOrder creates a new order in the repository.
 	//
 	// Parameters:
@@ -32,7 +94,8 @@ type OrderRepository interface {
 	//   - An error if the creation process fails.
 	CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error)
 
-	// UpdateOrder updates an existing order in the repository.
+	// UpdateOrder updates an existing order in the repository if its stored version still matches
+	// order.Version, returning ErrVersionConflict otherwise.
 	//
 	// Parameters:
 	//   - order: A pointer to the Order entity to be updated.
@@ -42,7 +105,8 @@ type OrderRepository interface {
 	//   - An error if the update process fails.
 	UpdateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error)
 
-	// DeleteOrder deletes an order by its ID from the repository.
+	// DeleteOrder soft deletes an order by its ID from the repository.
+	// Soft-deleted orders are kept in storage but excluded from every query.
 	//
 	// Parameters:
 	//   - id: The unique identifier of the order to delete.
@@ -51,28 +115,265 @@ type OrderRepository interface {
 	//   - An error if the deletion process fails or the order is not found.
 	DeleteOrder(ctx context.Context, id int64) error
 
+	// PurgeOrder permanently deletes an order and its product requests, including soft-deleted orders.
+	//
+	// Parameters:
+	//   - id: The unique identifier of the order to purge.
+	//
+	// Returns:
+	//   - An error if the deletion process fails or the order is not found.
+	PurgeOrder(ctx context.Context, id int64) error
+
+	// ListOrders retrieves orders matching the given filter.
+	// It reads from the replicas unless ctx is marked with WithPrimary.
+	//
+	// Parameters:
+	//   - filter: The criteria to filter by, including limit and offset.
+	//
+	// Returns:
+	//   - A slice of Order entities for the requested page.
+	//   - The total number of orders matching the filter, ignoring pagination.
+	//   - An error if the retrieval process fails.
+	ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, int64, error)
+
+	// ListOrdersByCursor retrieves a page of orders from every shard, newest first by (created_at, id),
+	// using keyset pagination so deep pages cost the same as the first.
+	// It reads from the replicas unless ctx is marked with WithPrimary.
+	//
+	// Parameters:
+	//   - after: The position of the last order of the previous page, or nil for the first page.
+	//   - limit: The maximum number of orders to return across all shards.
+	//
+	// Returns:
+	//   - A slice of Order entities, newest first.
+	//   - An error if the retrieval process fails.
+	ListOrdersByCursor(ctx context.Context, after *entity.OrderCursor, limit int) ([]entity.Order, error)
+
+	// GetOrdersByUserID retrieves a page of a user's orders, newest first, together with their product requests.
+	// It reads from the replicas unless ctx is marked with WithPrimary.
+	//
+	// Parameters:
+	//   - userID: The ID of the user whose orders are retrieved.
+	//   - limit: The maximum number of orders to return.
+	//   - offset: The number of orders to skip.
+	//
+	// Returns:
+	//   - The requested page of orders and the user's total number of orders.
+	//   - An error if the retrieval process fails.
+	GetOrdersByUserID(ctx context.Context, userID int64, limit, offset int) (*entity.OrderPage, error)
+
+	// SumUserProductQuantities totals the quantity of each product a user has ordered, across orders
+	// that were neither cancelled nor expired. It reads from the replicas unless ctx is marked with WithPrimary.
+	//
+	// Parameters:
+	//   - userID: The ID of the user whose orders are summed.
+	//   - productIDs: The products to total.
+	//
+	// Returns:
+	//   - The ordered quantity by product ID; products the user never ordered are absent.
+	//   - An error if the retrieval process fails.
+	SumUserProductQuantities(ctx context.Context, userID int64, productIDs []int64) (map[int64]int64, error)
+
+	// GetOrdersByStatusOlderThan retrieves a page of the orders in a status that were created before
+	// cutoff, oldest first by (created_at, id), together with their product requests. It uses keyset
+	// pagination on the (status, created_at) index, so a large backlog is walked page by page
+	// without rescanning the rows already seen.
+	//
+	// Parameters:
+	//   - status: The status of the orders to return.
+	//   - cutoff: Orders created before this time are returned.
+	//   - after: The position of the last order of the previous page, or nil for the first page.
+	//   - limit: The maximum number of orders to return across all shards.
+	//
+	// Returns:
+	//   - A slice of Order entities, oldest first.
+	//   - An error if the retrieval process fails.
+	GetOrdersByStatusOlderThan(ctx context.Context, status string, cutoff time.Time, after *entity.OrderCursor, limit int) ([]entity.Order, error)
+
+	// GetOrdersByCancelFilter retrieves a page of the orders matching a bulk cancel filter, oldest first
+	// by (created_at, id), together with their product requests. Like GetOrdersByStatusOlderThan it
+	// pages with a keyset on the (status, created_at) index.
+	//
+	// Parameters:
+	//   - filter: The status, creation range and product the orders must match. The status must be set.
+	//   - after: The position of the last order of the previous page, or nil for the first page.
+	//   - limit: The maximum number of orders to return across all shards.
+	//
+	// Returns:
+	//   - A slice of Order entities, oldest first.
+	//   - An error if the retrieval process fails.
+	GetOrdersByCancelFilter(ctx context.Context, filter entity.OrderCancelFilter, after *entity.OrderCursor, limit int) ([]entity.Order, error)
+
+	// GetStatusHistory retrieves the status transitions of an order, oldest first.
+	// It reads from the replicas unless ctx is marked with WithPrimary.
+	//
+	// Parameters:
+	//   - orderID: The ID of the order.
+	//
+	// Returns:
+	//   - A slice of OrderStatusHistory entries, empty if none were recorded.
+	//   - An error if the retrieval process fails.
+	GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusHistory, error)
+
+	// NewOrderID returns a new unique order ID. Orders are sharded by ID, so the ID is
+	// assigned before the order is inserted and used as the WithTransaction shard key.
+	NewOrderID() int64
+
+	// ShardOf returns the index of the shard that stores the order with the given ID.
+	// Orders on the same shard can be written together in a single WithTransaction call.
+	ShardOf(orderID int64) int
+
+	// CreateOrderTx inserts the order within tx, without its product requests, which are written
+	// separately with CreateOrderRequestTx. The order must already carry its ID from NewOrderID.
+	//
+	// Parameters:
+	//   - tx: The transaction opened by WithTransaction on the order's shard.
+	//   - order: A pointer to the Order entity to insert; its timestamps are set on success.
+	//
+	// Returns:
+	//   - An error if the insert fails, e.g. because the idempotency key is already used.
 	CreateOrderTx(ctx context.Context, tx *gorm.DB, order *entity.Order) error
+
+	// UpdateOrderTx saves the order within tx if its stored version still matches order.Version,
+	// returning ErrVersionConflict otherwise. The version is incremented on success.
+	UpdateOrderTx(ctx context.Context, tx *gorm.DB, order *entity.Order) error
+
+	// UpdateOrderStatusTx moves an order from one status to another within tx, incrementing its version.
+	//
+	// Parameters:
+	//   - tx: The transaction opened by WithTransaction on the order's shard.
+	//   - id: The ID of the order.
+	//   - from: The status the order must still be in.
+	//   - to: The status the order is moved to.
+	//
+	// Returns:
+	//   - True if the order was moved, false if it is no longer in the from status.
+	//   - An error if the update fails.
+	UpdateOrderStatusTx(ctx context.Context, tx *gorm.DB, id int64, from, to string) (bool, error)
+
+	// CreateOrderRequestTx inserts the product requests of an order within tx. Each request must
+	// carry the ID of its order, which must be inserted in the same transaction.
+	//
+	// Parameters:
+	//   - tx: The transaction opened by WithTransaction on the order's shard.
+	//   - order: The product requests to insert, none of which is written for an empty slice.
+	//
+	// Returns:
+	//   - An error if the insert fails.
 	CreateOrderRequestTx(ctx context.Context, tx *gorm.DB, order []entity.OrderRequest) error
-	WithTransaction(ctx context.Context, fn func(tx *gorm.DB) error) error
+
+	// UpdateOrderRequestPricingTx saves the markup, discount, final price and line total of each order line within tx.
+	// Lines are matched by their ID, so they must be the lines as read from the repository.
+	UpdateOrderRequestPricingTx(ctx context.Context, tx *gorm.DB, orderRequests []entity.OrderRequest) error
+
+	// CreateStatusHistoryTx records a status transition of an order within tx, so it is only kept if
+	// the change it describes is committed.
+	//
+	// Parameters:
+	//   - tx: The transaction opened by WithTransaction on the order's shard.
+	//   - entry: The transition to record; its ID is set on success.
+	//
+	// Returns:
+	//   - An error if the insert fails.
+	CreateStatusHistoryTx(ctx context.Context, tx *gorm.DB, entry *entity.OrderStatusHistory) error
+
+	// WithTransaction runs fn in a transaction on the shard selected by shardKey, which is the order
+	// ID, committing it if fn returns nil. The transaction is rolled back if fn returns an error or panics.
+	//
+	// Parameters:
+	//   - shardKey: The ID of the order the transaction writes, selecting its shard.
+	//   - fn: The writes to run, passed the transaction to hand to the Tx methods.
+	//
+	// Returns:
+	//   - The error returned by fn, or an error if the transaction cannot be started or committed.
+	WithTransaction(ctx context.Context, shardKey int64, fn func(tx *gorm.DB) error) error
+
+	// WithSavepoint runs fn within tx under the savepoint name. If fn fails, only its writes are
+	// rolled back and the transaction can go on with other writes.
+	//
+	// Parameters:
+	//   - tx: The transaction started by WithTransaction.
+	//   - name: The name of the savepoint, unique within the transaction.
+	//   - fn: The writes to run, passed the transaction to hand to the Tx methods.
+	//
+	// Returns:
+	//   - The error returned by fn, or ErrSavepoint if the savepoint cannot be set or rolled back to,
+	//     in which case the whole transaction must be rolled back.
+	WithSavepoint(ctx context.Context, tx *gorm.DB, name string, fn func(tx *gorm.DB) error) error
 }
 
 // orderRepository is a concrete implementation of the OrderRepository interface.
-// It uses an in-memory map to simulate order storage.
+// Orders and their product requests are stored on the shard selected from the order ID.
+// Read-only queries go to the shard's replica when one is configured.
 type orderRepository struct {
-	db *gorm.DB
+	shards   []*gorm.DB
+	replicas []*gorm.DB // Read replica of each shard, in shard order, or nil to read from the shards
+	router   *sharding.ShardRouter
 }
 
-// NewOrderRepository creates and returns a new instance of orderRepository.
+// NewOrderRepository creates and returns a new instance of orderRepository backed by a single database.
 //
 // Returns:
 //   - An instance of OrderRepository.
 func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return NewShardedOrderRepository([]*gorm.DB{db}, sharding.NewShardRouter(1))
+}
+
+// NewShardedOrderRepository creates and returns a new instance of orderRepository that spreads
+// orders across the given shards. The router must be configured with len(shards) shards.
+//
+// Returns:
+//   - An instance of OrderRepository.
+func NewShardedOrderRepository(shards []*gorm.DB, router *sharding.ShardRouter) OrderRepository {
+	return NewShardedOrderRepositoryRW(shards, nil, router)
+}
+
+// NewOrderRepositoryRW creates and returns a new instance of orderRepository backed by a single
+// database, sending writes and transactions to primary and read-only queries to replica.
+//
+// Returns:
+//   - An instance of OrderRepository.
+func NewOrderRepositoryRW(primary, replica *gorm.DB) OrderRepository {
+	return NewShardedOrderRepositoryRW([]*gorm.DB{primary}, []*gorm.DB{replica}, sharding.NewShardRouter(1))
+}
+
+// NewShardedOrderRepositoryRW creates and returns a new instance of orderRepository that spreads
+// orders across the given shards and reads from their replicas. replicas must either be nil, to read
+// from the shards themselves, or hold one replica per shard in the same order.
+//
+// Returns:
+//   - An instance of OrderRepository.
+func NewShardedOrderRepositoryRW(shards, replicas []*gorm.DB, router *sharding.ShardRouter) OrderRepository {
 	return &orderRepository{
-		db: db,
+		shards:   shards,
+		replicas: replicas,
+		router:   router,
 	}
 }
 
-// GetOrderByID retrieves an order by its ID from the in-memory storage.
+// shardFor returns the shard connection that stores the order with the given ID.
+func (r *orderRepository) shardFor(orderID int64) *gorm.DB {
+	return r.shards[r.router.GetShard(orderID)]
+}
+
+// readShards returns the connections read-only queries should use, one per shard in shard order:
+// the replicas if configured, unless ctx was marked with WithPrimary.
+func (r *orderRepository) readShards(ctx context.Context) []*gorm.DB {
+	if r.replicas == nil || readsFromPrimary(ctx) {
+		return r.shards
+	}
+	return r.replicas
+}
+
+func (r *orderRepository) NewOrderID() int64 {
+	return r.router.NextID()
+}
+
+func (r *orderRepository) ShardOf(orderID int64) int {
+	return r.router.GetShard(orderID)
+}
+
+// GetOrderByID retrieves an order by its ID together with its product requests, both read from the order's shard.
 //
 // Parameters:
 //   - id: The unique identifier of the order to retrieve.
@@ -81,32 +382,119 @@ func NewOrderRepository(db *gorm.DB) OrderRepository {
 //   - A pointer to the Order entity if found.
 //   - An error if the order is not found.
 func (r *orderRepository) GetOrderByID(ctx context.Context, id int64) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, id)
+	order, err := r.GetOrderHeaderByID(ctx, id)
+	if err != nil || order == nil {
+		return order, err
+	}
+
+	order.ProductRequests, err = orderRequests(ctx, r.readShards(ctx)[r.router.GetShard(id)], id)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// GetOrderHeaderByID retrieves an order by its ID without its product requests.
+//
+// Parameters:
+//   - id: The unique identifier of the order to retrieve.
+//
+// Returns:
+//   - A pointer to the Order entity if found, with ProductRequests left nil, or nil if not found.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) GetOrderHeaderByID(ctx context.Context, id int64) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, id)
+	db := r.readShards(ctx)[r.router.GetShard(id)]
+
 	var order entity.Order
-	err := r.db.Table("orders").WithContext(ctx).Where("id = ?", id).First(&order).Error
+	err := db.Table("orders").WithContext(ctx).Scopes(tenantScope(ctx)).Where("id = ?", id).First(&order).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Logger.Info().Int64("orderID", id).Msg("Order not found")
+			log.FromContext(ctx).Info().Msg("Order not found")
 			return nil, nil
 		}
-		log.Logger.Error().Err(err).Int64("orderID", id).Msg("Failed to get order by ID")
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to get order by ID")
 		return nil, err
 	}
 
 	return &order, nil
 }
 
+// GetOrderRequests retrieves the product requests of an order from the order's shard.
+//
+// Parameters:
+//   - orderID: The ID of the order whose product requests are retrieved.
+//
+// Returns:
+//   - A slice of the order's OrderRequest entities, empty if it has none.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) GetOrderRequests(ctx context.Context, orderID int64) ([]entity.OrderRequest, error) {
+	ctx = log.WithOrderID(ctx, orderID)
+	return orderRequests(ctx, r.readShards(ctx)[r.router.GetShard(orderID)], orderID)
+}
+
+// orderRequests reads the product requests of an order from db, the order's shard.
+func orderRequests(ctx context.Context, db *gorm.DB, orderID int64) ([]entity.OrderRequest, error) {
+	requests := []entity.OrderRequest{}
+	err := db.Table("product_requests").WithContext(ctx).Where("order_id = ?", orderID).Find(&requests).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to get product requests for order")
+		return nil, err
+	}
+	return requests, nil
+}
+
+// GetOrderByIdempotencyKey retrieves an order by the idempotency key it was created with.
+// The key does not determine the shard, so every shard is searched. The lookup matches the
+// (tenant_id, user_id, idempotency_key) unique index, with the tenant taken from ctx; unscoped
+// callers only see orders created without a tenant.
+//
+// Parameters:
+//   - userID: The ID of the user who created the order.
+//   - key: The idempotency key supplied when the order was created.
+//
+// Returns:
+//   - A pointer to the Order entity if found, or nil if not found.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) GetOrderByIdempotencyKey(ctx context.Context, userID int64, key string) (*entity.Order, error) {
+	for _, db := range r.shards {
+		var order entity.Order
+		err := db.Table("orders").WithContext(ctx).
+			Where("tenant_id = ? AND user_id = ? AND idempotency_key = ?", TenantFromContext(ctx), userID, key).
+			First(&order).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			log.FromContext(ctx).Error().Err(err).Str("idempotencyKey", key).Msg("Failed to get order by idempotency key")
+			return nil, err
+		}
+
+		// The order was just found on the primary, so it is read back from there too.
+		return r.GetOrderByID(WithPrimary(ctx), order.ID)
+	}
+
+	return nil, nil
+}
+
 // CreateOrder creates a new order in the in-memory storage.
 //
 // Parameters:
 //   - order: A pointer to the Order entity to be created.
 //
 // Returns:
-//   - A pointer to the created Order entity with an auto-generated ID.
+//   - A pointer to the created Order entity with a generated ID.
 //   - An error if the creation process fails.
 func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
-	err := r.db.Table("orders").WithContext(ctx).Create(order).Error
+	if order.ID == 0 {
+		order.ID = r.NewOrderID()
+	}
+
+	err := r.shardFor(order.ID).Table("orders").WithContext(ctx).Create(order).Error
 	if err != nil {
-		log.Logger.Error().Err(err).Msg("Failed to create order")
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to create order")
 		return nil, err
 	}
 
@@ -114,7 +502,8 @@ func (r *orderRepository) CreateOrder(ctx context.Context, order *entity.Order)
 }
 
 func (r *orderRepository) CreateOrderTx(ctx context.Context, tx *gorm.DB, order *entity.Order) error {
-	return tx.Table("orders").WithContext(ctx).Create(order).Error
+	// Product requests are written separately with CreateOrderRequestTx.
+	return tx.Table("orders").WithContext(ctx).Omit(clause.Associations).Create(order).Error
 }
 
 func (r *orderRepository) CreateOrderRequestTx(ctx context.Context, tx *gorm.DB, orderRequest []entity.OrderRequest) error {
@@ -124,6 +513,74 @@ func (r *orderRepository) CreateOrderRequestTx(ctx context.Context, tx *gorm.DB,
 	return tx.Table("product_requests").WithContext(ctx).CreateInBatches(orderRequest, 100).Error
 }
 
+func (r *orderRepository) UpdateOrderRequestPricingTx(ctx context.Context, tx *gorm.DB, orderRequests []entity.OrderRequest) error {
+	for _, orderRequest := range orderRequests {
+		err := tx.Table("product_requests").WithContext(ctx).
+			Where("id = ?", orderRequest.ID).
+			Updates(map[string]interface{}{
+				"mark_up":     orderRequest.MarkUp,
+				"discount":    orderRequest.Discount,
+				"final_price": orderRequest.FinalPrice,
+				"line_total":  orderRequest.LineTotal,
+				"currency":    orderRequest.Currency,
+				"updated_at":  time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *orderRepository) CreateStatusHistoryTx(ctx context.Context, tx *gorm.DB, entry *entity.OrderStatusHistory) error {
+	return tx.Table("order_status_history").WithContext(ctx).Create(entry).Error
+}
+
+func (r *orderRepository) UpdateOrderTx(ctx context.Context, tx *gorm.DB, order *entity.Order) error {
+	return updateOrderVersioned(ctx, tx, order)
+}
+
+// updateOrderVersioned saves the order only if its stored version still matches order.Version,
+// incrementing the version on success. It returns ErrVersionConflict if the order was changed
+// since it was read, leaving order.Version untouched.
+func updateOrderVersioned(ctx context.Context, db *gorm.DB, order *entity.Order) error {
+	expectedVersion := order.Version
+	order.Version++
+
+	// Select("*") writes zero values too, matching the full replacement Save used to do.
+	result := db.Table("orders").WithContext(ctx).Scopes(tenantScope(ctx)).
+		Where("id = ? AND version = ?", order.ID, expectedVersion).
+		Select("*").
+		Omit("id", "tenant_id", "created_at", "idempotency_key", "deleted_at", clause.Associations).
+		Updates(order)
+	if result.Error != nil {
+		order.Version = expectedVersion
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		order.Version = expectedVersion
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// UpdateOrderStatusTx moves an order from one status to another within tx.
+// It reports false without error if the order is no longer in the from status.
+func (r *orderRepository) UpdateOrderStatusTx(ctx context.Context, tx *gorm.DB, id int64, from, to string) (bool, error) {
+	result := tx.Table("orders").WithContext(ctx).Scopes(tenantScope(ctx)).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"status":     to,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 // UpdateOrder updates an existing order in the in-memory storage.
 //
 // Parameters:
@@ -133,15 +590,16 @@ func (r *orderRepository) CreateOrderRequestTx(ctx context.Context, tx *gorm.DB,
 //   - A pointer to the updated Order entity.
 //   - An error if the update process fails.
 func (r *orderRepository) UpdateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
-	err := r.db.Table("orders").WithContext(ctx).Save(order).Error
+	ctx = log.WithOrderID(ctx, order.ID)
+	err := updateOrderVersioned(ctx, r.shardFor(order.ID), order)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", order.ID).Msg("Failed to update order")
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to update order")
 		return nil, err
 	}
 	return order, nil
 }
 
-// DeleteOrder deletes an order by its ID from the in-memory storage.
+// DeleteOrder soft deletes an order by its ID by setting its deleted_at timestamp.
 //
 // Parameters:
 //   - id: The unique identifier of the order to delete.
@@ -149,28 +607,582 @@ func (r *orderRepository) UpdateOrder(ctx context.Context, order *entity.Order)
 // Returns:
 //   - An error if the order is not found or the deletion process fails.
 func (r *orderRepository) DeleteOrder(ctx context.Context, id int64) error {
-	order, err := r.GetOrderByID(ctx, id)
+	ctx = log.WithOrderID(ctx, id)
+	order, err := r.GetOrderHeaderByID(ctx, id)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", id).Msg("Failed to retrieve order before deletion")
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve order before deletion")
 		return err
 	}
 
 	if order == nil {
-		log.Logger.Warn().Int64("orderID", id).Msg("Order not found for deletion")
+		log.FromContext(ctx).Warn().Msg("Order not found for deletion")
 		return gorm.ErrRecordNotFound
 	}
 
-	err = r.db.Table("orders").WithContext(ctx).Delete(&entity.Order{}, id).Error
+	err = r.shardFor(id).Table("orders").WithContext(ctx).Scopes(tenantScope(ctx)).Delete(&entity.Order{}, id).Error
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", id).Msg("Failed to delete order")
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to delete order")
 		return err
 	}
 
 	return nil
 }
 
-func (r *orderRepository) WithTransaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
-	tx := r.db.Begin().WithContext(ctx)
+// PurgeOrder permanently deletes an order and its product requests by the order ID.
+//
+// Parameters:
+//   - id: The unique identifier of the order to purge.
+//
+// Returns:
+//   - An error if the order is not found or the deletion process fails.
+func (r *orderRepository) PurgeOrder(ctx context.Context, id int64) error {
+	ctx = log.WithOrderID(ctx, id)
+	return r.WithTransaction(ctx, id, func(tx *gorm.DB) error {
+		// The status history's foreign key restricts deleting the order, so it is deleted first.
+		// Product requests are deleted with the order by their foreign key's ON DELETE CASCADE.
+		err := tx.Table("order_status_history").WithContext(ctx).Where("order_id = ?", id).Delete(&entity.OrderStatusHistory{}).Error
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Msg("Failed to purge status history for order")
+			return err
+		}
+
+		// Deleting nothing rolls back the delete above, so an order of another tenant keeps its history.
+		result := tx.Table("orders").WithContext(ctx).Scopes(tenantScope(ctx)).Unscoped().Delete(&entity.Order{}, id)
+		if result.Error != nil {
+			log.FromContext(ctx).Error().Err(result.Error).Msg("Failed to purge order")
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			log.FromContext(ctx).Warn().Msg("Order not found for purge")
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// ListOrders retrieves a page of orders matching the given filter along with the total count.
+// Every shard is queried concurrently for its first offset+limit matches, and the results
+// are merged newest first before the requested page is cut out. Offsets past MaxListOffset
+// are rejected with ErrOffsetTooLarge.
+//
+// Parameters:
+//   - filter: The criteria to filter by, including limit and offset.
+//
+// Returns:
+//   - A slice of Order entities for the requested page.
+//   - The total number of orders matching the filter.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, int64, error) {
+	if filter.Offset > MaxListOffset {
+		return nil, 0, fmt.Errorf("%w: %d is past %d", ErrOffsetTooLarge, filter.Offset, MaxListOffset)
+	}
+
+	shardOrders := make([][]entity.Order, len(r.shards))
+	shardTotals := make([]int64, len(r.shards))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, db := range r.readShards(ctx) {
+		group.Go(func() error {
+			err := applyOrderFilter(db.Table("orders").WithContext(groupCtx).Scopes(tenantScope(ctx)), filter).Count(&shardTotals[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Msg("Failed to count orders")
+				return err
+			}
+
+			err = applyOrderFilter(db.Table("orders").WithContext(groupCtx).Scopes(tenantScope(ctx)), filter).
+				Order("id DESC").
+				Limit(filter.Offset + filter.Limit).
+				Find(&shardOrders[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Msg("Failed to list orders")
+				return err
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	orders := []entity.Order{}
+	for i := range r.shards {
+		total += shardTotals[i]
+		orders = append(orders, shardOrders[i]...)
+	}
+
+	// IDs are time-ordered, so sorting by ID descending returns the newest orders first.
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID > orders[j].ID
+	})
+
+	if filter.Offset >= len(orders) {
+		return []entity.Order{}, total, nil
+	}
+	end := filter.Offset + filter.Limit
+	if end > len(orders) {
+		end = len(orders)
+	}
+
+	return orders[filter.Offset:end], total, nil
+}
+
+// ListOrdersByCursor retrieves a page of orders newest first by (created_at, id). Each shard returns at most
+// limit orders after the cursor, which its created_at index serves without OFFSET since InnoDB secondary
+// indexes end with the primary key. The sorted shard results are then merged through a heap bounded by the
+// number of shards until the page is full.
+//
+// Parameters:
+//   - after: The position of the last order of the previous page, or nil for the first page.
+//   - limit: The maximum number of orders to return across all shards.
+//
+// Returns:
+//   - A slice of Order entities, newest first.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) ListOrdersByCursor(ctx context.Context, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	shardOrders := make([][]entity.Order, len(r.shards))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, db := range r.readShards(ctx) {
+		group.Go(func() error {
+			query := db.Table("orders").WithContext(groupCtx).Scopes(tenantScope(ctx))
+			if after != nil {
+				query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", after.CreatedAt, after.CreatedAt, after.ID)
+			}
+			err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&shardOrders[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Msg("Failed to list orders by cursor")
+				return err
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeNewestFirst(shardOrders, limit), nil
+}
+
+// mergeNewestFirst merges per-shard slices already sorted newest first into one slice of at most limit orders.
+func mergeNewestFirst(shardOrders [][]entity.Order, limit int) []entity.Order {
+	h := &orderHeap{shardOrders: shardOrders}
+	for i, orders := range shardOrders {
+		if len(orders) > 0 {
+			h.heads = append(h.heads, shardHead{shard: i})
+		}
+	}
+	heap.Init(h)
+
+	merged := make([]entity.Order, 0, limit)
+	for h.Len() > 0 && len(merged) < limit {
+		head := &h.heads[0]
+		merged = append(merged, shardOrders[head.shard][head.index])
+		head.index++
+		if head.index < len(shardOrders[head.shard]) {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return merged
+}
+
+// shardHead points at the next unmerged order of a shard.
+type shardHead struct {
+	shard int
+	index int
+}
+
+// orderHeap is a heap of shard heads with the newest order by (created_at, id) on top.
+type orderHeap struct {
+	shardOrders [][]entity.Order
+	heads       []shardHead
+}
+
+func (h *orderHeap) Len() int { return len(h.heads) }
+
+func (h *orderHeap) Less(i, j int) bool {
+	a := &h.shardOrders[h.heads[i].shard][h.heads[i].index]
+	b := &h.shardOrders[h.heads[j].shard][h.heads[j].index]
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+func (h *orderHeap) Swap(i, j int) { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+
+func (h *orderHeap) Push(x any) { h.heads = append(h.heads, x.(shardHead)) }
+
+func (h *orderHeap) Pop() any {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
+
+// GetStatusHistory retrieves the status transitions of an order from the order's shard, oldest first.
+//
+// Parameters:
+//   - orderID: The ID of the order.
+//
+// Returns:
+//   - A slice of OrderStatusHistory entries, empty if none were recorded.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID int64) ([]entity.OrderStatusHistory, error) {
+	ctx = log.WithOrderID(ctx, orderID)
+	db := r.readShards(ctx)[r.router.GetShard(orderID)]
+
+	// The history table has no tenant, so the order itself is checked to belong to the tenant.
+	if TenantFromContext(ctx) != "" {
+		var count int64
+		err := db.Table("orders").WithContext(ctx).Scopes(tenantScope(ctx)).Where("id = ?", orderID).Count(&count).Error
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Msg("Failed to check order tenant")
+			return nil, err
+		}
+		if count == 0 {
+			return []entity.OrderStatusHistory{}, nil
+		}
+	}
+
+	history := []entity.OrderStatusHistory{}
+	err := db.Table("order_status_history").WithContext(ctx).Where("order_id = ?", orderID).Order("id ASC").Find(&history).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to get order status history")
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// GetOrdersByUserID retrieves a page of a user's orders, newest first, together with their product requests.
+// A user's orders are spread across shards by order ID, so every shard is queried concurrently for its
+// first offset+limit orders and the results are merged by creation time before the page is cut out.
+// With a single shard the page is queried directly. Offsets past MaxListOffset are rejected with
+// ErrOffsetTooLarge.
+//
+// Parameters:
+//   - userID: The ID of the user whose orders are retrieved.
+//   - limit: The maximum number of orders to return.
+//   - offset: The number of orders to skip.
+//
+// Returns:
+//   - The requested page of orders and the user's total number of orders.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) GetOrdersByUserID(ctx context.Context, userID int64, limit, offset int) (*entity.OrderPage, error) {
+	if offset > MaxListOffset {
+		return nil, fmt.Errorf("%w: %d is past %d", ErrOffsetTooLarge, offset, MaxListOffset)
+	}
+
+	shards := r.readShards(ctx)
+	shardOffset, shardLimit := 0, offset+limit
+	if len(shards) == 1 {
+		shardOffset, shardLimit = offset, limit
+	}
+
+	shardOrders := make([][]entity.Order, len(shards))
+	shardTotals := make([]int64, len(shards))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, db := range shards {
+		group.Go(func() error {
+			err := db.Table("orders").WithContext(groupCtx).Scopes(tenantScope(ctx)).Where("user_id = ?", userID).Count(&shardTotals[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Int64("userID", userID).Msg("Failed to count user orders")
+				return err
+			}
+
+			err = db.Table("orders").WithContext(groupCtx).Scopes(tenantScope(ctx)).
+				Where("user_id = ?", userID).
+				Order("created_at DESC, id DESC").
+				Offset(shardOffset).
+				Limit(shardLimit).
+				Find(&shardOrders[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Int64("userID", userID).Msg("Failed to get user orders")
+				return err
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entity.OrderPage{Orders: []entity.Order{}, Limit: limit, Offset: offset}
+	orders := []entity.Order{}
+	for i := range shards {
+		page.Total += shardTotals[i]
+		orders = append(orders, shardOrders[i]...)
+	}
+
+	if len(shards) > 1 {
+		sort.Slice(orders, func(i, j int) bool {
+			if orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+				return orders[i].ID > orders[j].ID
+			}
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		})
+		if offset >= len(orders) {
+			return page, nil
+		}
+		end := offset + limit
+		if end > len(orders) {
+			end = len(orders)
+		}
+		orders = orders[offset:end]
+	}
+
+	// Only the orders on the page have their product requests loaded, each from its own shard.
+	for i := range orders {
+		order := &orders[i]
+		err = shards[r.router.GetShard(order.ID)].Table("product_requests").WithContext(ctx).Where("order_id = ?", order.ID).Find(&order.ProductRequests).Error
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Int64("orderID", order.ID).Msg("Failed to get product requests for order")
+			return nil, err
+		}
+	}
+
+	page.Orders = orders
+	return page, nil
+}
+
+// SumUserProductQuantities totals the quantity of each product a user has ordered, across orders that were
+// neither cancelled nor expired. A user's orders are spread across shards, so every shard is summed
+// concurrently and the per-shard totals are added up.
+//
+// Parameters:
+//   - userID: The ID of the user whose orders are summed.
+//   - productIDs: The products to total.
+//
+// Returns:
+//   - The ordered quantity by product ID; products the user never ordered are absent.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) SumUserProductQuantities(ctx context.Context, userID int64, productIDs []int64) (map[int64]int64, error) {
+	type productQuantity struct {
+		ProductID int64
+		Quantity  int64
+	}
+
+	shards := r.readShards(ctx)
+	shardSums := make([][]productQuantity, len(shards))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, db := range shards {
+		group.Go(func() error {
+			query := db.Table("product_requests AS pr").WithContext(groupCtx).
+				Select("pr.product_id AS product_id, SUM(pr.quantity) AS quantity").
+				Joins("JOIN orders AS o ON o.id = pr.order_id").
+				Where("o.user_id = ? AND pr.product_id IN ?", userID, productIDs).
+				Where("o.status NOT IN ? AND o.deleted_at IS NULL", []string{entity.OrderStatusCancelled, entity.OrderStatusExpired})
+			if tenantID := TenantFromContext(ctx); tenantID != "" {
+				query = query.Where("o.tenant_id = ?", tenantID)
+			}
+			err := query.Group("pr.product_id").Scan(&shardSums[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Int64("userID", userID).Msg("Failed to sum user product quantities")
+				return err
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	quantities := make(map[int64]int64)
+	for _, sums := range shardSums {
+		for _, sum := range sums {
+			quantities[sum.ProductID] += sum.Quantity
+		}
+	}
+	return quantities, nil
+}
+
+// GetOrdersByStatusOlderThan retrieves a page of the orders in a status that were created before cutoff,
+// oldest first by (created_at, id). Every shard is queried concurrently for its oldest matches after the
+// cursor, which its (status, created_at) index serves without OFFSET, and the merged results are cut to limit.
+//
+// Parameters:
+//   - status: The status of the orders to return.
+//   - cutoff: Orders created before this time are returned.
+//   - after: The position of the last order of the previous page, or nil for the first page.
+//   - limit: The maximum number of orders to return across all shards.
+//
+// Returns:
+//   - A slice of Order entities with their product requests, oldest first.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) GetOrdersByStatusOlderThan(ctx context.Context, status string, cutoff time.Time, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	shardOrders := make([][]entity.Order, len(r.shards))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, db := range r.shards {
+		group.Go(func() error {
+			query := db.Table("orders").WithContext(groupCtx).
+				Scopes(tenantScope(ctx)).
+				Where("status = ? AND created_at < ?", status, cutoff)
+			if after != nil {
+				query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", after.CreatedAt, after.CreatedAt, after.ID)
+			}
+			err := query.Order("created_at ASC, id ASC").Limit(limit).Find(&shardOrders[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Str("status", status).Msg("Failed to get orders by status")
+				return err
+			}
+
+			for j := range shardOrders[i] {
+				order := &shardOrders[i][j]
+				err = db.Table("product_requests").WithContext(groupCtx).Where("order_id = ?", order.ID).Find(&order.ProductRequests).Error
+				if err != nil {
+					log.FromContext(ctx).Error().Err(err).Int64("orderID", order.ID).Msg("Failed to get product requests for order")
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []entity.Order{}
+	for i := range r.shards {
+		orders = append(orders, shardOrders[i]...)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+// GetOrdersByCancelFilter retrieves a page of the orders matching a bulk cancel filter, oldest first by
+// (created_at, id). Every shard is queried concurrently for its oldest matches after the cursor and the
+// merged results are cut to limit.
+//
+// Parameters:
+//   - filter: The status, creation range and product the orders must match. The status must be set.
+//   - after: The position of the last order of the previous page, or nil for the first page.
+//   - limit: The maximum number of orders to return across all shards.
+//
+// Returns:
+//   - A slice of Order entities with their product requests, oldest first.
+//   - An error if the retrieval process fails.
+func (r *orderRepository) GetOrdersByCancelFilter(ctx context.Context, filter entity.OrderCancelFilter, after *entity.OrderCursor, limit int) ([]entity.Order, error) {
+	shardOrders := make([][]entity.Order, len(r.shards))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, db := range r.shards {
+		group.Go(func() error {
+			query := db.Table("orders").WithContext(groupCtx).
+				Scopes(tenantScope(ctx)).
+				Where("status = ?", filter.Status)
+			if filter.CreatedAfter != nil {
+				query = query.Where("created_at >= ?", filter.CreatedAfter.UTC())
+			}
+			if filter.CreatedBefore != nil {
+				query = query.Where("created_at < ?", filter.CreatedBefore.UTC())
+			}
+			if filter.ProductID != 0 {
+				query = query.Where("EXISTS (SELECT 1 FROM product_requests pr WHERE pr.order_id = orders.id AND pr.product_id = ?)", filter.ProductID)
+			}
+			if after != nil {
+				query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", after.CreatedAt, after.CreatedAt, after.ID)
+			}
+			err := query.Order("created_at ASC, id ASC").Limit(limit).Find(&shardOrders[i]).Error
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int("shard", i).Str("status", filter.Status).Msg("Failed to get orders by cancel filter")
+				return err
+			}
+
+			for j := range shardOrders[i] {
+				order := &shardOrders[i][j]
+				order.ProductRequests, err = orderRequests(log.WithOrderID(groupCtx, order.ID), db, order.ID)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []entity.Order{}
+	for i := range r.shards {
+		orders = append(orders, shardOrders[i]...)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+// applyOrderFilter adds a WHERE clause for every non-empty field of the filter. Creation bounds are
+// compared in UTC, the zone timestamps are stored in.
+func applyOrderFilter(query *gorm.DB, filter entity.OrderFilter) *gorm.DB {
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", filter.CreatedAfter.UTC())
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", filter.CreatedBefore.UTC())
+	}
+	return query
+}
+
+// WithTransaction runs fn in a transaction on the shard selected by shardKey, which is the order ID.
+// The transaction is rolled back if fn returns an error or panics, and fn is not called if it cannot begin.
+func (r *orderRepository) WithTransaction(ctx context.Context, shardKey int64, fn func(tx *gorm.DB) error) error {
+	ctx, span := tracer.Start(ctx, "db.transaction")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("order.id", shardKey),
+		attribute.Int("db.shard", r.router.GetShard(shardKey)),
+	)
+
+	tx := r.shardFor(shardKey).WithContext(ctx).Begin()
+	if tx.Error != nil {
+		log.FromContext(ctx).Error().Err(tx.Error).Msg("Failed to begin transaction")
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, "transaction begin failed")
+		return tx.Error
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -182,8 +1194,35 @@ func (r *orderRepository) WithTransaction(ctx context.Context, fn func(tx *gorm.
 	err := fn(tx)
 	if err != nil {
 		tx.Rollback()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "transaction rolled back")
+		return err
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "transaction commit failed")
+	}
+	return err
+}
+
+func (r *orderRepository) WithSavepoint(ctx context.Context, tx *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	err := tx.SavePoint(name).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Str("savepoint", name).Msg("Failed to set savepoint")
+		return fmt.Errorf("%w: set %s: %w", ErrSavepoint, name, err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		rollbackErr := tx.RollbackTo(name).Error
+		if rollbackErr != nil {
+			log.FromContext(ctx).Error().Err(rollbackErr).Str("savepoint", name).Msg("Failed to roll back to savepoint")
+			return fmt.Errorf("%w: roll back to %s: %w", ErrSavepoint, name, rollbackErr)
+		}
 		return err
 	}
 
-	return tx.Commit().Error
+	return nil
 }
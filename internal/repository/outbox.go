@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRepository defines the interface for managing events in the transactional outbox.
+type OutboxRepository interface {
+	// CreateOutboxEventTx stores a pending event within the given transaction.
+	//
+	// Parameters:
+	//   - tx: The transaction the order change is being written in.
+	//   - event: A pointer to the OutboxEvent to store.
+	//
+	// Returns:
+	//   - An error if the insert fails.
+	CreateOutboxEventTx(ctx context.Context, tx *gorm.DB, event *entity.OutboxEvent) error
+
+	// ClaimPendingOutboxEvents locks the oldest pending events of a partition with SELECT ... FOR UPDATE
+	// SKIP LOCKED and passes them to fn within the same transaction. Events locked by another publisher
+	// are skipped rather than waited for, and stay locked until fn returns and its updates through tx
+	// are committed, so no two publishers can publish the same event.
+	//
+	// The partition is also guarded by a MySQL advisory lock held until the transaction is committed, so
+	// only one publisher across all instances claims from it at a time and the events of an order are
+	// published in the order they were written. A partition held by another publisher claims nothing.
+	// Instances must therefore split events into the same number of partitions.
+	//
+	// Parameters:
+	//   - limit: The maximum number of events to claim.
+	//   - partition: The partition to claim from, between 0 and partitions-1.
+	//   - partitions: The number of partitions events are split into by aggregate ID, so all events of
+	//     an order fall in the same partition. Values below 2 claim from all events.
+	//   - fn: Publishes the claimed events, ordered by ID, and records the outcome through tx.
+	//
+	// Returns:
+	//   - The number of events claimed.
+	//   - An error if the events cannot be claimed, or the error returned by fn, in which case the
+	//     updates made through tx are rolled back.
+	ClaimPendingOutboxEvents(ctx context.Context, limit, partition, partitions int, fn func(tx *gorm.DB, events []entity.OutboxEvent) error) (int, error)
+
+	// MarkOutboxEventPublishedTx marks an event as successfully published within the given transaction.
+	//
+	// Parameters:
+	//   - tx: The transaction the event was claimed in.
+	//   - id: The ID of the published event.
+	//
+	// Returns:
+	//   - An error if the update fails.
+	MarkOutboxEventPublishedTx(ctx context.Context, tx *gorm.DB, id int64) error
+
+	// MarkOutboxEventAttemptFailedTx records a failed publish attempt within the given transaction. The event
+	// stays pending for another attempt until maxAttempts is reached, after which it is marked failed.
+	//
+	// Parameters:
+	//   - tx: The transaction the event was claimed in.
+	//   - event: A pointer to the OutboxEvent that failed to publish, as last read.
+	//   - publishErr: The error returned by the publish attempt.
+	//   - maxAttempts: The number of attempts after which the event is marked failed.
+	//
+	// Returns:
+	//   - An error if the update fails.
+	MarkOutboxEventAttemptFailedTx(ctx context.Context, tx *gorm.DB, event *entity.OutboxEvent, publishErr error, maxAttempts int) error
+
+	// RequeueFailedOutboxEvents moves failed events back to pending with a fresh set of attempts,
+	// leaving alone events that already failed maxTotalAttempts times over their lifetime.
+	//
+	// Parameters:
+	//   - maxTotalAttempts: Only events with fewer lifetime attempts are requeued.
+	//
+	// Returns:
+	//   - The number of events requeued.
+	//   - An error if the update fails.
+	RequeueFailedOutboxEvents(ctx context.Context, maxTotalAttempts int) (int64, error)
+
+	// CountFailedOutboxEvents counts the events that gave up publishing.
+	//
+	// Returns:
+	//   - The number of failed events.
+	//   - An error if the count fails.
+	CountFailedOutboxEvents(ctx context.Context) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository creates and returns a new instance of outboxRepository.
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
+
+func (r *outboxRepository) CreateOutboxEventTx(ctx context.Context, tx *gorm.DB, event *entity.OutboxEvent) error {
+	if event.Status == "" {
+		event.Status = entity.OutboxStatusPending
+	}
+	return tx.Table("outbox").WithContext(ctx).Create(event).Error
+}
+
+func (r *outboxRepository) ClaimPendingOutboxEvents(ctx context.Context, limit, partition, partitions int, fn func(tx *gorm.DB, events []entity.OutboxEvent) error) (int, error) {
+	var claimed int
+	// GET_LOCK is held by the connection, so the lock, the claim and the release run on one connection.
+	err := r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		lockName := outboxPartitionLockName(partition, partitions)
+		var locked sql.NullInt64
+		err := conn.Raw("SELECT GET_LOCK(?, 0)", lockName).Scan(&locked).Error
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Int("partition", partition).Msg("Failed to lock outbox partition")
+			return err
+		}
+		if locked.Int64 != 1 {
+			// Another publisher is draining the partition.
+			return nil
+		}
+		defer func() {
+			// The release must not be skipped because ctx was cancelled, or the lock would outlive the claim.
+			releaseErr := conn.WithContext(context.WithoutCancel(ctx)).Exec("SELECT RELEASE_LOCK(?)", lockName).Error
+			if releaseErr != nil {
+				log.FromContext(ctx).Error().Err(releaseErr).Int("partition", partition).Msg("Failed to unlock outbox partition")
+			}
+		}()
+
+		claimed, err = r.claimPendingOutboxEvents(ctx, conn, limit, partition, partitions, fn)
+		return err
+	})
+	if err != nil {
+		return claimed, err
+	}
+
+	return claimed, nil
+}
+
+// outboxPartitionLockName returns the name of the advisory lock guarding a partition of the outbox.
+func outboxPartitionLockName(partition, partitions int) string {
+	if partitions < 2 {
+		return "outbox:partition:all"
+	}
+	return fmt.Sprintf("outbox:partition:%d/%d", partition, partitions)
+}
+
+// claimPendingOutboxEvents claims the events of a partition on conn, in a transaction that also
+// commits the updates fn makes, and returns how many were claimed.
+func (r *outboxRepository) claimPendingOutboxEvents(ctx context.Context, conn *gorm.DB, limit, partition, partitions int, fn func(tx *gorm.DB, events []entity.OutboxEvent) error) (int, error) {
+	var claimed int
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("outbox").
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", entity.OutboxStatusPending)
+		if partitions > 1 {
+			query = query.Where("MOD(aggregate_id, ?) = ?", partitions, partition)
+		}
+
+		var events []entity.OutboxEvent
+		err := query.Order("id ASC").Limit(limit).Find(&events).Error
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Msg("Failed to claim pending outbox events")
+			return err
+		}
+		claimed = len(events)
+		if claimed == 0 {
+			return nil
+		}
+
+		return fn(tx, events)
+	})
+	return claimed, err
+}
+
+func (r *outboxRepository) MarkOutboxEventPublishedTx(ctx context.Context, tx *gorm.DB, id int64) error {
+	err := tx.Table("outbox").WithContext(ctx).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       entity.OutboxStatusPublished,
+		"published_at": time.Now(),
+	}).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("outboxID", id).Msg("Failed to mark outbox event published")
+		return err
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) MarkOutboxEventAttemptFailedTx(ctx context.Context, tx *gorm.DB, event *entity.OutboxEvent, publishErr error, maxAttempts int) error {
+	event.Attempts++
+	event.TotalAttempts++
+	event.LastError = publishErr.Error()
+	if event.Attempts >= maxAttempts {
+		event.Status = entity.OutboxStatusFailed
+	}
+
+	err := tx.Table("outbox").WithContext(ctx).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"attempts":       event.Attempts,
+		"total_attempts": event.TotalAttempts,
+		"last_error":     event.LastError,
+		"status":         event.Status,
+	}).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("outboxID", event.ID).Msg("Failed to record outbox publish failure")
+		return err
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) RequeueFailedOutboxEvents(ctx context.Context, maxTotalAttempts int) (int64, error) {
+	result := r.db.Table("outbox").WithContext(ctx).
+		Where("status = ? AND total_attempts < ?", entity.OutboxStatusFailed, maxTotalAttempts).
+		Updates(map[string]interface{}{
+			"status":   entity.OutboxStatusPending,
+			"attempts": 0,
+		})
+	if result.Error != nil {
+		log.FromContext(ctx).Error().Err(result.Error).Msg("Failed to requeue failed outbox events")
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (r *outboxRepository) CountFailedOutboxEvents(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.Table("outbox").WithContext(ctx).
+		Where("status = ?", entity.OutboxStatusFailed).
+		Count(&count).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to count failed outbox events")
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,18 @@
+package repository
+
+import "context"
+
+type readPrimaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary databases instead of the replicas.
+// Use it to read an order back after writing it in the same request, or to load the state a
+// write is about to be decided on, since replicas may lag behind the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+// readsFromPrimary reports whether ctx was marked with WithPrimary.
+func readsFromPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(readPrimaryKey{}).(bool)
+	return primary
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose order reads, updates and deletes only see the orders of
+// tenantID. Orders of other tenants behave as if they did not exist. An empty tenantID leaves
+// ctx unscoped, as for background jobs and callers that do not belong to a tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ctx was scoped to with WithTenant, or "" if it is unscoped.
+func TenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// tenantScope returns a GORM scope restricting a query on the orders table to the tenant of ctx.
+func tenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantID := TenantFromContext(ctx); tenantID != "" {
+			return db.Where("tenant_id = ?", tenantID)
+		}
+		return db
+	}
+}
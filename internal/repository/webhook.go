@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookRepository defines the interface for managing queued webhook deliveries.
+type WebhookRepository interface {
+	// CreateWebhookDeliveries queues deliveries as pending, due immediately.
+	//
+	// Parameters:
+	//   - deliveries: The deliveries to queue.
+	//
+	// Returns:
+	//   - An error if the insert fails.
+	CreateWebhookDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error
+
+	// ClaimDueWebhookDeliveries claims the oldest pending deliveries whose next attempt is due. The rows
+	// are locked with SELECT ... FOR UPDATE SKIP LOCKED and their next attempt is moved to leaseUntil
+	// before the claim is committed, so no other dispatcher picks them up while they are being sent.
+	// A delivery whose outcome is never recorded, for example because its dispatcher crashed, becomes
+	// due again once the lease runs out.
+	//
+	// Parameters:
+	//   - now: Deliveries with a next attempt at or before this time are due.
+	//   - limit: The maximum number of deliveries to claim.
+	//   - leaseUntil: The time until which the claimed deliveries are held by the caller.
+	//
+	// Returns:
+	//   - A slice of claimed WebhookDelivery entities ordered by ID.
+	//   - An error if the claim fails.
+	ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]entity.WebhookDelivery, error)
+
+	// MarkWebhookDelivered marks a delivery as successfully delivered.
+	//
+	// Parameters:
+	//   - id: The ID of the delivered delivery.
+	//
+	// Returns:
+	//   - An error if the update fails.
+	MarkWebhookDelivered(ctx context.Context, id int64) error
+
+	// MarkWebhookAttemptFailed records a failed delivery attempt. The delivery stays pending until
+	// nextAttemptAt, or is marked failed once maxAttempts is reached.
+	//
+	// Parameters:
+	//   - delivery: A pointer to the WebhookDelivery that failed, as last read.
+	//   - deliveryErr: The error returned by the delivery attempt.
+	//   - nextAttemptAt: The earliest time of the next attempt.
+	//   - maxAttempts: The number of attempts after which the delivery is marked failed.
+	//
+	// Returns:
+	//   - An error if the update fails.
+	MarkWebhookAttemptFailed(ctx context.Context, delivery *entity.WebhookDelivery, deliveryErr error, nextAttemptAt time.Time, maxAttempts int) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository creates and returns a new instance of webhookRepository.
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{
+		db: db,
+	}
+}
+
+func (r *webhookRepository) CreateWebhookDeliveries(ctx context.Context, deliveries []entity.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range deliveries {
+		deliveries[i].Status = entity.WebhookStatusPending
+		deliveries[i].NextAttemptAt = now
+	}
+
+	err := r.db.Table("webhook_deliveries").WithContext(ctx).Create(&deliveries).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to queue webhook deliveries")
+		return err
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("webhook_deliveries").
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", entity.WebhookStatusPending, now).
+			Order("id ASC").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		return tx.Table("webhook_deliveries").Where("id IN ?", ids).Update("next_attempt_at", leaseUntil).Error
+	})
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to claim due webhook deliveries")
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookRepository) MarkWebhookDelivered(ctx context.Context, id int64) error {
+	err := r.db.Table("webhook_deliveries").WithContext(ctx).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       entity.WebhookStatusDelivered,
+		"delivered_at": time.Now(),
+	}).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("deliveryID", id).Msg("Failed to mark webhook delivered")
+		return err
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) MarkWebhookAttemptFailed(ctx context.Context, delivery *entity.WebhookDelivery, deliveryErr error, nextAttemptAt time.Time, maxAttempts int) error {
+	delivery.Attempts++
+	delivery.LastError = deliveryErr.Error()
+	delivery.NextAttemptAt = nextAttemptAt
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = entity.WebhookStatusFailed
+	}
+
+	err := r.db.Table("webhook_deliveries").WithContext(ctx).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"attempts":        delivery.Attempts,
+		"last_error":      delivery.LastError,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"status":          delivery.Status,
+	}).Error
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("deliveryID", delivery.ID).Msg("Failed to record webhook delivery failure")
+		return err
+	}
+
+	return nil
+}
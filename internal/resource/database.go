@@ -4,41 +4,136 @@ import (
 	"fmt"
 	"log"
 	"order-service/config"
+	"os"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+const (
+	defaultMaxOpenConns    = 100
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+
+	defaultStatementTimeout   = 10 * time.Second
+	defaultSlowQueryThreshold = 200 * time.Millisecond
+)
+
 func InitDB(appConfig config.Config) *gorm.DB {
-	// Create DSN (Data Source Name)
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		appConfig.DB.User,
-		appConfig.DB.Password,
-		appConfig.DB.Host,
-		appConfig.DB.Port,
-		appConfig.DB.Name)
+	return openDB(appConfig.DB, appConfig.Startup)
+}
 
-	// Connect to database using GORM
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+// InitShardDBs opens one connection per order shard database, in shard order.
+func InitShardDBs(appConfig config.Config) []*gorm.DB {
+	shardConfigs := shardConfigs(appConfig.DB)
+
+	shards := make([]*gorm.DB, 0, len(shardConfigs))
+	for _, shardConfig := range shardConfigs {
+		shards = append(shards, openDB(shardConfig, appConfig.Startup))
+	}
+	return shards
+}
+
+// shardConfigs returns the connection settings of every order shard database, in shard order.
+func shardConfigs(cfg config.DB) []config.DB {
+	shardNames := []string{cfg.NameS1, cfg.NameS2}
+
+	configs := make([]config.DB, 0, len(shardNames))
+	for _, name := range shardNames {
+		shardConfig := cfg
+		shardConfig.Name = name
+		configs = append(configs, shardConfig)
+	}
+	return configs
+}
 
+// InitShardReplicaDBs opens one connection per order shard database on the read replica, in shard
+// order. It returns nil when no replica is configured, so reads stay on the shards themselves.
+func InitShardReplicaDBs(appConfig config.Config) []*gorm.DB {
+	if appConfig.DB.ReplicaHost == "" {
+		return nil
+	}
+
+	replicaConfig := appConfig.DB
+	replicaConfig.Host = appConfig.DB.ReplicaHost
+	if appConfig.DB.ReplicaPort != "" {
+		replicaConfig.Port = appConfig.DB.ReplicaPort
+	}
+
+	return InitShardDBs(config.Config{DB: replicaConfig, Startup: appConfig.Startup})
+}
+
+// openDB connects to the cfg.Name database, retrying as configured by startup while it is unreachable.
+func openDB(cfg config.DB, startup config.Startup) *gorm.DB {
+	var db *gorm.DB
+	err := Retry(startup, fmt.Sprintf("database %s on %s", cfg.Name, cfg.Host), func() error {
+		var err error
+		db, err = NewDatabase(cfg)
+		return err
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
+	return db
+}
+
+// NewDatabase opens a pooled connection to the cfg.Name database and pings it, so an
+// unreachable database is reported at startup rather than on the first request.
+// Unset pool settings fall back to 100 open connections, 10 idle connections and a
+// 30 minute connection lifetime. Queries slower than cfg.SlowQueryThreshold, 200ms when
+// unset, are logged as slow with their SQL.
+func NewDatabase(cfg config.DB) (*gorm.DB, error) {
+	slowQueryThreshold := cfg.SlowQueryThreshold
+	if slowQueryThreshold <= 0 {
+		slowQueryThreshold = defaultSlowQueryThreshold
+	}
+
+	// Connect to database using GORM
+	db, err := gorm.Open(mysql.Open(dataSourceName(cfg)), &gorm.Config{
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold: slowQueryThreshold,
+			LogLevel:      logger.Info,
+			Colorful:      true,
+		}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", cfg.Name, err)
+	}
 
-	// Optional: Configure connection pool
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("Failed to get underlying sql.DB:", err)
+		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+
+	maxOpenConns := cfg.MaxOpenConns
+	if maxOpenConns <= 0 {
+		maxOpenConns = defaultMaxOpenConns
+	}
+	maxIdleConns := cfg.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	connMaxLifetime := cfg.ConnMaxLifetime
+	if connMaxLifetime <= 0 {
+		connMaxLifetime = defaultConnMaxLifetime
 	}
 
-	// Set connection pool settings (optional)
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(connMaxLifetime)
 
-	return db
+	err = sqlDB.Ping()
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to ping database %s: %w", cfg.Name, err)
+	}
+
+	return db, nil
 }
 
 func TestConnection(db *gorm.DB) error {
@@ -49,3 +144,31 @@ func TestConnection(db *gorm.DB) error {
 
 	return sqlDB.Ping()
 }
+
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
+// dataSourceName returns the MySQL DSN of the cfg.Name database. Every connection sets the
+// max_execution_time session variable, so MySQL aborts a SELECT running longer than
+// cfg.StatementTimeout, 10s when unset, instead of letting it hold a connection.
+// Timestamps are read, written and defaulted by MySQL in UTC, whatever the zone of the host.
+func dataSourceName(cfg config.DB) string {
+	statementTimeout := cfg.StatementTimeout
+	if statementTimeout <= 0 {
+		statementTimeout = defaultStatementTimeout
+	}
+
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27&max_execution_time=%d",
+		cfg.User,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.Name,
+		statementTimeout.Milliseconds())
+}
@@ -0,0 +1,93 @@
+package resource
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"order-service/config"
+	"order-service/internal/entity"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratemysql "github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"gorm.io/gorm"
+)
+
+// Migration modes selected by config.DB.Migrations.
+const (
+	MigrationsUp   = "up"   // Apply pending versioned migrations, for production
+	MigrationsAuto = "auto" // Create and update the tables from the entities, for development
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrate brings the schema of every order shard up to date using the configured mode.
+// It does nothing when no mode is configured.
+func Migrate(appConfig config.Config, shards []*gorm.DB) error {
+	switch appConfig.DB.Migrations {
+	case MigrationsUp:
+		return MigrateUp(appConfig.DB)
+	case MigrationsAuto:
+		return AutoMigrate(shards)
+	case "":
+		return nil
+	default:
+		return fmt.Errorf("unknown migration mode %q", appConfig.DB.Migrations)
+	}
+}
+
+// MigrateUp applies the pending versioned migrations in the migrations directory to every order
+// shard database. Only up migrations are run; applied versions are tracked in schema_migrations.
+func MigrateUp(cfg config.DB) error {
+	for i, shardConfig := range shardConfigs(cfg) {
+		err := migrateUp(shardConfig)
+		if err != nil {
+			return fmt.Errorf("failed to migrate shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func migrateUp(cfg config.DB) error {
+	// Migration files hold several statements each.
+	db, err := sql.Open("mysql", dataSourceName(cfg)+"&multiStatements=true")
+	if err != nil {
+		return fmt.Errorf("failed to open database %s: %w", cfg.Name, err)
+	}
+	defer db.Close()
+
+	source, err := iofs.New(migrationFiles, "migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	driver, err := migratemysql.WithInstance(db, &migratemysql.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to prepare database %s for migration: %w", cfg.Name, err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "mysql", driver)
+	if err != nil {
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations to %s: %w", cfg.Name, err)
+	}
+	return nil
+}
+
+// AutoMigrate creates or updates the order tables on every shard to match the entities,
+// adding missing tables, columns and indexes. It never drops columns.
+func AutoMigrate(shards []*gorm.DB) error {
+	for i, shard := range shards {
+		err := shard.AutoMigrate(&entity.Order{}, &entity.OrderRequest{}, &entity.OutboxEvent{}, &entity.OrderStatusHistory{}, &entity.WebhookDelivery{})
+		if err != nil {
+			return fmt.Errorf("failed to migrate shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
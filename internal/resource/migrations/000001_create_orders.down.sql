@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS product_requests;
+DROP TABLE IF EXISTS orders;
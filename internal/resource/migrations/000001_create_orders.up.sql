@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS orders
+(
+    id              BIGINT       NOT NULL PRIMARY KEY,
+    user_id         BIGINT       NOT NULL,
+    quantity        INT          NOT NULL DEFAULT 0,
+    total           DOUBLE       NOT NULL DEFAULT 0,
+    status          VARCHAR(50)  NOT NULL,
+    hash_value      VARCHAR(255) NULL,
+    created_at      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    version         INT          NOT NULL DEFAULT 0,
+    idempotency_key VARCHAR(255) NULL,
+    deleted_at      DATETIME     NULL,
+    UNIQUE INDEX idx_orders_idempotency_key (idempotency_key),
+    INDEX idx_orders_user_id (user_id),
+    INDEX idx_orders_status (status),
+    INDEX idx_orders_created_at (created_at),
+    INDEX idx_orders_deleted_at (deleted_at)
+);
+
+CREATE TABLE IF NOT EXISTS product_requests
+(
+    id                BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id          BIGINT       NOT NULL,
+    product_id        BIGINT       NOT NULL,
+    quantity          BIGINT       NOT NULL,
+    mark_up           DOUBLE       NOT NULL DEFAULT 0,
+    discount          DOUBLE       NOT NULL DEFAULT 0,
+    final_price       DOUBLE       NOT NULL DEFAULT 0,
+    line_total        DOUBLE       NOT NULL DEFAULT 0,
+    hash_value        VARCHAR(255) NULL,
+    reservation_token VARCHAR(255) NULL,
+    created_at        DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_product_requests_order_id (order_id),
+    CONSTRAINT fk_product_requests_order FOREIGN KEY (order_id) REFERENCES orders (id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id            BIGINT AUTO_INCREMENT PRIMARY KEY,
+    aggregate_id  BIGINT       NOT NULL,
+    event_key     VARCHAR(255) NOT NULL,
+    payload       BLOB         NOT NULL,
+    status        VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    attempts      INT          NOT NULL DEFAULT 0,
+    last_error    TEXT         NULL,
+    trace_context TEXT         NULL,
+    created_at    DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at  DATETIME     NULL,
+    INDEX idx_outbox_status_id (status, id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS order_status_history
+(
+    id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id    BIGINT       NOT NULL,
+    from_status VARCHAR(50)  NOT NULL DEFAULT '',
+    to_status   VARCHAR(50)  NOT NULL,
+    actor       VARCHAR(255) NOT NULL,
+    created_at  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_order_status_history_order_id (order_id, id),
+    CONSTRAINT fk_order_status_history_order FOREIGN KEY (order_id) REFERENCES orders (id)
+);
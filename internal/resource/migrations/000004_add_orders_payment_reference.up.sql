@@ -0,0 +1,2 @@
+ALTER TABLE orders
+    ADD COLUMN payment_reference VARCHAR(255) NULL AFTER status;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGINT AUTO_INCREMENT PRIMARY KEY,
+    subscriber      VARCHAR(255) NOT NULL,
+    event_key       VARCHAR(255) NOT NULL,
+    event_type      VARCHAR(50)  NOT NULL,
+    payload         BLOB         NOT NULL,
+    status          VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    attempts        INT          NOT NULL DEFAULT 0,
+    last_error      TEXT         NULL,
+    next_attempt_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at    DATETIME     NULL,
+    INDEX idx_webhook_deliveries_status_next_attempt (status, next_attempt_at)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE orders
+    ADD COLUMN pricing_estimated BOOLEAN NOT NULL DEFAULT FALSE AFTER payment_reference;
@@ -0,0 +1,4 @@
+ALTER TABLE product_requests
+    DROP COLUMN currency;
+ALTER TABLE orders
+    DROP COLUMN currency;
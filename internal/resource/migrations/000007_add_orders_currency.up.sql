@@ -0,0 +1,4 @@
+ALTER TABLE orders
+    ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT '' AFTER total;
+ALTER TABLE product_requests
+    ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT '' AFTER line_total;
@@ -0,0 +1,5 @@
+ALTER TABLE product_requests
+    DROP COLUMN backordered_qty,
+    DROP COLUMN allow_backorder;
+ALTER TABLE orders
+    DROP COLUMN allow_backorder;
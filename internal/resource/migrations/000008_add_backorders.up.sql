@@ -0,0 +1,5 @@
+ALTER TABLE orders
+    ADD COLUMN allow_backorder BOOLEAN NOT NULL DEFAULT FALSE AFTER pricing_estimated;
+ALTER TABLE product_requests
+    ADD COLUMN allow_backorder BOOLEAN NOT NULL DEFAULT FALSE AFTER currency,
+    ADD COLUMN backordered_qty BIGINT  NOT NULL DEFAULT 0 AFTER allow_backorder;
@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP INDEX idx_orders_tenant_id,
+    DROP COLUMN tenant_id;
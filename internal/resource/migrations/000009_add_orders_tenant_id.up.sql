@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT '' AFTER user_id,
+    ADD INDEX idx_orders_tenant_id (tenant_id);
@@ -0,0 +1,3 @@
+ALTER TABLE outbox
+    ADD COLUMN total_attempts INT NOT NULL DEFAULT 0 AFTER attempts;
+UPDATE outbox SET total_attempts = attempts;
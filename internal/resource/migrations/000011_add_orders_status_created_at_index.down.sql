@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    ADD INDEX idx_orders_status (status),
+    DROP INDEX idx_orders_status_created_at;
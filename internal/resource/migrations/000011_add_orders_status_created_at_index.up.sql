@@ -0,0 +1,4 @@
+-- The composite index serves every query idx_orders_status did, so it replaces it.
+ALTER TABLE orders
+    ADD INDEX idx_orders_status_created_at (status, created_at),
+    DROP INDEX idx_orders_status;
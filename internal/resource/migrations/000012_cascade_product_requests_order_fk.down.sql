@@ -0,0 +1,4 @@
+ALTER TABLE product_requests
+    DROP FOREIGN KEY fk_product_requests_order;
+ALTER TABLE product_requests
+    ADD CONSTRAINT fk_product_requests_order FOREIGN KEY (order_id) REFERENCES orders (id);
@@ -0,0 +1,11 @@
+-- Order lines belong to their order, so purging an order deletes its lines. The status history
+-- keeps its restricting foreign key, so the audit trail is never deleted as a side effect.
+ALTER TABLE product_requests
+    DROP FOREIGN KEY fk_product_requests_order;
+DELETE pr
+FROM product_requests pr
+         LEFT JOIN orders o ON o.id = pr.order_id
+WHERE o.id IS NULL;
+ALTER TABLE product_requests
+    ADD CONSTRAINT fk_product_requests_order FOREIGN KEY (order_id) REFERENCES orders (id)
+        ON DELETE CASCADE ON UPDATE RESTRICT;
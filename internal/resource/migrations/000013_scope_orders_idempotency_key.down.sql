@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    ADD UNIQUE INDEX idx_orders_idempotency_key (idempotency_key),
+    DROP INDEX idx_orders_idempotency;
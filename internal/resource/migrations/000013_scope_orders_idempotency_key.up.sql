@@ -0,0 +1,4 @@
+-- Idempotency keys are chosen by clients, so they are only unique per tenant and user.
+ALTER TABLE orders
+    ADD UNIQUE INDEX idx_orders_idempotency (tenant_id, user_id, idempotency_key),
+    DROP INDEX idx_orders_idempotency_key;
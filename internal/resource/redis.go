@@ -0,0 +1,24 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"order-service/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func InitRedis(appConfig config.Config) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", appConfig.Redis.Host, appConfig.Redis.Port),
+		Password: appConfig.Redis.Password,
+	})
+
+	err := rdb.Ping(context.Background()).Err()
+	if err != nil {
+		log.Fatal("Failed to connect to redis:", err)
+	}
+
+	return rdb
+}
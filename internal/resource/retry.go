@@ -0,0 +1,57 @@
+package resource
+
+import (
+	"order-service/config"
+	"order-service/infrastructure/log"
+	"time"
+)
+
+const (
+	defaultStartupMaxAttempts = 5
+	defaultStartupRetryDelay  = 2 * time.Second
+	defaultStartupMaxDelay    = 30 * time.Second
+)
+
+// Retry calls connect until it succeeds or cfg.MaxAttempts attempts have failed, so a dependency that
+// is still starting during a deploy does not fail the service outright. The delay between attempts
+// starts at cfg.RetryDelay and doubles after each failure, up to cfg.MaxDelay. Unset settings fall
+// back to 5 attempts, a 2s initial delay and a 30s maximum delay.
+//
+// Parameters:
+//   - name: The dependency being connected to, used in the log lines of failed attempts.
+//   - connect: Opens and checks the connection, returning an error if the dependency is unreachable.
+//
+// Returns:
+//   - The error of the last attempt if every attempt failed.
+func Retry(cfg config.Startup, name string, connect func() error) error {
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultStartupMaxAttempts
+	}
+	delay := cfg.RetryDelay
+	if delay <= 0 {
+		delay = defaultStartupRetryDelay
+	}
+	maxDelay := cfg.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultStartupMaxDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			if attempt > 1 {
+				log.Logger.Info().Str("dependency", name).Int("attempt", attempt).Msg("Connected after retrying")
+			}
+			return nil
+		}
+		if attempt >= maxAttempts {
+			log.Logger.Error().Err(err).Str("dependency", name).Int("attempt", attempt).Int("maxAttempts", maxAttempts).Msg("Failed to connect, giving up")
+			return err
+		}
+
+		log.Logger.Warn().Err(err).Str("dependency", name).Int("attempt", attempt).Int("maxAttempts", maxAttempts).Dur("retryIn", delay).Msg("Failed to connect, retrying")
+		time.Sleep(delay)
+		delay = min(delay*2, maxDelay)
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"order-service/internal/repository"
+)
+
+// ActorSystem is recorded as the actor of changes made without a caller, such as by background jobs.
+const ActorSystem = "system"
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor recorded in the status history of orders
+// changed with it, e.g. "user:42".
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFromContext returns the actor stored in ctx, or ActorSystem if there is none.
+func actorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
+
+// WithTenant returns a copy of ctx scoped to tenantID: orders created with it belong to the tenant,
+// and orders of other tenants are not found. An empty tenantID leaves ctx unscoped.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return repository.WithTenant(ctx, tenantID)
+}
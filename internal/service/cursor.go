@@ -0,0 +1,33 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"order-service/internal/entity"
+)
+
+// encodeOrderCursor encodes the position of an order as an opaque, URL-safe cursor.
+func encodeOrderCursor(cursor entity.OrderCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeOrderCursor decodes a cursor produced by encodeOrderCursor, returning ErrInvalidCursor if it is malformed.
+func decodeOrderCursor(cursor string) (*entity.OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	var decoded entity.OrderCursor
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if decoded.ID <= 0 || decoded.CreatedAt.IsZero() {
+		return nil, fmt.Errorf("%w: missing position", ErrInvalidCursor)
+	}
+
+	return &decoded, nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import "errors"
+
+var (
+	// ErrInsufficientStock is returned when a product does not have enough stock for the requested quantity.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrOrderNotFound is returned when the requested order does not exist.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrProductServiceDown is returned when the product service cannot be reached or fails.
+	ErrProductServiceDown = errors.New("product service unavailable")
+	// ErrPricingServiceDown is returned when the pricing service cannot be reached or fails.
+	ErrPricingServiceDown = errors.New("pricing service unavailable")
+	// ErrDownstreamProtocol is returned when a downstream service responds with a body that is not what its API promises,
+	// such as malformed JSON or a missing field, which usually points to a bad deploy of that service.
+	ErrDownstreamProtocol = errors.New("downstream service returned an invalid response")
+	// ErrInvalidStatusTransition is returned when an order cannot move from its current status to the requested one.
+	ErrInvalidStatusTransition = errors.New("invalid order status transition")
+	// ErrConcurrentUpdate is returned when an order was modified since it was read; the caller should reload it and retry.
+	ErrConcurrentUpdate = errors.New("order was modified concurrently")
+	// ErrTooManyLineItems is returned when an order has more product lines than the service accepts.
+	ErrTooManyLineItems = errors.New("too many line items")
+	// ErrPurchaseLimitExceeded is returned when an order would take a user past the quantity of a product they may buy.
+	ErrPurchaseLimitExceeded = errors.New("purchase limit exceeded")
+	// ErrMixedCurrency is returned when the products of an order are priced in different currencies.
+	ErrMixedCurrency = errors.New("order lines are priced in different currencies")
+	// ErrOrderNotRepriceable is returned when an order is repriced after it has left the created status.
+	ErrOrderNotRepriceable = errors.New("order cannot be repriced in its current status")
+	// ErrIdempotencyKeyConflict is returned when an idempotency key resolves to an order of another user or tenant.
+	ErrIdempotencyKeyConflict = errors.New("idempotency key belongs to another caller")
+	// ErrReservationExpired is returned when an unpaid order is paid after its stock reservations expired.
+	ErrReservationExpired = errors.New("stock reservations expired")
+	// ErrOffsetTooLarge is returned when an offset-paginated listing asks for a page too deep to fetch from every shard.
+	ErrOffsetTooLarge = errors.New("offset too large")
+	// ErrInvalidCursor is returned when a pagination cursor was not issued by the service or is corrupted.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
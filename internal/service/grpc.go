@@ -0,0 +1,170 @@
+package service
+
+//go:generate protoc --proto_path=../../proto --go_out=../.. --go_opt=module=order-service --go-grpc_out=../.. --go-grpc_opt=module=order-service product.proto pricing.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/config"
+	"order-service/infrastructure/buildinfo"
+	"order-service/infrastructure/log"
+	"order-service/internal/breaker"
+	"order-service/internal/metrics"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+
+	grpcKeepaliveTime    = 30 * time.Second // Interval between pings on an idle connection
+	grpcKeepaliveTimeout = 10 * time.Second // How long a ping may go unanswered before the connection is closed
+)
+
+// NewGRPCConn opens the long-lived connection to the gRPC service at target. A single connection
+// multiplexes all concurrent calls, so it is created once per service and shared.
+// The connection is established lazily on the first call and must be closed on shutdown.
+func NewGRPCConn(target string) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUserAgent(buildinfo.UserAgent()),
+		// The otelgrpc handler propagates the W3C trace context so downstream spans join the caller's trace.
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    grpcKeepaliveTime,
+			Timeout: grpcKeepaliveTimeout,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection to %s: %w", target, err)
+	}
+	return conn, nil
+}
+
+// grpcDownstream calls one gRPC service, retrying transient failures behind the service's
+// circuit breaker. The gRPC clients of each service embed it.
+type grpcDownstream struct {
+	Conn           *grpc.ClientConn        // Shared connection to the downstream service
+	Timeout        time.Duration           // Deadline of each attempt, shortened by an earlier deadline on the caller's context
+	MaxRetries     int                     // Maximum retries for transient downstream failures
+	RetryBaseDelay time.Duration           // Initial backoff between downstream retries
+	Breaker        *breaker.CircuitBreaker // Breaker of the downstream service, named after it
+}
+
+// newGRPCDownstream creates the downstream of the named service reached through conn.
+func newGRPCDownstream(name string, conn *grpc.ClientConn, services config.Services) grpcDownstream {
+	timeout := services.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return grpcDownstream{
+		Conn:           conn,
+		Timeout:        timeout,
+		MaxRetries:     services.MaxRetries,
+		RetryBaseDelay: services.BaseDelay,
+		Breaker:        newCircuitBreaker(name, services.CircuitBreaker),
+	}
+}
+
+// invoke runs call guarded by the service's circuit breaker, retrying it up to MaxRetries times
+// with exponential backoff and jitter while it fails with a transient status. Each attempt gets its
+// own deadline derived from ctx. Transient statuses count as breaker failures; caller cancellations count
+// as neither a success nor a failure.
+//
+// Parameters:
+//   - method: The name of the called RPC, used in logs.
+//   - call: The RPC to run with the attempt's context.
+//
+// Returns:
+//   - breaker.ErrServiceUnavailable if the breaker is open, or the error of the last attempt otherwise.
+func (d *grpcDownstream) invoke(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	return d.invokeWithRetries(ctx, method, d.MaxRetries, call)
+}
+
+// invokeOnce runs call exactly once, guarded by the service's circuit breaker. It is used for RPCs
+// that are not idempotent, where a retry after a timeout could repeat a change the first attempt
+// already made.
+func (d *grpcDownstream) invokeOnce(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	return d.invokeWithRetries(ctx, method, 0, call)
+}
+
+// invokeWithRetries runs call with up to maxRetries retries, guarded by the service's circuit breaker.
+func (d *grpcDownstream) invokeWithRetries(ctx context.Context, method string, maxRetries int, call func(ctx context.Context) error) error {
+	cb := d.Breaker
+	start := time.Now()
+	defer func() {
+		metrics.DownstreamRequestDuration.WithLabelValues(cb.Name()).Observe(time.Since(start).Seconds())
+	}()
+
+	var callErr error
+	err := cb.Execute(func() error {
+		callErr = d.callWithRetry(ctx, method, maxRetries, call)
+		if callErr != nil && ctx.Err() != nil {
+			// A call cancelled by the caller says nothing about the service's health.
+			return fmt.Errorf("%w: %w", breaker.ErrOutcomeIgnored, ctx.Err())
+		}
+		if callErr != nil && isTransientStatus(callErr) {
+			return callErr
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, breaker.ErrOutcomeIgnored) {
+		metrics.DownstreamRequestErrors.WithLabelValues(cb.Name()).Inc()
+	}
+	if errors.Is(err, breaker.ErrServiceUnavailable) {
+		log.FromContext(ctx).Warn().Str("service", cb.Name()).Str("method", method).Msg("Circuit breaker open, skipping downstream request")
+		return fmt.Errorf("%s service: %w", cb.Name(), err)
+	}
+
+	return callErr
+}
+
+func (d *grpcDownstream) callWithRetry(ctx context.Context, method string, maxRetries int, call func(ctx context.Context) error) error {
+	baseDelay := d.RetryBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, buildinfo.CallerServiceHeader, buildinfo.ServiceName)
+	if requestID := log.RequestIDFromContext(ctx); requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, log.RequestIDHeader, requestID)
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, d.Timeout)
+		err := call(attemptCtx)
+		cancel()
+		if err == nil || attempt >= maxRetries || ctx.Err() != nil || !isTransientStatus(err) {
+			return err
+		}
+
+		log.FromContext(ctx).Warn().Err(err).Str("method", method).Int("attempt", attempt+1).Msg("Retrying downstream request")
+
+		timer := time.NewTimer(backoffDelay(baseDelay, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransientStatus reports whether err is a gRPC status worth retrying, the gRPC
+// counterpart of a network error or 5xx response.
+func isTransientStatus(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/metrics"
+	"order-service/internal/repository"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+// CreateOrderResult is the outcome of creating one order of a batch.
+// Exactly one of Order and Err is set.
+type CreateOrderResult struct {
+	Order *entity.Order
+	Err   error
+}
+
+// CreateOrders creates a batch of orders. Purchase limits are checked per order as CreateOrder does,
+// pricing is fetched once per distinct product across the whole batch, and stock is reserved for
+// every line. Unlike CreateOrder, there is no stock pre-check before reserving: the reservations are
+// what decide, and a pre-check per order would only add calls to the product service. Orders whose
+// limits, reservations or pricing fail are rejected individually, and the remaining orders are written
+// in one transaction per shard, since orders are spread across shards by ID, each under a savepoint so
+// an order that fails to be written does not fail the others.
+//
+// Parameters:
+//   - orders: The orders to create. Orders already rejected by the caller may be passed as nil.
+//
+// Returns:
+//   - One result per input order, in the same order. Results for nil orders are left empty.
+func (s *orderService) CreateOrders(ctx context.Context, orders []*entity.Order) []CreateOrderResult {
+	results := make([]CreateOrderResult, len(orders))
+
+	// Only valid orders reach the downstream services; the others fail without touching stock.
+	valid := make([]bool, len(orders))
+	var productRequests []entity.OrderRequest
+	for i, order := range orders {
+		if order == nil {
+			continue
+		}
+		if len(order.ProductRequests) == 0 {
+			results[i].Err = errors.New("order must contain at least one product")
+			continue
+		}
+		if err := s.checkLineItems(ctx, order); err != nil {
+			results[i].Err = err
+			continue
+		}
+		valid[i] = true
+		order.Status = entity.OrderStatusCreated
+		order.PaymentReference = ""
+	}
+
+	// Purchase limits are checked before any stock is reserved. Each user's lock is held until every
+	// shard transaction has committed, so the user's concurrent creates count this batch's orders.
+	unlockPurchaseLimits := s.checkBatchPurchaseLimits(ctx, orders, valid, results)
+	defer unlockPurchaseLimits()
+
+	for i, order := range orders {
+		if valid[i] {
+			productRequests = append(productRequests, order.ProductRequests...)
+		}
+	}
+
+	// Pricing and reservations are independent, so they run concurrently in one pool of at most
+	// MaxConcurrency calls, however many orders and lines the batch holds. Failures are recorded
+	// per product and per order instead of cancelling the group, so one bad item cannot fail the batch.
+	var group errgroup.Group
+	group.SetLimit(s.MaxConcurrency)
+	var pricingMu sync.Mutex
+	pricingResults := make(map[int64]entity.PricingChannel)
+	pricingErrs := make(map[int64]error)
+
+	for productID := range uniqueProductIDs(productRequests) {
+		group.Go(func() error {
+			pricing, err := s.getPricing(ctx, productID)
+
+			pricingMu.Lock()
+			defer pricingMu.Unlock()
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to get pricing for product")
+				pricingErrs[productID] = fmt.Errorf("failed to get pricing for product ID %d: %w", productID, err)
+				return nil
+			}
+			pricingResults[productID] = entity.PricingChannel{
+				ProductID:  productID,
+				FinalPrice: pricing.FinalPrice,
+				MarkUp:     pricing.MarkUp,
+				Discount:   pricing.Discount,
+				Currency:   s.pricingCurrency(pricing),
+				Estimated:  pricing.Estimated,
+			}
+			return nil
+		})
+	}
+
+	var reserveMu sync.Mutex
+	for i, order := range orders {
+		if !valid[i] {
+			continue
+		}
+		for j := range order.ProductRequests {
+			group.Go(func() error {
+				// Lines of an order that already failed are skipped, as the order will not be created.
+				reserveMu.Lock()
+				failed := results[i].Err != nil
+				reserveMu.Unlock()
+				if failed {
+					return nil
+				}
+
+				// The line records its own reservation, so only the shared result needs the lock.
+				err := s.reserveLineStock(ctx, order, j)
+
+				reserveMu.Lock()
+				defer reserveMu.Unlock()
+				if err != nil && results[i].Err == nil {
+					results[i].Err = err
+				}
+				return nil
+			})
+		}
+	}
+
+	_ = group.Wait()
+
+	// Orders that passed are grouped by the shard their ID maps to.
+	shardOrders := make(map[int][]int)
+	for i, order := range orders {
+		if !valid[i] {
+			continue
+		}
+		if results[i].Err == nil {
+			results[i].Err = firstPricingError(order, pricingErrs)
+		}
+		if results[i].Err == nil {
+			results[i].Err = applyPricing(order, pricingResults)
+		}
+		if results[i].Err != nil {
+			s.releaseReservations(ctx, order)
+			continue
+		}
+
+		order.ID = s.OrderRepository.NewOrderID()
+		shard := s.OrderRepository.ShardOf(order.ID)
+		shardOrders[shard] = append(shardOrders[shard], i)
+	}
+
+	for _, indexes := range shardOrders {
+		// Each order is written under its own savepoint, so an order that fails to be written, such as
+		// one whose idempotency key is taken, is rolled back alone and the rest of the shard commits.
+		err := s.OrderRepository.WithTransaction(ctx, orders[indexes[0]].ID, func(tx *gorm.DB) error {
+			for _, i := range indexes {
+				err := s.OrderRepository.WithSavepoint(ctx, tx, fmt.Sprintf("order_%d", i), func(tx *gorm.DB) error {
+					return s.createOrderTx(ctx, tx, orders[i])
+				})
+				if errors.Is(err, repository.ErrSavepoint) {
+					return err
+				}
+				results[i].Err = err
+			}
+			return nil
+		})
+
+		for _, i := range indexes {
+			if err != nil && results[i].Err == nil {
+				results[i].Err = err
+			}
+			if results[i].Err != nil {
+				s.releaseReservations(ctx, orders[i])
+				continue
+			}
+			s.setReservationExpiry(orders[i])
+			results[i].Order = orders[i]
+			metrics.OrdersTotal.WithLabelValues(orders[i].Status).Inc()
+		}
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Int("orders", len(indexes)).Msg("Batch transaction failed, rolling back")
+		}
+	}
+
+	return results
+}
+
+// checkBatchPurchaseLimits checks the valid orders of a batch against the purchase limits, user by
+// user, recording ErrPurchaseLimitExceeded or the failed check in results and marking those orders
+// invalid. Locks are taken in user ID order so concurrent batches cannot wait on each other. The
+// returned function releases every lock taken.
+func (s *orderService) checkBatchPurchaseLimits(ctx context.Context, orders []*entity.Order, valid []bool, results []CreateOrderResult) func() {
+	userOrders := make(map[int64][]int)
+	var userIDs []int64
+	for i, order := range orders {
+		if !valid[i] {
+			continue
+		}
+		if _, ok := userOrders[order.UserID]; !ok {
+			userIDs = append(userIDs, order.UserID)
+		}
+		userOrders[order.UserID] = append(userOrders[order.UserID], i)
+	}
+	slices.Sort(userIDs)
+
+	var unlocks []func()
+	for _, userID := range userIDs {
+		indexes := userOrders[userID]
+		checked := make([]*entity.Order, len(indexes))
+		for k, i := range indexes {
+			checked[k] = orders[i]
+		}
+
+		unlock, errs, err := s.checkUserPurchaseLimits(ctx, userID, checked)
+		for k, i := range indexes {
+			orderErr := err
+			if orderErr == nil {
+				orderErr = errs[k]
+			}
+			if orderErr != nil {
+				results[i].Err = orderErr
+				valid[i] = false
+			}
+		}
+		if err == nil {
+			unlocks = append(unlocks, unlock)
+		}
+	}
+
+	return func() {
+		for _, unlock := range unlocks {
+			unlock()
+		}
+	}
+}
+
+// firstPricingError returns the pricing error of the first product in the order that could not be priced.
+func firstPricingError(order *entity.Order, pricingErrs map[int64]error) error {
+	for _, productRequest := range order.ProductRequests {
+		if err, ok := pricingErrs[productRequest.ProductID]; ok {
+			return err
+		}
+	}
+	return nil
+}
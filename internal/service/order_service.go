@@ -3,40 +3,247 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"order-service/config"
+	"order-service/infrastructure/buildinfo"
 	"order-service/infrastructure/log"
+	"order-service/internal/breaker"
 	"order-service/internal/entity"
+	"order-service/internal/metrics"
 	"order-service/internal/repository"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/segmentio/kafka-go"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+
+	bulkCancelBatchSize     = 100 // Orders read and cancelled per batch of a bulk cancel
+	maxReportedCancelErrors = 100 // Failures listed in a bulk cancel result, the rest are only counted
+
+	idempotencyKeyTTL  = 24 * time.Hour         // How long a created order is remembered for an idempotency key
+	idempotencyLockTTL = 30 * time.Second       // Upper bound on how long a create may hold the key lock
+	lockPollInterval   = 100 * time.Millisecond // Interval between attempts to acquire a held lock
+
+	defaultHTTPTimeout         = 5 * time.Second
+	defaultMaxIdleConnsPerHost = 100
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultPricingCacheTTL     = 5 * time.Second
+	defaultStockLockTTL        = 5 * time.Second
+	defaultMaxLineItems        = 100
+	defaultMaxConcurrency      = 10
+	defaultLastKnownPricingTTL = 24 * time.Hour
+	defaultBaseCurrency        = "USD"
+
+	// purchaseLimitLockTTL bounds how long a user's purchase limit lock outlives a crashed holder.
+	// The lock is held until the order is committed, so it must cover a whole create.
+	purchaseLimitLockTTL = 30 * time.Second
+)
+
+// DefaultReservationTTL is how long an order may stay unpaid before the expiration worker expires it
+// and releases its stock, when no TTL is configured.
+const DefaultReservationTTL = 15 * time.Minute
+
+// Partition key strategies for published order events. Events with the same key land on the same
+// partition and are consumed in order.
+const (
+	PartitionKeyOrder = "order" // Key by order ID, so all events of an order are ordered
+	PartitionKeyUser  = "user"  // Key by user ID, so all events of a user's orders are ordered
+	PartitionKeyEvent = "event" // Key by event type and order ID, e.g. order.created.42, with no ordering across event types
+)
+
 type OrderService interface {
 	// CreateOrder creates a new order with an initial status of "created".
 	CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error)
+	// CreateOrderIdempotent creates a new order at most once per idempotency key.
+	// The returned bool reports whether an order previously created with the key was returned instead.
+	CreateOrderIdempotent(ctx context.Context, order *entity.Order, idempotencyKey string) (*entity.Order, bool, error)
 	// UpdateOrder updates an existing order by modifying its status to "updated".
 	UpdateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error)
+	// PatchOrder changes only the fields set in the patch, leaving the rest of the order as stored.
+	PatchOrder(ctx context.Context, orderId int64, patch entity.OrderPatch) (*entity.Order, error)
+	// PayOrder marks a created order as paid with the given payment reference.
+	PayOrder(ctx context.Context, orderId int64, paymentReference string) (*entity.Order, error)
 	// CancelOrder cancels an existing order by modifying its status to "cancelled".
 	CancelOrder(ctx context.Context, orderId int64) (*entity.Order, error)
+	// QuoteOrder prices an order the way CreateOrder would, without reserving stock or persisting anything.
+	QuoteOrder(ctx context.Context, order *entity.Order) (*entity.OrderQuote, error)
+	// ReleaseOrder gives back the stock reserved by an unpaid order and keeps it as a released draft.
+	ReleaseOrder(ctx context.Context, orderId int64) (*entity.Order, error)
+	// RepriceOrder recomputes the totals of an unpaid order from the current pricing.
+	RepriceOrder(ctx context.Context, orderId int64) (*entity.Order, error)
+	// GetOrder retrieves an existing order by its ID, returning ErrOrderNotFound if it does not exist.
+	GetOrder(ctx context.Context, orderId int64) (*entity.Order, error)
+	// GetOrderReceipt computes a printable summary of an order from the pricing stored on its lines.
+	GetOrderReceipt(ctx context.Context, orderId int64) (*entity.OrderReceipt, error)
+	// GetOrderHistory retrieves the status transitions of an order, oldest first.
+	GetOrderHistory(ctx context.Context, orderId int64) ([]entity.OrderStatusHistory, error)
+	// ListOrders lists orders matching the filter along with the total number of matches.
+	ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, int64, error)
+	// ListAllOrders lists the orders of every user newest first, one cursor page at a time.
+	ListAllOrders(ctx context.Context, cursor string, limit int) (*entity.OrderCursorPage, error)
+	// DeleteOrder soft deletes an order, hiding it from every read while keeping it in storage.
+	DeleteOrder(ctx context.Context, orderId int64) error
+	// PurgeOrder permanently deletes an order and its line items.
+	PurgeOrder(ctx context.Context, orderId int64) error
+	// CreateOrders creates a batch of orders, reporting the outcome of each order separately
+	// so that one failing order does not prevent the others from being created.
+	CreateOrders(ctx context.Context, orders []*entity.Order) []CreateOrderResult
+	// GetPricing returns the pricing new orders of a product would be priced with, reporting whether
+	// it came from the pricing cache and how old the cached entry is.
+	GetPricing(ctx context.Context, productID int64) (*entity.PricingLookup, error)
+	// HandleStockReplenished reacts to a product's stock being replenished by the product service.
+	HandleStockReplenished(ctx context.Context, event entity.StockReplenishedEvent) error
+	// HandlePricingUpdated drops the cached pricing of a product whose price changed.
+	HandlePricingUpdated(ctx context.Context, event entity.PricingUpdatedEvent) error
+	// CancelOrders cancels every order matching the filter batch by batch, releasing their stock and
+	// publishing their cancelled events. A call cut short by ctx returns a cursor to resume from.
+	CancelOrders(ctx context.Context, filter entity.OrderCancelFilter, cursor string) (*entity.OrderCancelResult, error)
+	// ExpireOrders expires up to limit unpaid orders created before olderThan, after the cursor, and
+	// releases their stock. It returns the number of orders that were expired and the cursor of the
+	// next batch, nil once the backlog is exhausted.
+	ExpireOrders(ctx context.Context, olderThan time.Time, after *entity.OrderCursor, limit int) (int, *entity.OrderCursor, error)
 }
 
 // orderService provides methods to manage orders, including creating, updating, and canceling orders.
 type orderService struct {
-	OrderRepository   repository.OrderRepository
-	ProductServiceURL string // URL for the product service, if needed for communication
-	PricingServiceURL string // URL for the pricing service, if needed for communication
-	KafkaWriter       *kafka.Writer
+	OrderRepository  repository.OrderRepository
+	CacheRepository  repository.CacheRepository
+	OutboxRepository repository.OutboxRepository
+	ProductClient    ProductClient         // Checks and reserves stock on the product service
+	PricingClient    PricingClient         // Fetches pricing from the pricing service
+	PricingCacheTTL  time.Duration         // How long pricing is cached in Redis
+	StockLockTTL     time.Duration         // Expiry of the per-product lock held while reserving stock
+	PartitionKey     string                // Strategy used to key published order events, one of the PartitionKey constants
+	MaxLineItems     int                   // Maximum number of product lines accepted in one order
+	MaxConcurrency   int                   // Maximum number of concurrent downstream calls made for one order
+	PricingFallback  bool                  // Whether orders are priced at the last known price while the pricing service is down
+	LastKnownTTL     time.Duration         // How long a fetched price is kept as the fallback
+	CriticalProducts map[int64]bool        // Products that are never priced from the fallback
+	BaseCurrency     string                // Currency of prices returned by the pricing service without one
+	PurchaseLimits   config.PurchaseLimits // Units of a product one user may order
+	ReservationTTL   time.Duration         // How long an order may stay unpaid before it expires, the TTL of the expiration worker
+	pricingFlight    singleflight.Group    // Collapses concurrent pricing cache misses per product and per batch of products
 }
 
-// NewOrderService creates and returns a new instance of orderService.
-func NewOrderService(productRepository repository.OrderRepository, productServiceURL, PricingServiceURL string, kafkaWriter *kafka.Writer) OrderService {
+// NewOrderService creates and returns a new instance of orderService. Stock and pricing are requested
+// through productClient and pricingClient. Unpaid orders are reported to expire reservationTTL after
+// they were created, defaulting to DefaultReservationTTL, which must match the TTL of the expiration
+// worker. Published order events are keyed by partitionKey, one of the PartitionKey constants,
+// defaulting to PartitionKeyOrder.
+func NewOrderService(productRepository repository.OrderRepository, cacheRepository repository.CacheRepository, outboxRepository repository.OutboxRepository, productClient ProductClient, pricingClient PricingClient, services config.Services, reservationTTL time.Duration, partitionKey string) OrderService {
+	pricingCacheTTL := services.PricingCacheTTL
+	if pricingCacheTTL <= 0 {
+		pricingCacheTTL = defaultPricingCacheTTL
+	}
+	stockLockTTL := services.StockLockTTL
+	if stockLockTTL <= 0 {
+		stockLockTTL = defaultStockLockTTL
+	}
+	if partitionKey == "" {
+		partitionKey = PartitionKeyOrder
+	}
+	maxLineItems := services.MaxLineItems
+	if maxLineItems <= 0 {
+		maxLineItems = defaultMaxLineItems
+	}
+	maxConcurrency := services.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+	lastKnownTTL := services.PricingFallback.LastKnownTTL
+	if lastKnownTTL <= 0 {
+		lastKnownTTL = defaultLastKnownPricingTTL
+	}
+	criticalProducts := make(map[int64]bool, len(services.PricingFallback.CriticalProducts))
+	for _, productID := range services.PricingFallback.CriticalProducts {
+		criticalProducts[productID] = true
+	}
+	baseCurrency := services.BaseCurrency
+	if baseCurrency == "" {
+		baseCurrency = defaultBaseCurrency
+	}
+	if reservationTTL <= 0 {
+		reservationTTL = DefaultReservationTTL
+	}
+
 	return &orderService{
-		OrderRepository:   productRepository,
-		ProductServiceURL: productServiceURL,
-		PricingServiceURL: PricingServiceURL,
-		KafkaWriter:       kafkaWriter,
+		OrderRepository:  productRepository,
+		CacheRepository:  cacheRepository,
+		OutboxRepository: outboxRepository,
+		ProductClient:    productClient,
+		PricingClient:    pricingClient,
+		PricingCacheTTL:  pricingCacheTTL,
+		StockLockTTL:     stockLockTTL,
+		PartitionKey:     partitionKey,
+		MaxLineItems:     maxLineItems,
+		MaxConcurrency:   maxConcurrency,
+		PricingFallback:  services.PricingFallback.Enabled,
+		LastKnownTTL:     lastKnownTTL,
+		CriticalProducts: criticalProducts,
+		BaseCurrency:     baseCurrency,
+		PurchaseLimits:   services.PurchaseLimits,
+		ReservationTTL:   reservationTTL,
+	}
+}
+
+// newCircuitBreaker creates a breaker for the named downstream service that logs its state transitions
+// and exports its state and transitions as metrics.
+func newCircuitBreaker(name string, cfg config.CircuitBreaker) *breaker.CircuitBreaker {
+	cb := breaker.New(name, breaker.Config{
+		FailureThreshold: cfg.FailureThreshold,
+		MinRequests:      cfg.MinRequests,
+		Window:           cfg.Window,
+		Cooldown:         cfg.Cooldown,
+	})
+	cb.OnStateChange(func(name string, from, to breaker.State) {
+		log.Logger.Warn().Str("service", name).Str("from", from.String()).Str("to", to.String()).Msg("Circuit breaker state changed")
+		metrics.CircuitBreakerTransitions.WithLabelValues(name, from.String(), to.String()).Inc()
+	})
+	metrics.RegisterCircuitBreaker(cb)
+	return cb
+}
+
+// NewHTTPClient builds the pooled client shared by all downstream calls.
+// Unset values fall back to a 5s timeout, 100 idle connections per host and a 90s idle timeout.
+func NewHTTPClient(services config.Services) *http.Client {
+	timeout := services.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	maxIdleConnsPerHost := services.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	idleConnTimeout := services.IdleConnTimeout
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = defaultIdleConnTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConnsPerHost * 2
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.IdleConnTimeout = idleConnTimeout
+
+	// The otelhttp transport injects the W3C traceparent header so downstream spans join the caller's trace.
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(buildinfo.IdentifyRequests(metrics.InstrumentConnections(transport))),
 	}
 }
 
@@ -52,252 +259,1776 @@ func NewOrderService(productRepository repository.OrderRepository, productServic
 func (s *orderService) CreateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
 	// Logic to create an order
 	// This could involve saving the order to a database, etc.
-	var totalPrice float64
+	timer := prometheus.NewTimer(metrics.CreateOrderDuration)
+	defer timer.ObserveDuration()
 
-	availabilityCh := make(chan entity.AvailabilityChannel, len(order.ProductRequests))
-	pricingCh := make(chan entity.PricingChannel, len(order.ProductRequests))
+	err := s.checkLineItems(ctx, order)
+	if err != nil {
+		return nil, err
+	}
 
-	// Launch goroutines to fetch availability and pricing data concurrently
-	for _, productRequest := range order.ProductRequests {
-		go func(productRequest *entity.OrderRequest) {
-			available, err := s.checkProductStock(productRequest.ProductID, productRequest.Quantity)
-			availabilityCh <- entity.AvailabilityChannel{
-				ProductID: productRequest.ProductID,
-				Available: available,
-				Error:     err,
-			}
-		}(&productRequest)
+	unlockPurchaseLimits, err := s.checkPurchaseLimits(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockPurchaseLimits()
 
-		go func(productRequest *entity.OrderRequest) {
-			pricing, err := s.getPricing(productRequest.ProductID)
-			pricingCh <- entity.PricingChannel{
-				ProductID:  productRequest.ProductID,
-				FinalPrice: pricing.FinalPrice,
-				MarkUp:     pricing.MarkUp,
-				Discount:   pricing.Discount,
-				Error:      err,
-			}
-		}(&productRequest)
+	order.Status = entity.OrderStatusCreated
+	order.PaymentReference = ""
+
+	// A product that is already short is rejected with one batched call, before any stock lock is taken
+	// or reservation made, so during a sale the orders that cannot succeed stay cheap for the product service.
+	err = s.checkOrderStock(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reserving rather than checking stock makes the decrement atomic on the product service,
+	// so concurrent orders cannot both pass a check and oversell the same item.
+	err = s.enrichOrderPricing(ctx, order, s.reserveLineStock)
+	if err != nil {
+		s.releaseReservations(ctx, order)
+		return nil, err
 	}
 
-	// Process results from channels
-	// NOTE: Current design doesn't require mapping between availability and pricing channels
-	// since we process them independently (availability for validation, pricing by ProductID matching).
-	// However, for future development where you need to correlate results from multiple channels
-	// for the same product, consider using a map-based approach or combined result channels
-	// to ensure proper pairing of related data.
-	for range order.ProductRequests {
-		availabilityResult := <-availabilityCh
-		pricingResult := <-pricingCh
+	// The ID is assigned up front because it selects the shard the order is written to.
+	order.ID = s.OrderRepository.NewOrderID()
+	err = s.OrderRepository.WithTransaction(ctx, order.ID, func(tx *gorm.DB) error {
+		return s.createOrderTx(ctx, tx, order)
+	})
 
-		if availabilityResult.Error != nil {
-			log.Logger.Error().Err(availabilityResult.Error).Int64("productID", availabilityResult.ProductID).Msg("Failed to check product stock")
-			return nil, fmt.Errorf("failed to check product stock for product ID %d: %w", availabilityResult.ProductID, availabilityResult.Error)
-		}
-		if !availabilityResult.Available {
-			log.Logger.Warn().Int64("productID", availabilityResult.ProductID).Msg("Insufficient stock for product")
-			return nil, fmt.Errorf("insufficient stock for product ID %d", availabilityResult.ProductID)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Transaction failed, rolling back")
+		s.releaseReservations(ctx, order)
+		return nil, err
+	}
+
+	metrics.OrdersTotal.WithLabelValues(order.Status).Inc()
+	s.setReservationExpiry(order)
+	return order, nil
+}
+
+// QuoteOrder prices an order without creating it. Stock is checked and pricing fetched exactly as
+// CreateOrder does, but no stock is reserved, nothing is persisted and no event is published.
+//
+// Parameters:
+//   - order: A pointer to the Order entity to be priced.
+//
+// Returns:
+//   - A pointer to the OrderQuote holding the priced lines and the order total.
+//   - An error if a product is out of stock, cannot be priced or the lines mix currencies.
+func (s *orderService) QuoteOrder(ctx context.Context, order *entity.Order) (*entity.OrderQuote, error) {
+	err := s.checkLineItems(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkOrderStock(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.enrichOrderPricing(ctx, order, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.OrderQuote{
+		ProductRequests:  order.ProductRequests,
+		TotalPrice:       order.TotalPrice,
+		Currency:         order.Currency,
+		PricingEstimated: order.PricingEstimated,
+	}, nil
+}
+
+// lineStockCheck validates the stock of the line at index i of order. It is called concurrently for
+// distinct lines, so it may only write to its own line.
+type lineStockCheck func(ctx context.Context, order *entity.Order, i int) error
+
+// enrichOrderPricing validates the stock of every line of order with checkStock and prices the lines
+// and the order total from the pricing of their products. Stock and pricing are requested concurrently,
+// at most MaxConcurrency calls at a time, and the first error cancels the context passed to the
+// remaining calls so they return early instead of leaking.
+//
+// Parameters:
+//   - order: A pointer to the Order entity whose lines are validated and priced.
+//   - checkStock: Validates the stock of one line, e.g. reserveLineStock, or nil if the stock was already checked.
+//
+// Returns:
+//   - An error wrapping ErrInsufficientStock, ErrProductServiceDown, ErrPricingServiceDown,
+//     ErrDownstreamProtocol or ErrMixedCurrency if the order cannot be priced. The order is left unpriced in that case,
+//     though checkStock may already have written to some lines.
+func (s *orderService) enrichOrderPricing(ctx context.Context, order *entity.Order, checkStock lineStockCheck) error {
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(s.MaxConcurrency)
+
+	pricingResults := make(map[int64]entity.PricingChannel, len(order.ProductRequests))
+
+	if checkStock != nil {
+		for i := range order.ProductRequests {
+			group.Go(func() error {
+				return checkStock(groupCtx, order, i)
+			})
 		}
-		if pricingResult.Error != nil {
-			log.Logger.Error().Err(pricingResult.Error).Int64("productID", pricingResult.ProductID).Msg("Failed to get pricing for product")
-			return nil, fmt.Errorf("failed to get pricing for product ID %d: %w", pricingResult.ProductID, pricingResult.Error)
+	}
+
+	// Pricing only depends on the product, so each product is priced once even if it appears on several lines.
+	productIDs := make([]int64, 0, len(order.ProductRequests))
+	for productID := range uniqueProductIDs(order.ProductRequests) {
+		productIDs = append(productIDs, productID)
+	}
+	group.Go(func() error {
+		pricings, err := s.getPricingBatch(groupCtx, productIDs)
+		if err != nil {
+			return err
 		}
 
-		for _, productRequest := range order.ProductRequests {
-			if productRequest.ProductID == pricingResult.ProductID {
-				productRequest.Discount = pricingResult.Discount
-				productRequest.MarkUp = pricingResult.MarkUp
-				productRequest.FinalPrice = pricingResult.FinalPrice
-				totalPrice += productRequest.FinalPrice
+		// pricingResults is only read once the group is done, so it needs no lock.
+		for productID, pricing := range pricings {
+			pricingResults[productID] = entity.PricingChannel{
+				ProductID:  productID,
+				FinalPrice: pricing.FinalPrice,
+				MarkUp:     pricing.MarkUp,
+				Discount:   pricing.Discount,
+				Currency:   s.pricingCurrency(pricing),
+				Estimated:  pricing.Estimated,
 			}
 		}
+		return nil
+	})
+
+	err := group.Wait()
+	if err != nil {
+		return err
+	}
+
+	err = applyPricing(order, pricingResults)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Msg("Order mixes currencies")
+		return err
 	}
+	return nil
+}
 
-	err := s.OrderRepository.WithTransaction(ctx, func(tx *gorm.DB) error {
-		err := s.OrderRepository.CreateOrderTx(ctx, tx, order)
+// reserveLineStock reserves the stock of the line at index i on the product service and records the
+// reservation token on the line. A line that may be backordered reserves what is available when its
+// product is short and records the remainder as BackorderedQty; other lines fail on a shortage.
+func (s *orderService) reserveLineStock(ctx context.Context, order *entity.Order, i int) error {
+	productRequest := order.ProductRequests[i]
+	if order.AllowBackorder || productRequest.AllowBackorder {
+		token, reserved, err := s.reserveAvailableStock(ctx, productRequest.ProductID, productRequest.Quantity)
 		if err != nil {
-			log.Logger.Error().Err(err).Msg("Failed to create order in transaction")
-			return fmt.Errorf("failed to create order in transaction: %w", err)
+			log.FromContext(ctx).Error().Err(err).Int64("productID", productRequest.ProductID).Msg("Failed to reserve product stock")
+			return fmt.Errorf("failed to reserve stock for product ID %d: %w", productRequest.ProductID, err)
 		}
+		order.ProductRequests[i].ReservationToken = token
+		order.ProductRequests[i].BackorderedQty = productRequest.Quantity - reserved
+		return nil
+	}
 
-		orderRequests := s.mapOrderRequestWithOrderID(order)
-		err = s.OrderRepository.CreateOrderRequestTx(ctx, tx, orderRequests)
-		if err != nil {
-			log.Logger.Error().Err(err).Msg("Failed to create order requests in transaction")
-			return fmt.Errorf("failed to create order requests in transaction: %w", err)
+	token, err := s.reserveStock(ctx, productRequest.ProductID, productRequest.Quantity)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productRequest.ProductID).Msg("Failed to reserve product stock")
+		return fmt.Errorf("failed to reserve stock for product ID %d: %w", productRequest.ProductID, err)
+	}
+	order.ProductRequests[i].ReservationToken = token
+	order.ProductRequests[i].BackorderedQty = 0
+	return nil
+}
+
+// checkOrderStock checks with one batched stock request that the product of every line has enough
+// stock, without reserving it. A line that may be backordered never fails on a shortage; the units that
+// would be backordered are recorded as BackorderedQty instead.
+//
+// Parameters:
+//   - order: A pointer to the Order entity whose lines are checked.
+//
+// Returns:
+//   - ErrInsufficientStock if a line that may not be backordered is short, or another error if the check fails.
+func (s *orderService) checkOrderStock(ctx context.Context, order *entity.Order) error {
+	for i := range order.ProductRequests {
+		order.ProductRequests[i].BackorderedQty = 0
+	}
+
+	stock, err := s.getStockBatch(ctx, stockRequests(order.ProductRequests))
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to check product stock")
+		return fmt.Errorf("failed to check product stock: %w", err)
+	}
+
+	for i, productRequest := range order.ProductRequests {
+		available := stock[productRequest.ProductID]
+		if order.AllowBackorder || productRequest.AllowBackorder {
+			order.ProductRequests[i].BackorderedQty = max(productRequest.Quantity-max(available, 0), 0)
+			continue
+		}
+		if available < productRequest.Quantity {
+			log.FromContext(ctx).Warn().Int64("productID", productRequest.ProductID).Msg("Insufficient stock for product")
+			return fmt.Errorf("%w for product ID %d", ErrInsufficientStock, productRequest.ProductID)
 		}
+	}
+	return nil
+}
 
-		return nil
-	})
+// createOrderTx writes the order, its product requests, its initial status history entry and its created event within tx.
+func (s *orderService) createOrderTx(ctx context.Context, tx *gorm.DB, order *entity.Order) error {
+	// The tenant comes from the caller, never from the request body.
+	order.TenantID = repository.TenantFromContext(ctx)
+	// Versions start at 1 because UpdateOrder reads a zero version as one the client did not send.
+	order.Version = 1
+	err := s.OrderRepository.CreateOrderTx(ctx, tx, order)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to create order in transaction")
+		return fmt.Errorf("failed to create order in transaction: %w", err)
+	}
+	ctx = log.WithOrderID(ctx, order.ID)
 
+	orderRequests := s.mapOrderRequestWithOrderID(order)
+	for i := range orderRequests {
+		// Line IDs are assigned by the repository, never taken from the request body.
+		orderRequests[i].ID = 0
+	}
+	err = s.OrderRepository.CreateOrderRequestTx(ctx, tx, orderRequests)
 	if err != nil {
-		log.Logger.Error().Err(err).Msg("Transaction failed, rolling back")
-		return nil, err
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to create order requests in transaction")
+		return fmt.Errorf("failed to create order requests in transaction: %w", err)
 	}
 
-	err = s.publishOrderCreatedEvent(order, "created")
+	err = s.recordStatusChangeTx(ctx, tx, order.ID, "", order.Status)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", order.ID).Msg("Failed to publish order created event")
-		return nil, fmt.Errorf("failed to publish order created event: %w", err)
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to record order status history")
+		return fmt.Errorf("failed to record order status history: %w", err)
 	}
 
-	return order, nil
+	// The event is stored in the same transaction so it is published if and only if the order is committed.
+	err = s.createOrderEventTx(ctx, tx, order, entity.OrderEventCreated)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to store order created event")
+		return fmt.Errorf("failed to store order created event: %w", err)
+	}
+
+	return nil
 }
 
-// UpdateOrder updates an existing order by modifying its status to "updated".
+// setReservationExpiry sets when an unpaid order's stock reservations expire, ReservationTTL after the
+// order was created, which is the earliest the expiration worker expires it. Orders that are no longer
+// waiting for payment have no expiry.
+func (s *orderService) setReservationExpiry(order *entity.Order) {
+	if order.Status != entity.OrderStatusCreated {
+		order.ReservationExpiresAt = nil
+		return
+	}
+	expiresAt := order.CreatedAt.Add(s.ReservationTTL)
+	order.ReservationExpiresAt = &expiresAt
+}
+
+// checkLineItems rejects orders with more product lines than MaxLineItems, before any downstream call is made.
+func (s *orderService) checkLineItems(ctx context.Context, order *entity.Order) error {
+	if len(order.ProductRequests) > s.MaxLineItems {
+		log.FromContext(ctx).Warn().Int("lineItems", len(order.ProductRequests)).Int("max", s.MaxLineItems).Msg("Order has too many line items")
+		return fmt.Errorf("%w: %d lines, at most %d allowed", ErrTooManyLineItems, len(order.ProductRequests), s.MaxLineItems)
+	}
+	return nil
+}
+
+// checkPurchaseLimits rejects an order that would take its user past the purchase limit of one of its
+// products, counting the user's orders that were neither cancelled nor expired. While the order is
+// checked and created, the user's other creates wait on a lock, so concurrent orders cannot each pass
+// the check and exceed the limit together. The returned function releases the lock and must be called
+// once the order is committed or abandoned.
 //
 // Parameters:
-//   - order: A pointer to the Order entity to be updated.
+//   - order: A pointer to the Order entity being created.
 //
 // Returns:
-//   - A pointer to the updated Order entity.
-//   - An error if the update process fails.
-func (s *orderService) UpdateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
-	// Logic to update an existing order
-	// This could involve updating the order in a database, etc.
+//   - A function releasing the user's purchase limit lock.
+//   - ErrPurchaseLimitExceeded if a product's limit would be exceeded, or another error if the check fails.
+func (s *orderService) checkPurchaseLimits(ctx context.Context, order *entity.Order) (func(), error) {
+	unlock, errs, err := s.checkUserPurchaseLimits(ctx, order.UserID, []*entity.Order{order})
+	if err != nil {
+		return nil, err
+	}
+	if errs[0] != nil {
+		unlock()
+		return nil, errs[0]
+	}
+	return unlock, nil
+}
 
-	if order.Status == "Paid" {
-		for _, orderRequest := range order.ProductRequests {
-			match, err := s.checkProductStock(orderRequest.ProductID, orderRequest.Quantity)
-			if err != nil {
-				log.Logger.Error().Err(err).Int64("productID", orderRequest.ProductID).Msg("Failed to check product stock during order update")
-				return nil, fmt.Errorf("failed to check product stock for product ID %d: %w", orderRequest.ProductID, err)
+// checkUserPurchaseLimits checks several orders of one user against the purchase limits under a single
+// hold of the user's purchase limit lock. The orders are checked in turn, each counting the units of the
+// orders before it that passed, so together they cannot exceed a limit either.
+//
+// Parameters:
+//   - userID: The ID of the user the orders belong to.
+//   - orders: The orders being created for the user.
+//
+// Returns:
+//   - A function releasing the user's purchase limit lock, to be called once the orders are committed or abandoned.
+//   - One error per order, ErrPurchaseLimitExceeded for an order that would exceed a limit and nil otherwise.
+//   - An error if the lock cannot be taken or the previously ordered quantities cannot be read.
+func (s *orderService) checkUserPurchaseLimits(ctx context.Context, userID int64, orders []*entity.Order) (func(), []error, error) {
+	errs := make([]error, len(orders))
+	var productIDs []int64
+	seen := make(map[int64]bool)
+	for _, order := range orders {
+		for _, productRequest := range order.ProductRequests {
+			if s.purchaseLimit(productRequest.ProductID) <= 0 || seen[productRequest.ProductID] {
+				continue
+			}
+			seen[productRequest.ProductID] = true
+			productIDs = append(productIDs, productRequest.ProductID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return func() {}, errs, nil
+	}
+
+	lockKey := purchaseLimitLockKey(userID)
+	lockToken, err := s.acquireLock(ctx, lockKey, purchaseLimitLockTTL)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to acquire purchase limit lock")
+		return nil, nil, fmt.Errorf("failed to acquire purchase limit lock for user ID %d: %w", userID, err)
+	}
+	unlock := func() { s.releaseLock(ctx, lockKey, lockToken) }
+
+	// Orders committed by the previous holder of the lock may not have reached the replicas yet.
+	ordered, err := s.OrderRepository.SumUserProductQuantities(repository.WithPrimary(ctx), userID, productIDs)
+	if err != nil {
+		unlock()
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to sum previously ordered quantities")
+		return nil, nil, fmt.Errorf("failed to sum previously ordered quantities: %w", err)
+	}
+
+	for i, order := range orders {
+		requested := make(map[int64]int64)
+		for _, productRequest := range order.ProductRequests {
+			if seen[productRequest.ProductID] {
+				requested[productRequest.ProductID] += productRequest.Quantity
 			}
+		}
 
-			if !match {
-				log.Logger.Warn().Int64("productID", orderRequest.ProductID).Msg("Insufficient stock for product during order update")
-				return nil, fmt.Errorf("insufficient stock for product ID %d", orderRequest.ProductID)
+		for _, productID := range productIDs {
+			quantity, ok := requested[productID]
+			if !ok {
+				continue
 			}
+			limit := s.purchaseLimit(productID)
+			if ordered[productID]+quantity > limit {
+				log.FromContext(ctx).Warn().Int64("productID", productID).Int64("limit", limit).Int64("ordered", ordered[productID]).Int64("requested", quantity).Msg("Purchase limit exceeded")
+				errs[i] = fmt.Errorf("%w: product ID %d allows %d per user, %d already ordered", ErrPurchaseLimitExceeded, productID, limit, ordered[productID])
+				break
+			}
+		}
+		if errs[i] != nil {
+			continue
+		}
+		for productID, quantity := range requested {
+			ordered[productID] += quantity
 		}
 	}
 
-	updatedOrder, err := s.OrderRepository.UpdateOrder(ctx, order)
-	if err != nil {
-		log.Logger.Error().Err(err).Msg("Failed to update order")
-		return nil, fmt.Errorf("failed to update order: %w", err)
+	return unlock, errs, nil
+}
+
+// purchaseLimit returns the units of a product one user may order, 0 when it is unlimited.
+func (s *orderService) purchaseLimit(productID int64) int64 {
+	if limit, ok := s.PurchaseLimits.Products[productID]; ok {
+		return limit
 	}
-	if updatedOrder == nil {
-		log.Logger.Warn().Int64("orderID", order.ID).Msg("Order not found for update")
-		return nil, fmt.Errorf("order with ID %d not found", order.ID)
+	return s.PurchaseLimits.Default
+}
+
+// applyPricing copies the pricing of each line's product onto the line, prices the line for its
+// quantity and sets the order total from the line totals, overwriting any totals supplied by the client.
+// Totals are only summed within one currency, so an order whose products are priced in different
+// currencies is rejected with ErrMixedCurrency and left unchanged.
+func applyPricing(order *entity.Order, pricingResults map[int64]entity.PricingChannel) error {
+	currency := ""
+	for _, productRequest := range order.ProductRequests {
+		lineCurrency := pricingResults[productRequest.ProductID].Currency
+		if currency == "" {
+			currency = lineCurrency
+			continue
+		}
+		if lineCurrency != currency {
+			return fmt.Errorf("%w: %s and %s", ErrMixedCurrency, currency, lineCurrency)
+		}
 	}
 
-	err = s.publishOrderCreatedEvent(updatedOrder, "updated")
-	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", updatedOrder.ID).Msg("Failed to publish order updated event")
-		return nil, fmt.Errorf("failed to publish order updated event: %w", err)
+	var totalPrice float64
+	estimated := false
+	for i := range order.ProductRequests {
+		pricingResult := pricingResults[order.ProductRequests[i].ProductID]
+		estimated = estimated || pricingResult.Estimated
+		order.ProductRequests[i].Discount = pricingResult.Discount
+		order.ProductRequests[i].MarkUp = pricingResult.MarkUp
+		order.ProductRequests[i].FinalPrice = pricingResult.FinalPrice
+		order.ProductRequests[i].LineTotal = pricingResult.FinalPrice * float64(order.ProductRequests[i].Quantity)
+		order.ProductRequests[i].Currency = currency
+		totalPrice += order.ProductRequests[i].LineTotal
 	}
+	order.TotalPrice = totalPrice
+	order.Currency = currency
+	order.PricingEstimated = estimated
+	return nil
+}
 
-	return updatedOrder, nil
+// pricingCurrency returns the currency of pricing, falling back to BaseCurrency when the pricing
+// service did not report one.
+func (s *orderService) pricingCurrency(pricing *entity.Pricing) string {
+	if pricing.Currency == "" {
+		return s.BaseCurrency
+	}
+	return strings.ToUpper(pricing.Currency)
 }
 
-// CancelOrder cancels an existing order by modifying its status to "cancelled".
+// CreateOrderIdempotent creates a new order unless the order's user already created one with the same
+// idempotency key in the tenant of ctx; keys sent by other users or tenants never match. Concurrent requests with the same key are serialized through a Redis lock so only one order is created.
+// The key is persisted on the order row inside the create transaction, so the mapping can be recovered
+// from the database if the Redis write is lost.
 //
 // Parameters:
-//   - orderId: The ID of the order to be canceled.
+//   - order: A pointer to the Order entity to be created.
+//   - idempotencyKey: The client-supplied key identifying this create attempt.
 //
 // Returns:
-//   - A pointer to the canceled Order entity.
-//   - An error if the cancellation process fails.
-func (s *orderService) CancelOrder(ctx context.Context, orderId int64) (*entity.Order, error) {
-	// Logic to cancel an order
-	// This could involve updating the order status in a database, etc.
-	order, err := s.OrderRepository.GetOrderByID(ctx, orderId)
+//   - A pointer to the created or previously created Order entity.
+//   - True if the order was previously created with the key and no new order was created.
+//   - ErrIdempotencyKeyConflict if the key resolves to another caller's order, or another error if the creation process fails.
+func (s *orderService) CreateOrderIdempotent(ctx context.Context, order *entity.Order, idempotencyKey string) (*entity.Order, bool, error) {
+	existing, err := s.getOrderByIdempotencyKey(ctx, order.UserID, idempotencyKey)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", orderId).Msg("Failed to retrieve order for cancellation")
-		return nil, fmt.Errorf("failed to retrieve order: %w", err)
+		return nil, false, err
+	}
+	if existing != nil {
+		s.setReservationExpiry(existing)
+		return existing, true, nil
 	}
 
-	if order == nil {
-		log.Logger.Warn().Int64("orderID", orderId).Msg("Order not found for cancellation")
-		return nil, fmt.Errorf("order with ID %d not found", orderId)
+	lockKey := idempotencyLockKey(ctx, order.UserID, idempotencyKey)
+	lockToken, err := s.acquireLock(ctx, lockKey, idempotencyLockTTL)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Str("idempotencyKey", idempotencyKey).Msg("Failed to acquire idempotency lock")
+		return nil, false, fmt.Errorf("failed to acquire idempotency lock: %w", err)
 	}
+	defer s.releaseLock(ctx, lockKey, lockToken)
 
-	order.Status = "cancelled" // Simulating a cancellation of the order
-	cancelledOrder, err := s.OrderRepository.UpdateOrder(ctx, order)
+	// Another request may have created the order while we were waiting for the lock.
+	existing, err = s.getOrderByIdempotencyKey(ctx, order.UserID, idempotencyKey)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", orderId).Msg("Failed to cancel order")
-		return nil, fmt.Errorf("failed to cancel order: %w", err)
+		return nil, false, err
+	}
+	if existing != nil {
+		s.setReservationExpiry(existing)
+		return existing, true, nil
 	}
 
-	err = s.publishOrderCreatedEvent(cancelledOrder, "cancelled")
+	order.IdempotencyKey = idempotencyKey
+	createdOrder, err := s.CreateOrder(ctx, order)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", cancelledOrder.ID).Msg("Failed to publish order cancelled event")
-		return nil, fmt.Errorf("failed to publish order cancelled event: %w", err)
+		return nil, false, err
 	}
 
-	return cancelledOrder, nil
+	err = s.CacheRepository.SetWithTTL(ctx, idempotencyCacheKey(ctx, order.UserID, idempotencyKey), createdOrder.ID, idempotencyKeyTTL)
+	if err != nil {
+		// The key is stored on the order row, so later lookups fall back to the database.
+		log.FromContext(ctx).Error().Err(err).Str("idempotencyKey", idempotencyKey).Int64("orderID", createdOrder.ID).Msg("Failed to cache idempotency key")
+	}
+
+	return createdOrder, false, nil
 }
 
-func (s *orderService) checkProductStock(productID int64, quantity int64) (bool, error) {
-	response, err := http.Get(fmt.Sprintf("%s/product/%d/stock", s.ProductServiceURL, productID))
+// UpdateOrder updates an existing order by modifying its status to "updated".
+// Only the status and version of order are applied; every other field keeps its stored value, so
+// clients cannot change the owner, totals or lines of an order. The update only applies if the
+// order is still at order.Version; a zero version is taken to mean the version currently stored.
+//
+// Parameters:
+//   - order: A pointer to the Order entity carrying the ID, status and version to update to.
+//
+// Returns:
+//   - A pointer to the updated Order entity, as stored.
+//   - ErrConcurrentUpdate if the order was modified since it was read, or another error if the update process fails.
+func (s *orderService) UpdateOrder(ctx context.Context, order *entity.Order) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, order.ID)
+	// The transition is decided on the current state, which a lagging replica may not have yet.
+	existingOrder, err := s.GetOrder(repository.WithPrimary(ctx), order.ID)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("productID", productID).Msg("Failed to check product stock")
-		return false, fmt.Errorf("failed to check product stock: %w", err)
+		return nil, err
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		log.Logger.Error().Int64("productID", productID).Int("statusCode", response.StatusCode).Msg("Failed to check product stock")
-		return false, fmt.Errorf("failed to check product stock, status code: %d", response.StatusCode)
+	updatedOrder := *existingOrder
+	updatedOrder.Status = order.Status
+	// Clients that did not read a version are checked against the version loaded here,
+	// which still guards the update against changes made since this check.
+	if order.Version != 0 {
+		updatedOrder.Version = order.Version
 	}
 
-	var stockResponse map[string]int
-	err = json.NewDecoder(response.Body).Decode(&stockResponse)
+	return s.applyOrderUpdate(ctx, existingOrder, &updatedOrder)
+}
+
+// PatchOrder applies the fields set in the patch to an existing order, leaving every other field
+// as stored. The patch goes through the same transition and stock checks as UpdateOrder.
+//
+// Parameters:
+//   - orderId: The ID of the order to patch.
+//   - patch: The fields to change; nil fields are left unchanged.
+//
+// Returns:
+//   - A pointer to the updated Order entity.
+//   - ErrOrderNotFound, ErrInvalidStatusTransition or ErrConcurrentUpdate, or another error if the update process fails.
+func (s *orderService) PatchOrder(ctx context.Context, orderId int64, patch entity.OrderPatch) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, orderId)
+	existingOrder, err := s.GetOrder(repository.WithPrimary(ctx), orderId)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("productID", productID).Msg("Failed to decode stock response")
-		return false, fmt.Errorf("failed to decode stock response: %w", err)
+		return nil, err
 	}
 
-	productStock, exists := stockResponse["stock"]
-	if !exists {
-		log.Logger.Warn().Int64("productID", productID).Msg("Stock information not found for product")
-		return false, fmt.Errorf("stock information not found for product ID %d", productID)
+	order := *existingOrder
+	if patch.Status != nil {
+		order.Status = *patch.Status
+	}
+	if patch.Version != nil {
+		order.Version = *patch.Version
 	}
 
-	return productStock >= int(quantity), nil
+	return s.applyOrderUpdate(ctx, existingOrder, &order)
 }
 
-func (s *orderService) getPricing(productID int64) (*entity.Pricing, error) {
-	response, err := http.Get(fmt.Sprintf("%s/product/%d/price", s.PricingServiceURL, productID))
-	if err != nil {
-		log.Logger.Error().Err(err).Int64("productID", productID).Msg("Failed to get product pricing")
-		return nil, fmt.Errorf("failed to get product pricing: %w", err)
+// applyOrderUpdate validates the change from existingOrder to order and saves order with an updated event.
+func (s *orderService) applyOrderUpdate(ctx context.Context, existingOrder, order *entity.Order) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, order.ID)
+	if !entity.CanTransition(existingOrder.Status, order.Status) {
+		log.FromContext(ctx).Warn().Str("from", existingOrder.Status).Str("to", order.Status).Msg("Invalid order status transition")
+		return nil, fmt.Errorf("%w: from %q to %q", ErrInvalidStatusTransition, existingOrder.Status, order.Status)
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		log.Logger.Error().Int64("productID", productID).Int("statusCode", response.StatusCode).Msg("Failed to get product pricing")
-		return nil, fmt.Errorf("failed to get product pricing, status code: %d", response.StatusCode)
+	// Payment goes through PayOrder, which checks stock and records the payment reference.
+	if order.Status == entity.OrderStatusPaid && existingOrder.Status != entity.OrderStatusPaid {
+		log.FromContext(ctx).Warn().Msg("Order can only be paid through the pay endpoint")
+		return nil, fmt.Errorf("%w: orders are paid with PayOrder", ErrInvalidStatusTransition)
 	}
 
-	var pricing entity.Pricing
-	err = json.NewDecoder(response.Body).Decode(&pricing)
+	updatedOrder, err := s.updateOrderWithEvent(ctx, order, existingOrder.Status, entity.OrderEventUpdated)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("productID", productID).Msg("Failed to decode pricing response")
-		return nil, fmt.Errorf("failed to decode pricing response: %w", err)
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to update order")
+		return nil, fmt.Errorf("failed to update order: %w", err)
 	}
 
-	return &pricing, nil
+	if existingOrder.Status != updatedOrder.Status {
+		metrics.OrdersTotal.WithLabelValues(updatedOrder.Status).Inc()
+	}
+
+	return updatedOrder, nil
 }
 
-func (s *orderService) publishOrderCreatedEvent(order *entity.Order, key string) error {
-	orderJson, err := json.Marshal(order)
+// PayOrder confirms payment of an order: it checks that the order's stock reservations have not expired,
+// moves the order to "paid", stores the payment reference and publishes an order.paid event. Repeating the call with the
+// reference the order was paid with returns the order unchanged.
+//
+// Parameters:
+//   - orderId: The ID of the order to pay.
+//   - paymentReference: The reference of the payment from the payment provider.
+//
+// Returns:
+//   - A pointer to the paid Order entity.
+//   - ErrOrderNotFound, ErrInvalidStatusTransition, ErrReservationExpired or ErrConcurrentUpdate,
+//     or another error if the payment cannot be recorded.
+func (s *orderService) PayOrder(ctx context.Context, orderId int64, paymentReference string) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, orderId)
+	order, err := s.GetOrder(repository.WithPrimary(ctx), orderId)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if order.Status == entity.OrderStatusPaid && order.PaymentReference == paymentReference {
+		log.FromContext(ctx).Info().Msg("Order already paid with this payment reference")
+		return order, nil
+	}
+
+	if order.Status == entity.OrderStatusPaid || !entity.CanTransition(order.Status, entity.OrderStatusPaid) {
+		log.FromContext(ctx).Warn().Str("status", order.Status).Msg("Order cannot be paid in its current status")
+		return nil, fmt.Errorf("%w: from %q to %q", ErrInvalidStatusTransition, order.Status, entity.OrderStatusPaid)
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(fmt.Sprintf("order.%s.%d", key, order.ID)),
-		Value: orderJson,
+	// The order's units were taken out of the available stock when they were reserved, so rather than
+	// counting free stock again, payment only checks that the reservations have not run out.
+	if order.ReservationExpiresAt != nil && !time.Now().Before(*order.ReservationExpiresAt) {
+		log.FromContext(ctx).Warn().Time("reservationExpiresAt", *order.ReservationExpiresAt).Msg("Stock reservations expired before payment")
+		return nil, fmt.Errorf("%w: order ID %d at %s", ErrReservationExpired, order.ID, order.ReservationExpiresAt.Format(time.RFC3339))
 	}
 
-	err = s.KafkaWriter.WriteMessages(context.Background(), msg)
+	previousStatus := order.Status
+	order.Status = entity.OrderStatusPaid
+	order.PaymentReference = paymentReference
+	paidOrder, err := s.updateOrderWithEvent(ctx, order, previousStatus, entity.OrderEventPaid)
 	if err != nil {
-		log.Logger.Error().Err(err).Int64("orderID", order.ID).Msg("Failed to publish order created event to Kafka")
-		return fmt.Errorf("failed to publish order created event to Kafka: %w", err)
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to pay order")
+		return nil, fmt.Errorf("failed to pay order: %w", err)
 	}
 
-	return nil
+	metrics.OrdersTotal.WithLabelValues(entity.OrderStatusPaid).Inc()
+
+	return paidOrder, nil
+}
+
+// RepriceOrder fetches the current pricing of every line of an order still in the "created" status,
+// recomputes the line and order totals, and saves them with an order.repriced event in one transaction.
+// Pricing is requested from the pricing service directly, so neither the cache nor the last known
+// pricing fallback is used and the order is no longer flagged as estimated afterwards.
+//
+// Parameters:
+//   - orderId: The ID of the order to reprice.
+//
+// Returns:
+//   - A pointer to the repriced Order entity.
+//   - ErrOrderNotFound, ErrOrderNotRepriceable or ErrConcurrentUpdate, or another error if repricing fails.
+func (s *orderService) RepriceOrder(ctx context.Context, orderId int64) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, orderId)
+	order, err := s.GetOrder(repository.WithPrimary(ctx), orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Status != entity.OrderStatusCreated {
+		log.FromContext(ctx).Warn().Str("status", order.Status).Msg("Order cannot be repriced in its current status")
+		return nil, fmt.Errorf("%w: %q", ErrOrderNotRepriceable, order.Status)
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(s.MaxConcurrency)
+	var pricingMu sync.Mutex
+	pricingResults := make(map[int64]entity.PricingChannel, len(order.ProductRequests))
+	for productID := range uniqueProductIDs(order.ProductRequests) {
+		group.Go(func() error {
+			pricing, err := s.PricingClient.GetPricing(groupCtx, productID)
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to get pricing for product")
+				return fmt.Errorf("failed to get pricing for product ID %d: %w", productID, err)
+			}
+
+			pricingMu.Lock()
+			pricingResults[productID] = entity.PricingChannel{
+				ProductID:  productID,
+				FinalPrice: pricing.FinalPrice,
+				MarkUp:     pricing.MarkUp,
+				Discount:   pricing.Discount,
+				Currency:   s.pricingCurrency(pricing),
+			}
+			pricingMu.Unlock()
+			return nil
+		})
+	}
+	err = group.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyPricing(order, pricingResults)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Msg("Order mixes currencies")
+		return nil, err
+	}
+
+	err = s.OrderRepository.WithTransaction(ctx, order.ID, func(tx *gorm.DB) error {
+		err := s.OrderRepository.UpdateOrderTx(ctx, tx, order)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			log.FromContext(ctx).Warn().Int("version", order.Version).Msg("Order was modified concurrently")
+			return fmt.Errorf("%w: ID %d at version %d", ErrConcurrentUpdate, order.ID, order.Version)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.OrderRepository.UpdateOrderRequestPricingTx(ctx, tx, s.mapOrderRequestWithOrderID(order))
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Msg("Failed to update order line pricing")
+			return fmt.Errorf("failed to update order line pricing: %w", err)
+		}
+
+		err = s.createOrderEventTx(ctx, tx, order, entity.OrderEventRepriced)
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Msg("Failed to store order repriced event")
+			return fmt.Errorf("failed to store order repriced event: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to reprice order")
+		return nil, fmt.Errorf("failed to reprice order: %w", err)
+	}
+
+	log.FromContext(ctx).Info().Float64("totalPrice", order.TotalPrice).Msg("Order repriced")
+	return order, nil
+}
+
+// CancelOrder cancels an existing order by modifying its status to "cancelled", giving back the
+// stock reserved by a created order. An order that is already cancelled is returned unchanged,
+// without publishing another event.
+//
+// Parameters:
+//   - orderId: The ID of the order to be canceled.
+//
+// Returns:
+//   - A pointer to the canceled Order entity.
+//   - An error if the cancellation process fails.
+func (s *orderService) CancelOrder(ctx context.Context, orderId int64) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, orderId)
+	// Logic to cancel an order
+	// This could involve updating the order status in a database, etc.
+	order, err := s.GetOrder(repository.WithPrimary(ctx), orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cancelling an already cancelled order is a no-op, so retried cancels neither save nor republish.
+	if order.Status == entity.OrderStatusCancelled {
+		log.FromContext(ctx).Info().Msg("Order already cancelled")
+		return order, nil
+	}
+
+	if !entity.CanTransition(order.Status, entity.OrderStatusCancelled) {
+		log.FromContext(ctx).Warn().Str("status", order.Status).Msg("Order cannot be cancelled in its current status")
+		return nil, fmt.Errorf("%w: from %q to %q", ErrInvalidStatusTransition, order.Status, entity.OrderStatusCancelled)
+	}
+
+	// The status is saved before the stock is released, so a concurrent payment that wins the
+	// version check keeps its reservations.
+	previousStatus := order.Status
+	order.Status = entity.OrderStatusCancelled
+	cancelledOrder, err := s.updateOrderWithEvent(ctx, order, previousStatus, entity.OrderEventCancelled)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to cancel order")
+		return nil, fmt.Errorf("failed to cancel order: %w", err)
+	}
+
+	// Released orders already gave their stock back.
+	if previousStatus == entity.OrderStatusCreated {
+		s.releaseReservations(ctx, cancelledOrder)
+	}
+	metrics.OrdersTotal.WithLabelValues(entity.OrderStatusCancelled).Inc()
+
+	return cancelledOrder, nil
+}
+
+// ReleaseOrder releases the stock reservations of an unpaid order and moves it to the "released"
+// status, keeping the order so the customer can come back to it. Unlike CancelOrder, the order stays
+// a draft rather than being closed.
+//
+// Parameters:
+//   - orderId: The ID of the order to release.
+//
+// Returns:
+//   - A pointer to the released Order entity.
+//   - ErrInvalidStatusTransition if the order is not in the "created" status, or another error if the release fails.
+func (s *orderService) ReleaseOrder(ctx context.Context, orderId int64) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, orderId)
+	order, err := s.GetOrder(repository.WithPrimary(ctx), orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Releasing an already released order is a no-op, so retried releases neither save nor republish.
+	if order.Status == entity.OrderStatusReleased {
+		log.FromContext(ctx).Info().Msg("Order already released")
+		return order, nil
+	}
+
+	if !entity.CanTransition(order.Status, entity.OrderStatusReleased) {
+		log.FromContext(ctx).Warn().Str("status", order.Status).Msg("Order cannot be released in its current status")
+		return nil, fmt.Errorf("%w: from %q to %q", ErrInvalidStatusTransition, order.Status, entity.OrderStatusReleased)
+	}
+
+	// The status is saved before the stock is released, so a concurrent payment that wins the
+	// version check keeps its reservations.
+	previousStatus := order.Status
+	order.Status = entity.OrderStatusReleased
+	releasedOrder, err := s.updateOrderWithEvent(ctx, order, previousStatus, entity.OrderEventReleased)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to release order")
+		return nil, fmt.Errorf("failed to release order: %w", err)
+	}
+
+	s.releaseReservations(ctx, releasedOrder)
+	metrics.OrdersTotal.WithLabelValues(entity.OrderStatusReleased).Inc()
+	log.FromContext(ctx).Info().Msg("Order released")
+
+	return releasedOrder, nil
+}
+
+// GetOrder retrieves an existing order by its ID.
+//
+// Parameters:
+//   - orderId: The ID of the order to retrieve.
+//
+// Returns:
+//   - A pointer to the Order entity.
+//   - ErrOrderNotFound if the order does not exist, or another error if the retrieval process fails.
+func (s *orderService) GetOrder(ctx context.Context, orderId int64) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, orderId)
+	order, err := s.OrderRepository.GetOrderByID(ctx, orderId)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve order")
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
+	}
+
+	if order == nil {
+		log.FromContext(ctx).Warn().Msg("Order not found")
+		return nil, fmt.Errorf("%w: ID %d", ErrOrderNotFound, orderId)
+	}
+
+	s.setReservationExpiry(order)
+	return order, nil
+}
+
+// GetOrderHistory retrieves the status transitions of an order, oldest first.
+//
+// Parameters:
+//   - orderId: The ID of the order.
+//
+// Returns:
+//   - A slice of OrderStatusHistory entries.
+//   - An error if the retrieval process fails.
+func (s *orderService) GetOrderHistory(ctx context.Context, orderId int64) ([]entity.OrderStatusHistory, error) {
+	ctx = log.WithOrderID(ctx, orderId)
+	history, err := s.OrderRepository.GetStatusHistory(ctx, orderId)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve order status history")
+		return nil, fmt.Errorf("failed to retrieve order status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// ListOrders lists orders matching the given filter.
+// The limit defaults to 20 and is capped at 100 to avoid unbounded scans, and offsets past
+// repository.MaxListOffset are rejected with ErrOffsetTooLarge.
+//
+// Parameters:
+//   - filter: The criteria to filter by, including limit and offset.
+//
+// Returns:
+//   - A slice of Order entities for the requested page.
+//   - The total number of orders matching the filter.
+//   - An error if the retrieval process fails.
+func (s *orderService) ListOrders(ctx context.Context, filter entity.OrderFilter) ([]entity.Order, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	if filter.Offset > repository.MaxListOffset {
+		return nil, 0, fmt.Errorf("%w: %d, at most %d is allowed", ErrOffsetTooLarge, filter.Offset, repository.MaxListOffset)
+	}
+
+	orders, total, err := s.OrderRepository.ListOrders(ctx, filter)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to list orders")
+		return nil, 0, fmt.Errorf("failed to list orders: %w", err)
+	}
+
+	return orders, total, nil
+}
+
+// ListAllOrders lists the orders of every user newest first using keyset pagination across shards.
+// The limit defaults to 20 and is capped at 100.
+//
+// Parameters:
+//   - cursor: The next_cursor of the previous page, or "" for the first page.
+//   - limit: The maximum number of orders to return.
+//
+// Returns:
+//   - The page of orders with the cursor of the next page, which is empty on the last page.
+//   - ErrInvalidCursor if the cursor cannot be decoded, or another error if the retrieval process fails.
+func (s *orderService) ListAllOrders(ctx context.Context, cursor string, limit int) (*entity.OrderCursorPage, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	var after *entity.OrderCursor
+	if cursor != "" {
+		decoded, err := decodeOrderCursor(cursor)
+		if err != nil {
+			log.FromContext(ctx).Warn().Err(err).Msg("Invalid order cursor")
+			return nil, err
+		}
+		after = decoded
+	}
+
+	orders, err := s.OrderRepository.ListOrdersByCursor(ctx, after, limit)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to list orders by cursor")
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+
+	page := &entity.OrderCursorPage{Orders: orders}
+	// A short page means every shard is exhausted.
+	if len(orders) == limit {
+		last := orders[len(orders)-1]
+		page.NextCursor = encodeOrderCursor(entity.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	return page, nil
+}
+
+// DeleteOrder soft deletes an existing order. Unlike cancellation, it does not change
+// the order status; the order is hidden from reads but kept in storage.
+//
+// Parameters:
+//   - orderId: The ID of the order to delete.
+//
+// Returns:
+//   - ErrOrderNotFound if the order does not exist, or another error if the deletion fails.
+func (s *orderService) DeleteOrder(ctx context.Context, orderId int64) error {
+	ctx = log.WithOrderID(ctx, orderId)
+	err := s.OrderRepository.DeleteOrder(ctx, orderId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: ID %d", ErrOrderNotFound, orderId)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to delete order")
+		return fmt.Errorf("failed to delete order: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeOrder permanently deletes an order and its line items, including soft-deleted orders.
+//
+// Parameters:
+//   - orderId: The ID of the order to purge.
+//
+// Returns:
+//   - ErrOrderNotFound if the order does not exist, or another error if the deletion fails.
+func (s *orderService) PurgeOrder(ctx context.Context, orderId int64) error {
+	ctx = log.WithOrderID(ctx, orderId)
+	err := s.OrderRepository.PurgeOrder(ctx, orderId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: ID %d", ErrOrderNotFound, orderId)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to purge order")
+		return fmt.Errorf("failed to purge order: %w", err)
+	}
+
+	log.FromContext(ctx).Info().Msg("Order purged")
+	return nil
+}
+
+// HandleStockReplenished reacts to a stock-replenished event from the product service.
+// No orders wait on stock yet, so the event is only recorded in the logs.
+//
+// Parameters:
+//   - event: The consumed stock-replenished event.
+//
+// Returns:
+//   - An error if the event cannot be handled.
+func (s *orderService) HandleStockReplenished(ctx context.Context, event entity.StockReplenishedEvent) error {
+	log.FromContext(ctx).Info().Int64("productID", event.ProductID).Int64("quantity", event.Quantity).Msg("Product stock replenished")
+	return nil
+}
+
+// HandlePricingUpdated reacts to a pricing-updated event from the pricing service by dropping the
+// product's cached pricing, so the next order fetches the new price.
+//
+// Parameters:
+//   - event: The consumed pricing-updated event.
+//
+// Returns:
+//   - An error if the cached pricing cannot be removed.
+func (s *orderService) HandlePricingUpdated(ctx context.Context, event entity.PricingUpdatedEvent) error {
+	err := s.CacheRepository.Delete(ctx, pricingCacheKey(event.ProductID))
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", event.ProductID).Msg("Failed to invalidate cached pricing")
+		return fmt.Errorf("failed to invalidate cached pricing: %w", err)
+	}
+
+	log.FromContext(ctx).Info().Int64("productID", event.ProductID).Msg("Cached pricing invalidated")
+	return nil
+}
+
+// ExpireOrders moves unpaid orders created before olderThan to the "expired" status, publishes an
+// expired event for each and releases their stock reservations. Orders paid or cancelled since they
+// were read are left untouched.
+//
+// Parameters:
+//   - olderThan: Orders still in the "created" status and created before this time are expired.
+//   - after: The position of the last order of the previous batch, or nil for the first batch.
+//   - limit: The maximum number of orders to expire in this call.
+//
+// Returns:
+//   - The number of orders that were expired.
+//   - The cursor of the next batch, past the orders of this one even when they failed to expire,
+//     or nil when this batch was the last.
+//   - An error if the expired orders cannot be retrieved.
+func (s *orderService) ExpireOrders(ctx context.Context, olderThan time.Time, after *entity.OrderCursor, limit int) (int, *entity.OrderCursor, error) {
+	orders, err := s.OrderRepository.GetOrdersByStatusOlderThan(ctx, entity.OrderStatusCreated, olderThan, after, limit)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve expired pending orders")
+		return 0, nil, fmt.Errorf("failed to retrieve expired pending orders: %w", err)
+	}
+
+	expired := 0
+	for i := range orders {
+		order := &orders[i]
+		orderCtx := log.WithOrderID(ctx, order.ID)
+		ok, err := s.moveOrderStatus(orderCtx, order, entity.OrderStatusCreated, entity.OrderStatusExpired, entity.OrderEventExpired)
+		if err != nil {
+			log.FromContext(orderCtx).Error().Err(err).Msg("Failed to expire order")
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		s.releaseReservations(orderCtx, order)
+		metrics.OrdersTotal.WithLabelValues(entity.OrderStatusExpired).Inc()
+		expired++
+		log.FromContext(orderCtx).Info().Msg("Order expired")
+	}
+
+	if len(orders) < limit {
+		return expired, nil, nil
+	}
+	last := orders[len(orders)-1]
+	return expired, &entity.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+// CancelOrders cancels the orders matching the filter, oldest first, in batches of bulkCancelBatchSize.
+// Each order is moved to the "cancelled" status together with its status history and cancelled event
+// in its own transaction, only if it is still in the filter's status, and the stock reserved by created
+// orders is then released. Orders paid or cancelled since they were read are skipped, so running the
+// same filter again, e.g. after a timeout, only cancels the orders still left.
+//
+// Parameters:
+//   - filter: The orders to cancel. The status defaults to "created"; an empty creation range matches
+//     every order created before the first batch was read.
+//   - cursor: The next_cursor of a previous call cut short, or "" to start from the oldest match.
+//
+// Returns:
+//   - The number of orders cancelled and failed, the first failures, and a cursor to resume from when
+//     ctx was done before every match was handled.
+//   - ErrInvalidCursor if the cursor cannot be decoded, ErrInvalidStatusTransition if the filter's status
+//     cannot be cancelled, or another error if the matching orders cannot be retrieved.
+func (s *orderService) CancelOrders(ctx context.Context, filter entity.OrderCancelFilter, cursor string) (*entity.OrderCancelResult, error) {
+	if filter.Status == "" {
+		filter.Status = entity.OrderStatusCreated
+	}
+	if !entity.CanTransition(filter.Status, entity.OrderStatusCancelled) || filter.Status == entity.OrderStatusCancelled {
+		return nil, fmt.Errorf("%w: from %q to %q", ErrInvalidStatusTransition, filter.Status, entity.OrderStatusCancelled)
+	}
+	// Orders created while the cancel runs are left alone, so a sale still taking orders cannot keep it going.
+	if filter.CreatedBefore == nil {
+		now := time.Now()
+		filter.CreatedBefore = &now
+	}
+
+	var after *entity.OrderCursor
+	if cursor != "" {
+		decoded, err := decodeOrderCursor(cursor)
+		if err != nil {
+			log.FromContext(ctx).Warn().Err(err).Msg("Invalid order cursor")
+			return nil, err
+		}
+		after = decoded
+	}
+
+	result := &entity.OrderCancelResult{Failures: []entity.OrderCancelFailure{}}
+	// interrupted reports the progress made before ctx was done, with the cursor the next call resumes from.
+	interrupted := func() (*entity.OrderCancelResult, error) {
+		if after == nil {
+			return nil, ctx.Err()
+		}
+		result.NextCursor = encodeOrderCursor(*after)
+		log.FromContext(ctx).Warn().Int("cancelled", result.Cancelled).Int("failed", result.Failed).Msg("Bulk cancel interrupted")
+		return result, nil
+	}
+
+	for {
+		orders, err := s.OrderRepository.GetOrdersByCancelFilter(ctx, filter, after, bulkCancelBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+			log.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve orders to cancel")
+			return nil, fmt.Errorf("failed to retrieve orders to cancel: %w", err)
+		}
+
+		for i := range orders {
+			order := &orders[i]
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+
+			orderCtx := log.WithOrderID(ctx, order.ID)
+			ok, err := s.moveOrderStatus(orderCtx, order, filter.Status, entity.OrderStatusCancelled, entity.OrderEventCancelled)
+			if err != nil && ctx.Err() != nil {
+				return interrupted()
+			}
+			after = &entity.OrderCursor{CreatedAt: order.CreatedAt, ID: order.ID}
+			if err != nil {
+				log.FromContext(orderCtx).Error().Err(err).Msg("Failed to cancel order")
+				result.Failed++
+				if len(result.Failures) < maxReportedCancelErrors {
+					result.Failures = append(result.Failures, entity.OrderCancelFailure{OrderID: order.ID, Error: err.Error()})
+				}
+				continue
+			}
+			if !ok {
+				continue
+			}
+
+			// Released orders already gave their stock back.
+			if filter.Status == entity.OrderStatusCreated {
+				s.releaseReservations(orderCtx, order)
+			}
+			metrics.OrdersTotal.WithLabelValues(entity.OrderStatusCancelled).Inc()
+			result.Cancelled++
+		}
+
+		if len(orders) < bulkCancelBatchSize {
+			break
+		}
+	}
+
+	log.FromContext(ctx).Info().Int("cancelled", result.Cancelled).Int("failed", result.Failed).Msg("Bulk cancel completed")
+	return result, nil
+}
+
+// moveOrderStatus moves the order from one status to another together with its status history and
+// the given event, in one transaction. It reports false if the order left the from status in the meantime.
+func (s *orderService) moveOrderStatus(ctx context.Context, order *entity.Order, from, to, eventType string) (bool, error) {
+	ctx = log.WithOrderID(ctx, order.ID)
+	moved := false
+	err := s.OrderRepository.WithTransaction(ctx, order.ID, func(tx *gorm.DB) error {
+		ok, err := s.OrderRepository.UpdateOrderStatusTx(ctx, tx, order.ID, from, to)
+		if err != nil || !ok {
+			return err
+		}
+
+		order.Status = to
+		order.Version++
+		err = s.recordStatusChangeTx(ctx, tx, order.ID, from, to)
+		if err != nil {
+			return fmt.Errorf("failed to record order status history: %w", err)
+		}
+
+		err = s.createOrderEventTx(ctx, tx, order, eventType)
+		if err != nil {
+			return fmt.Errorf("failed to store %s event: %w", eventType, err)
+		}
+
+		moved = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return moved, nil
+}
+
+// getOrderByIdempotencyKey returns the order userID created with idempotencyKey in the tenant of ctx,
+// or nil if there is none. ErrIdempotencyKeyConflict is returned if the key resolves to an order of
+// another user or tenant, which is never replayed.
+func (s *orderService) getOrderByIdempotencyKey(ctx context.Context, userID int64, idempotencyKey string) (*entity.Order, error) {
+	cached, err := s.CacheRepository.Get(ctx, idempotencyCacheKey(ctx, userID, idempotencyKey))
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Str("idempotencyKey", idempotencyKey).Msg("Failed to read idempotency key from cache")
+	}
+
+	if cached != "" {
+		orderId, err := strconv.ParseInt(cached, 10, 64)
+		if err == nil {
+			// The order may have been created moments ago, before it reached the replica.
+			order, err := s.OrderRepository.GetOrderByID(repository.WithPrimary(ctx), orderId)
+			if err != nil {
+				return nil, fmt.Errorf("failed to retrieve order for idempotency key: %w", err)
+			}
+			if order != nil {
+				return checkIdempotentOwner(ctx, order, userID)
+			}
+		}
+	}
+
+	order, err := s.OrderRepository.GetOrderByIdempotencyKey(ctx, userID, idempotencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve order for idempotency key: %w", err)
+	}
+
+	if order == nil {
+		return nil, nil
+	}
+	return checkIdempotentOwner(ctx, order, userID)
+}
+
+// checkIdempotentOwner returns order if it belongs to userID in the tenant of ctx, and
+// ErrIdempotencyKeyConflict otherwise.
+func checkIdempotentOwner(ctx context.Context, order *entity.Order, userID int64) (*entity.Order, error) {
+	if order.UserID != userID || order.TenantID != repository.TenantFromContext(ctx) {
+		log.FromContext(ctx).Warn().Int64("orderID", order.ID).Msg("Idempotency key resolved to an order of another caller")
+		return nil, fmt.Errorf("%w: order ID %d", ErrIdempotencyKeyConflict, order.ID)
+	}
+	return order, nil
+}
+
+// acquireLock takes the Redis lock at key, waiting until it is free or ctx is done. The lock expires
+// after ttl so it cannot outlive a crashed holder. It returns the token that releaseLock needs.
+func (s *orderService) acquireLock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	token := uuid.NewString()
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := s.CacheRepository.SetNX(ctx, key, token, ttl)
+		if err != nil {
+			return "", err
+		}
+		if acquired {
+			return token, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseLock releases a lock taken with acquireLock. A lock that already expired and was taken
+// by someone else is left alone. Release is attempted even if ctx was cancelled.
+func (s *orderService) releaseLock(ctx context.Context, key, token string) {
+	released, err := s.CacheRepository.DeleteIfEqual(context.WithoutCancel(ctx), key, token)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Str("lockKey", key).Msg("Failed to release lock")
+		return
+	}
+	if !released {
+		log.FromContext(ctx).Warn().Str("lockKey", key).Msg("Lock expired before it was released")
+	}
+}
+
+func uniqueProductIDs(orderRequests []entity.OrderRequest) map[int64]struct{} {
+	productIDs := make(map[int64]struct{}, len(orderRequests))
+	for _, orderRequest := range orderRequests {
+		productIDs[orderRequest.ProductID] = struct{}{}
+	}
+	return productIDs
+}
+
+// stockRequests returns one StockRequest per product of orderRequests, for the units of the product
+// the lines still need in stock, i.e. excluding backordered units.
+func stockRequests(orderRequests []entity.OrderRequest) []StockRequest {
+	requests := make([]StockRequest, 0, len(orderRequests))
+	index := make(map[int64]int, len(orderRequests))
+	for _, orderRequest := range orderRequests {
+		i, exists := index[orderRequest.ProductID]
+		if !exists {
+			i = len(requests)
+			index[orderRequest.ProductID] = i
+			requests = append(requests, StockRequest{ProductID: orderRequest.ProductID})
+		}
+		requests[i].Quantity += orderRequest.Quantity - orderRequest.BackorderedQty
+	}
+	return requests
+}
+
+// idempotencyCacheKey returns the Redis key remembering the order userID created with idempotencyKey
+// in the tenant of ctx. Keys are chosen by clients, so they are only unique per tenant and user.
+func idempotencyCacheKey(ctx context.Context, userID int64, idempotencyKey string) string {
+	return fmt.Sprintf("idempotency:%s:%d:%s", repository.TenantFromContext(ctx), userID, idempotencyKey)
+}
+
+// idempotencyLockKey returns the Redis key of the lock serializing creates with the same idempotency key.
+func idempotencyLockKey(ctx context.Context, userID int64, idempotencyKey string) string {
+	return fmt.Sprintf("idempotency:lock:%s:%d:%s", repository.TenantFromContext(ctx), userID, idempotencyKey)
+}
+
+// reserveStock atomically reserves quantity units of a product on the product service,
+// holding the product's stock lock for the duration of the call.
+//
+// Parameters:
+//   - productID: The ID of the product to reserve.
+//   - quantity: The number of units to reserve.
+//
+// Returns:
+//   - The reservation token identifying the reservation, used to release it.
+//   - ErrInsufficientStock if the product does not have enough stock, or another error if the reservation fails.
+func (s *orderService) reserveStock(ctx context.Context, productID int64, quantity int64) (string, error) {
+	// Reservations for the same product are serialized across instances, so a hot product
+	// sees one reservation at a time from this service.
+	lockKey := stockLockKey(productID)
+	lockToken, err := s.acquireLock(ctx, lockKey, s.StockLockTTL)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to acquire stock lock")
+		return "", fmt.Errorf("failed to acquire stock lock for product ID %d: %w", productID, err)
+	}
+	defer s.releaseLock(ctx, lockKey, lockToken)
+
+	return s.ProductClient.ReserveStock(ctx, productID, quantity)
+}
+
+// reserveAvailableStock reserves quantity units of a product, or as many as are in stock when it is
+// short, under the same lock as reserveStock. It returns the reservation token, empty when nothing
+// could be reserved, and the number of units reserved.
+func (s *orderService) reserveAvailableStock(ctx context.Context, productID int64, quantity int64) (string, int64, error) {
+	lockKey := stockLockKey(productID)
+	lockToken, err := s.acquireLock(ctx, lockKey, s.StockLockTTL)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to acquire stock lock")
+		return "", 0, fmt.Errorf("failed to acquire stock lock for product ID %d: %w", productID, err)
+	}
+	defer s.releaseLock(ctx, lockKey, lockToken)
+
+	token, err := s.ProductClient.ReserveStock(ctx, productID, quantity)
+	if !errors.Is(err, ErrInsufficientStock) {
+		if err != nil {
+			return "", 0, err
+		}
+		return token, quantity, nil
+	}
+
+	available, err := s.ProductClient.GetStock(ctx, productID)
+	if err != nil {
+		return "", 0, err
+	}
+	if available <= 0 {
+		log.FromContext(ctx).Info().Int64("productID", productID).Int64("quantity", quantity).Msg("Product out of stock, backordering the line")
+		return "", 0, nil
+	}
+
+	reserved := min(available, quantity)
+	token, err = s.ProductClient.ReserveStock(ctx, productID, reserved)
+	if errors.Is(err, ErrInsufficientStock) {
+		// The stock was taken by a reservation made outside this service since it was read.
+		log.FromContext(ctx).Info().Int64("productID", productID).Int64("quantity", quantity).Msg("Product out of stock, backordering the line")
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	log.FromContext(ctx).Info().Int64("productID", productID).Int64("reserved", reserved).Int64("backordered", quantity-reserved).Msg("Product short of stock, backordering the remainder")
+	return token, reserved, nil
+}
+
+// getStockBatch returns the units in stock of the requested products with a single CheckStockBatch call.
+// When the batch call fails, e.g. because the product service has no batch endpoint, the stock is
+// requested per product instead, at most MaxConcurrency calls at a time.
+//
+// Parameters:
+//   - requests: The products to check and the units needed of each.
+//
+// Returns:
+//   - The units in stock keyed by product ID.
+//   - An error wrapping ErrProductServiceDown or ErrDownstreamProtocol, or another error if the stock of a product cannot be read.
+func (s *orderService) getStockBatch(ctx context.Context, requests []StockRequest) (map[int64]int64, error) {
+	stock, err := s.ProductClient.CheckStockBatch(ctx, requests)
+	if err == nil {
+		return stock, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	log.FromContext(ctx).Warn().Err(err).Int("products", len(requests)).Msg("Batch stock check failed, checking stock per product")
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(s.MaxConcurrency)
+
+	var stockMu sync.Mutex
+	stock = make(map[int64]int64, len(requests))
+	for _, request := range requests {
+		group.Go(func() error {
+			available, err := s.ProductClient.GetStock(groupCtx, request.ProductID)
+			if err != nil {
+				return fmt.Errorf("failed to check stock for product ID %d: %w", request.ProductID, err)
+			}
+
+			stockMu.Lock()
+			stock[request.ProductID] = available
+			stockMu.Unlock()
+			return nil
+		})
+	}
+
+	err = group.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return stock, nil
+}
+
+// releaseReservations releases every stock reservation held by the order's lines.
+// It runs detached from ctx cancellation so a cancelled request still returns its stock.
+func (s *orderService) releaseReservations(ctx context.Context, order *entity.Order) {
+	releaseCtx := context.WithoutCancel(ctx)
+	for i := range order.ProductRequests {
+		productRequest := &order.ProductRequests[i]
+		if productRequest.ReservationToken == "" {
+			continue
+		}
+
+		err := s.ProductClient.ReleaseStock(releaseCtx, productRequest.ProductID, productRequest.ReservationToken)
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Int64("productID", productRequest.ProductID).Str("reservationToken", productRequest.ReservationToken).Msg("Failed to release stock reservation")
+			continue
+		}
+		productRequest.ReservationToken = ""
+	}
+}
+
+// getPricing returns the pricing of a product, served from the Redis cache when possible.
+// Concurrent misses for the same product share a single call to the pricing service, so a hot
+// product whose cache entry expires does not stampede the service. With the pricing fallback
+// enabled, a product that is not critical is priced at its last known pricing, marked as
+// estimated, while the pricing service is unavailable.
+func (s *orderService) getPricing(ctx context.Context, productID int64) (*entity.Pricing, error) {
+	cacheKey := pricingCacheKey(productID)
+	if pricing := s.cachedPricing(ctx, productID); pricing != nil {
+		return pricing, nil
+	}
+
+	// The shared call must not be cancelled by whichever caller happened to start it;
+	// the HTTP client timeout still bounds it.
+	result, err, _ := s.pricingFlight.Do(cacheKey, func() (interface{}, error) {
+		fetchCtx := context.WithoutCancel(ctx)
+		pricing, err := s.PricingClient.GetPricing(fetchCtx, productID)
+		if err != nil {
+			return nil, err
+		}
+
+		s.cachePricing(fetchCtx, productID, pricing)
+		return pricing, nil
+	})
+	if err != nil {
+		if s.PricingFallback && errors.Is(err, ErrPricingServiceDown) && !s.CriticalProducts[productID] {
+			if pricing := s.lastKnownPricing(ctx, productID); pricing != nil {
+				log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Pricing service unavailable, using last known pricing")
+				return pricing, nil
+			}
+		}
+		return nil, err
+	}
+
+	// Callers may modify the result, so each gets its own copy.
+	pricing := *result.(*entity.Pricing)
+	return &pricing, nil
+}
+
+// GetPricing returns the pricing new orders of a product would be priced with, going through the same
+// cache and fallback as order creation. A cache hit reports the entry's age, derived from its remaining
+// TTL, so support can tell a stale price from one the pricing service just returned.
+//
+// Parameters:
+//   - productID: The ID of the product to price.
+//
+// Returns:
+//   - The pricing along with whether it was a cache hit and the cache age.
+//   - An error if the product cannot be priced.
+func (s *orderService) GetPricing(ctx context.Context, productID int64) (*entity.PricingLookup, error) {
+	if pricing := s.cachedPricing(ctx, productID); pricing != nil {
+		lookup := &entity.PricingLookup{Pricing: *pricing, CacheHit: true}
+		remaining, err := s.CacheRepository.TTL(ctx, pricingCacheKey(productID))
+		if err != nil {
+			log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to read cached pricing TTL")
+		} else if remaining > 0 && remaining <= s.PricingCacheTTL {
+			lookup.CacheAgeMs = (s.PricingCacheTTL - remaining).Milliseconds()
+		}
+		return lookup, nil
+	}
+
+	pricing, err := s.getPricing(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.PricingLookup{Pricing: *pricing, Estimated: pricing.Estimated}, nil
+}
+
+// getPricingBatch returns the pricing of several products, served from the Redis cache when possible.
+// The products missing from the cache are fetched from the pricing service with a single GetPricingBatch
+// call; a single missing product is fetched with getPricing instead. When the batch call fails, e.g.
+// because the pricing service has no batch endpoint, the missing products are priced one by one with
+// getPricing, at most MaxConcurrency calls at a time, so the last known pricing fallback still applies.
+//
+// Parameters:
+//   - productIDs: The distinct IDs of the products to price.
+//
+// Returns:
+//   - The pricing keyed by product ID, with an entry for every requested product.
+//   - An error if a product cannot be priced.
+func (s *orderService) getPricingBatch(ctx context.Context, productIDs []int64) (map[int64]*entity.Pricing, error) {
+	results := make(map[int64]*entity.Pricing, len(productIDs))
+	var misses []int64
+	for _, productID := range productIDs {
+		if pricing := s.cachedPricing(ctx, productID); pricing != nil {
+			results[productID] = pricing
+			continue
+		}
+		misses = append(misses, productID)
+	}
+
+	if len(misses) > 1 {
+		pricings, err := s.fetchPricingBatch(ctx, misses)
+		if err == nil {
+			for _, productID := range misses {
+				// Callers may modify the result, so each gets its own copy.
+				pricing := pricings[productID]
+				results[productID] = &pricing
+			}
+			return results, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.FromContext(ctx).Warn().Err(err).Int("products", len(misses)).Msg("Batch pricing failed, pricing per product")
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(s.MaxConcurrency)
+
+	var resultsMu sync.Mutex
+	for _, productID := range misses {
+		group.Go(func() error {
+			pricing, err := s.getPricing(groupCtx, productID)
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to get pricing for product")
+				return fmt.Errorf("failed to get pricing for product ID %d: %w", productID, err)
+			}
+
+			resultsMu.Lock()
+			results[productID] = pricing
+			resultsMu.Unlock()
+			return nil
+		})
+	}
+
+	err := group.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// fetchPricingBatch requests the pricing of several products from the pricing service with
+// GetPricingBatch and caches it. Concurrent fetches of the same products, such as identical carts
+// during a sale, share a single call to the pricing service. Like getPricing, only calls in flight
+// are shared: once the call returns its result and error are forgotten, so a failure is never
+// served to a later fetch.
+//
+// Parameters:
+//   - productIDs: The distinct IDs of the products to price.
+//
+// Returns:
+//   - The pricing keyed by product ID, shared between callers and not to be modified.
+//   - An error if the batch call fails.
+func (s *orderService) fetchPricingBatch(ctx context.Context, productIDs []int64) (map[int64]entity.Pricing, error) {
+	productIDs = slices.Sorted(slices.Values(productIDs))
+
+	// The shared call must not be cancelled by whichever caller happened to start it;
+	// the HTTP client timeout still bounds it.
+	result, err, _ := s.pricingFlight.Do(pricingBatchFlightKey(productIDs), func() (interface{}, error) {
+		fetchCtx := context.WithoutCancel(ctx)
+		pricings, err := s.PricingClient.GetPricingBatch(fetchCtx, productIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		for productID, pricing := range pricings {
+			s.cachePricing(fetchCtx, productID, &pricing)
+		}
+		return pricings, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[int64]entity.Pricing), nil
+}
+
+// cachedPricing returns the cached pricing of a product, or nil if it is not cached or cannot be read.
+func (s *orderService) cachedPricing(ctx context.Context, productID int64) *entity.Pricing {
+	cached, err := s.CacheRepository.Get(ctx, pricingCacheKey(productID))
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to read cached pricing, falling back to the pricing service")
+		return nil
+	}
+	if cached == "" {
+		return nil
+	}
+
+	var pricing entity.Pricing
+	err = json.Unmarshal([]byte(cached), &pricing)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to decode cached pricing")
+		return nil
+	}
+	return &pricing
+}
+
+// cachePricing caches pricing freshly fetched from the pricing service and, with the pricing fallback
+// enabled, stores it as the product's last known pricing. Failures are logged, not returned.
+func (s *orderService) cachePricing(ctx context.Context, productID int64, pricing *entity.Pricing) {
+	pricingJson, err := json.Marshal(pricing)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to cache pricing")
+		return
+	}
+	err = s.CacheRepository.SetWithTTL(ctx, pricingCacheKey(productID), pricingJson, s.PricingCacheTTL)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to cache pricing")
+	}
+	if s.PricingFallback {
+		err = s.CacheRepository.SetWithTTL(ctx, lastKnownPricingKey(productID), pricingJson, s.LastKnownTTL)
+		if err != nil {
+			log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to store last known pricing")
+		}
+	}
+}
+
+// lastKnownPricing returns the last pricing fetched for a product marked as estimated, or nil if none is stored.
+func (s *orderService) lastKnownPricing(ctx context.Context, productID int64) *entity.Pricing {
+	stored, err := s.CacheRepository.Get(ctx, lastKnownPricingKey(productID))
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to read last known pricing")
+		return nil
+	}
+	if stored == "" {
+		return nil
+	}
+
+	var pricing entity.Pricing
+	err = json.Unmarshal([]byte(stored), &pricing)
+	if err != nil {
+		log.FromContext(ctx).Warn().Err(err).Int64("productID", productID).Msg("Failed to decode last known pricing")
+		return nil
+	}
+	pricing.Estimated = true
+	return &pricing
+}
+
+func stockLockKey(productID int64) string {
+	return fmt.Sprintf("stock:lock:%d", productID)
+}
+
+func purchaseLimitLockKey(userID int64) string {
+	return fmt.Sprintf("purchase:lock:%d", userID)
+}
+
+func pricingCacheKey(productID int64) string {
+	return fmt.Sprintf("pricing:%d", productID)
+}
+
+// pricingBatchFlightKey keys a batch pricing call by its sorted product IDs. It never collides with
+// the per-product keys of pricingCacheKey sharing the same singleflight group.
+func pricingBatchFlightKey(productIDs []int64) string {
+	ids := make([]string, len(productIDs))
+	for i, productID := range productIDs {
+		ids[i] = strconv.FormatInt(productID, 10)
+	}
+	return "pricing:batch:" + strings.Join(ids, ",")
+}
+
+func lastKnownPricingKey(productID int64) string {
+	return fmt.Sprintf("pricing:last:%d", productID)
+}
+
+// createOrderEventTx stores an order event of eventType in the outbox within the given transaction,
+// wrapped in the versioned event envelope. The outbox publisher delivers it to Kafka after the transaction commits.
+func (s *orderService) createOrderEventTx(ctx context.Context, tx *gorm.DB, order *entity.Order, eventType string) error {
+	// The ID is generated once and stored in the payload, so every publish attempt of the event
+	// carries the same ID and consumers can drop duplicates.
+	payload, err := json.Marshal(entity.NewOrderEventEnvelope(uuid.NewString(), eventType, order, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	// The trace context and request ID are stored with the event so the publisher can continue the request's trace.
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if requestID := log.RequestIDFromContext(ctx); requestID != "" {
+		carrier.Set(log.RequestIDHeader, requestID)
+	}
+	traceContext, err := json.Marshal(carrier)
+	if err != nil {
+		return err
+	}
+
+	return s.OutboxRepository.CreateOutboxEventTx(ctx, tx, &entity.OutboxEvent{
+		AggregateID:  order.ID,
+		EventKey:     s.eventKey(order, eventType),
+		Payload:      payload,
+		TraceContext: string(traceContext),
+	})
+}
+
+// eventKey returns the Kafka message key of an order event according to the configured partition key strategy.
+// The event type is always carried in the event envelope.
+func (s *orderService) eventKey(order *entity.Order, eventType string) string {
+	switch s.PartitionKey {
+	case PartitionKeyUser:
+		return strconv.FormatInt(order.UserID, 10)
+	case PartitionKeyEvent:
+		return fmt.Sprintf("%s.%d", eventType, order.ID)
+	default:
+		return strconv.FormatInt(order.ID, 10)
+	}
+}
+
+// recordStatusChangeTx appends a status transition of the order to its history within tx,
+// attributed to the actor stored in ctx.
+func (s *orderService) recordStatusChangeTx(ctx context.Context, tx *gorm.DB, orderID int64, from, to string) error {
+	return s.OrderRepository.CreateStatusHistoryTx(ctx, tx, &entity.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actorFromContext(ctx),
+	})
+}
+
+// updateOrderWithEvent saves the order and stores an event of eventType in a single transaction.
+// A change from previousStatus is recorded in the order's status history in the same transaction.
+func (s *orderService) updateOrderWithEvent(ctx context.Context, order *entity.Order, previousStatus, eventType string) (*entity.Order, error) {
+	ctx = log.WithOrderID(ctx, order.ID)
+	err := s.OrderRepository.WithTransaction(ctx, order.ID, func(tx *gorm.DB) error {
+		err := s.OrderRepository.UpdateOrderTx(ctx, tx, order)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			log.FromContext(ctx).Warn().Int("version", order.Version).Msg("Order was modified concurrently")
+			return fmt.Errorf("%w: ID %d at version %d", ErrConcurrentUpdate, order.ID, order.Version)
+		}
+		if err != nil {
+			return err
+		}
+
+		if previousStatus != order.Status {
+			err = s.recordStatusChangeTx(ctx, tx, order.ID, previousStatus, order.Status)
+			if err != nil {
+				log.FromContext(ctx).Error().Err(err).Msg("Failed to record order status history")
+				return fmt.Errorf("failed to record order status history: %w", err)
+			}
+		}
+
+		err = s.createOrderEventTx(ctx, tx, order, eventType)
+		if err != nil {
+			log.FromContext(ctx).Error().Err(err).Str("event", eventType).Msg("Failed to store order event")
+			return fmt.Errorf("failed to store %s event: %w", eventType, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
 }
 
 func (s *orderService) mapOrderRequestWithOrderID(order *entity.Order) []entity.OrderRequest {
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/config"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/repository"
+	"order-service/internal/repository/memory"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	logger := zerolog.Nop()
+	log.Logger = &logger
+	os.Exit(m.Run())
+}
+
+// fakeCache is a map-backed CacheRepository. Values are stored as Redis would return them.
+type fakeCache struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{values: make(map[string]string)}
+}
+
+func (c *fakeCache) Set(ctx context.Context, key string, value interface{}) error {
+	return c.SetWithTTL(ctx, key, value, 0)
+}
+
+func (c *fakeCache) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = cacheString(value)
+	return nil
+}
+
+func (c *fakeCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[key]; ok {
+		return false, nil
+	}
+	c.values[key] = cacheString(value)
+	return true, nil
+}
+
+func (c *fakeCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key], nil
+}
+
+func (c *fakeCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (c *fakeCache) DeleteIfEqual(ctx context.Context, key string, value string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values[key] != value {
+		return false, nil
+	}
+	delete(c.values, key)
+	return true, nil
+}
+
+func (c *fakeCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	return nil
+}
+
+func cacheString(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// fakeOutbox records the events stored through CreateOutboxEventTx.
+type fakeOutbox struct {
+	mu     sync.Mutex
+	events []entity.OutboxEvent
+}
+
+func (o *fakeOutbox) CreateOutboxEventTx(ctx context.Context, tx *gorm.DB, event *entity.OutboxEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, *event)
+	return nil
+}
+
+func (o *fakeOutbox) ClaimPendingOutboxEvents(ctx context.Context, limit, partition, partitions int, fn func(tx *gorm.DB, events []entity.OutboxEvent) error) (int, error) {
+	return 0, nil
+}
+
+func (o *fakeOutbox) MarkOutboxEventPublishedTx(ctx context.Context, tx *gorm.DB, id int64) error {
+	return nil
+}
+
+func (o *fakeOutbox) MarkOutboxEventAttemptFailedTx(ctx context.Context, tx *gorm.DB, event *entity.OutboxEvent, publishErr error, maxAttempts int) error {
+	return nil
+}
+
+func (o *fakeOutbox) RequeueFailedOutboxEvents(ctx context.Context, maxTotalAttempts int) (int64, error) {
+	return 0, nil
+}
+
+func (o *fakeOutbox) CountFailedOutboxEvents(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// fakeProductClient holds the stock of each product and reserves it in memory.
+type fakeProductClient struct {
+	mu           sync.Mutex
+	stock        map[int64]int64
+	reservations int
+}
+
+func (p *fakeProductClient) GetStock(ctx context.Context, productID int64) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stock[productID], nil
+}
+
+func (p *fakeProductClient) CheckStockBatch(ctx context.Context, requests []StockRequest) (map[int64]int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stock := make(map[int64]int64, len(requests))
+	for _, request := range requests {
+		stock[request.ProductID] = p.stock[request.ProductID]
+	}
+	return stock, nil
+}
+
+func (p *fakeProductClient) CheckStock(ctx context.Context, productID int64, quantity int64) (bool, error) {
+	stock, err := p.GetStock(ctx, productID)
+	return stock >= quantity, err
+}
+
+func (p *fakeProductClient) ReserveStock(ctx context.Context, productID int64, quantity int64) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stock[productID] < quantity {
+		return "", fmt.Errorf("%w for product ID %d", ErrInsufficientStock, productID)
+	}
+	p.stock[productID] -= quantity
+	p.reservations++
+	return fmt.Sprintf("reservation-%d", p.reservations), nil
+}
+
+func (p *fakeProductClient) ReleaseStock(ctx context.Context, productID int64, reservationToken string) error {
+	return nil
+}
+
+// fakePricingClient serves fixed pricing per product.
+type fakePricingClient struct {
+	pricing map[int64]entity.Pricing
+}
+
+func (p *fakePricingClient) GetPricing(ctx context.Context, productID int64) (*entity.Pricing, error) {
+	pricing, ok := p.pricing[productID]
+	if !ok {
+		return nil, fmt.Errorf("%w: no pricing for product ID %d", ErrPricingServiceDown, productID)
+	}
+	return &pricing, nil
+}
+
+func (p *fakePricingClient) GetPricingBatch(ctx context.Context, productIDs []int64) (map[int64]entity.Pricing, error) {
+	pricings := make(map[int64]entity.Pricing, len(productIDs))
+	for _, productID := range productIDs {
+		pricing, err := p.GetPricing(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		pricings[productID] = *pricing
+	}
+	return pricings, nil
+}
+
+// newTestOrderService returns an order service backed by the in-memory order repository and the
+// fakes above, with every product in pricing stocked with 100 units.
+func newTestOrderService(pricing map[int64]entity.Pricing) (OrderService, repository.OrderRepository) {
+	stock := make(map[int64]int64, len(pricing))
+	for productID := range pricing {
+		stock[productID] = 100
+	}
+
+	orderRepository := memory.NewOrderRepository()
+	orderService := NewOrderService(
+		orderRepository,
+		newFakeCache(),
+		&fakeOutbox{},
+		&fakeProductClient{stock: stock},
+		&fakePricingClient{pricing: pricing},
+		config.Services{},
+		0,
+		"",
+	)
+	return orderService, orderRepository
+}
+
+func TestCreateOrderPersistsLinePricing(t *testing.T) {
+	orderService, orderRepository := newTestOrderService(map[int64]entity.Pricing{
+		1: {ProductID: 1, MarkUp: 10, Discount: 5, FinalPrice: 104.5, Currency: "USD"},
+		2: {ProductID: 2, MarkUp: 20, Discount: 0, FinalPrice: 60, Currency: "USD"},
+	})
+	ctx := context.Background()
+
+	created, err := orderService.CreateOrder(ctx, &entity.Order{
+		UserID: 7,
+		ProductRequests: []entity.OrderRequest{
+			{ProductID: 1, Quantity: 1},
+			{ProductID: 2, Quantity: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	saved, err := orderRepository.GetOrderRequests(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetOrderRequests: %v", err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("saved %d lines, want 2", len(saved))
+	}
+
+	want := map[int64]entity.OrderRequest{
+		1: {MarkUp: 10, Discount: 5, FinalPrice: 104.5},
+		2: {MarkUp: 20, Discount: 0, FinalPrice: 60},
+	}
+	for _, line := range saved {
+		expected := want[line.ProductID]
+		if line.MarkUp != expected.MarkUp || line.Discount != expected.Discount || line.FinalPrice != expected.FinalPrice {
+			t.Errorf("product %d saved with markup %v, discount %v, final price %v; want %v, %v, %v",
+				line.ProductID, line.MarkUp, line.Discount, line.FinalPrice, expected.MarkUp, expected.Discount, expected.FinalPrice)
+		}
+		if line.Currency != "USD" {
+			t.Errorf("product %d saved with currency %q, want USD", line.ProductID, line.Currency)
+		}
+	}
+}
+
+func TestUpdateOrderRejectsSecondWriterAtSameVersion(t *testing.T) {
+	orderService, _ := newTestOrderService(map[int64]entity.Pricing{
+		1: {ProductID: 1, FinalPrice: 10, Currency: "USD"},
+	})
+	ctx := context.Background()
+
+	created, err := orderService.CreateOrder(ctx, &entity.Order{
+		UserID:          7,
+		ProductRequests: []entity.OrderRequest{{ProductID: 1, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	// Both writers read the order at the same version; the second saves after the first.
+	readVersion := created.Version
+	_, err = orderService.UpdateOrder(ctx, &entity.Order{ID: created.ID, Status: entity.OrderStatusReleased, Version: readVersion})
+	if err != nil {
+		t.Fatalf("first UpdateOrder: %v", err)
+	}
+	_, err = orderService.UpdateOrder(ctx, &entity.Order{ID: created.ID, Status: entity.OrderStatusCancelled, Version: readVersion})
+	if !errors.Is(err, ErrConcurrentUpdate) {
+		t.Fatalf("second UpdateOrder error = %v, want ErrConcurrentUpdate", err)
+	}
+
+	stored, err := orderService.GetOrder(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if stored.Status != entity.OrderStatusReleased || stored.Version != readVersion+1 {
+		t.Errorf("stored status %q at version %d, want %q at version %d", stored.Status, stored.Version, entity.OrderStatusReleased, readVersion+1)
+	}
+}
+
+func TestCreateOrderPricesLinesByQuantity(t *testing.T) {
+	orderService, orderRepository := newTestOrderService(map[int64]entity.Pricing{
+		1: {ProductID: 1, FinalPrice: 12.5, Currency: "USD"},
+		2: {ProductID: 2, FinalPrice: 40, Currency: "USD"},
+	})
+	ctx := context.Background()
+
+	created, err := orderService.CreateOrder(ctx, &entity.Order{
+		UserID: 7,
+		ProductRequests: []entity.OrderRequest{
+			{ProductID: 1, Quantity: 4},
+			{ProductID: 2, Quantity: 3},
+		},
+		// Totals sent by the client are always recomputed.
+		TotalPrice: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	wantLineTotals := map[int64]float64{1: 50, 2: 120}
+	if created.TotalPrice != 170 {
+		t.Errorf("TotalPrice = %v, want 170", created.TotalPrice)
+	}
+
+	stored, err := orderRepository.GetOrderByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	if stored.TotalPrice != 170 {
+		t.Errorf("stored TotalPrice = %v, want 170", stored.TotalPrice)
+	}
+	for _, line := range stored.ProductRequests {
+		if line.LineTotal != wantLineTotals[line.ProductID] {
+			t.Errorf("product %d LineTotal = %v, want %v", line.ProductID, line.LineTotal, wantLineTotals[line.ProductID])
+		}
+	}
+}
+
+func TestPayOrderWithLastUnitsReserved(t *testing.T) {
+	orderService, _ := newTestOrderService(map[int64]entity.Pricing{
+		1: {ProductID: 1, FinalPrice: 10, Currency: "USD"},
+	})
+	ctx := context.Background()
+
+	// The order reserves every unit in stock, leaving none available.
+	created, err := orderService.CreateOrder(ctx, &entity.Order{
+		UserID:          7,
+		ProductRequests: []entity.OrderRequest{{ProductID: 1, Quantity: 100}},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	paid, err := orderService.PayOrder(ctx, created.ID, "payment-1")
+	if err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+	if paid.Status != entity.OrderStatusPaid {
+		t.Errorf("status = %q, want %q", paid.Status, entity.OrderStatusPaid)
+	}
+}
+
+func TestCreateOrdersEnforcesPurchaseLimitsAcrossTheBatch(t *testing.T) {
+	orderService := NewOrderService(
+		memory.NewOrderRepository(),
+		newFakeCache(),
+		&fakeOutbox{},
+		&fakeProductClient{stock: map[int64]int64{1: 100}},
+		&fakePricingClient{pricing: map[int64]entity.Pricing{1: {ProductID: 1, FinalPrice: 10, Currency: "USD"}}},
+		config.Services{PurchaseLimits: config.PurchaseLimits{Default: 3}},
+		0,
+		"",
+	)
+	ctx := context.Background()
+
+	// Each order fits the limit of 3 on its own, but not together.
+	results := orderService.CreateOrders(ctx, []*entity.Order{
+		{UserID: 7, ProductRequests: []entity.OrderRequest{{ProductID: 1, Quantity: 2}}},
+		{UserID: 7, ProductRequests: []entity.OrderRequest{{ProductID: 1, Quantity: 2}}},
+		{UserID: 8, ProductRequests: []entity.OrderRequest{{ProductID: 1, Quantity: 3}}},
+	})
+
+	if results[0].Err != nil {
+		t.Errorf("first order of user 7: %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrPurchaseLimitExceeded) {
+		t.Errorf("second order of user 7 error = %v, want ErrPurchaseLimitExceeded", results[1].Err)
+	}
+	if results[2].Err != nil {
+		t.Errorf("order of user 8: %v", results[2].Err)
+	}
+}
+
+func TestCreateOrdersFailsOnlyTheOrderThatCannotBeWritten(t *testing.T) {
+	orderService, _ := newTestOrderService(map[int64]entity.Pricing{
+		1: {ProductID: 1, FinalPrice: 10, Currency: "USD"},
+	})
+	ctx := context.Background()
+
+	// Both orders land on the single shard; the second reuses the first's idempotency key.
+	results := orderService.CreateOrders(ctx, []*entity.Order{
+		{UserID: 7, IdempotencyKey: "key-1", ProductRequests: []entity.OrderRequest{{ProductID: 1, Quantity: 1}}},
+		{UserID: 7, IdempotencyKey: "key-1", ProductRequests: []entity.OrderRequest{{ProductID: 1, Quantity: 1}}},
+	})
+
+	if results[0].Err != nil || results[0].Order == nil {
+		t.Errorf("first order: %v, want it created", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, gorm.ErrDuplicatedKey) {
+		t.Errorf("second order error = %v, want gorm.ErrDuplicatedKey", results[1].Err)
+	}
+}
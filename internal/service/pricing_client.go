@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"order-service/config"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+)
+
+// PricingClient fetches product pricing from the pricing service.
+type PricingClient interface {
+	GetPricing(ctx context.Context, productID int64) (*entity.Pricing, error)
+	GetPricingBatch(ctx context.Context, productIDs []int64) (map[int64]entity.Pricing, error)
+}
+
+type httpPricingClient struct {
+	downstream
+}
+
+// NewHTTPPricingClient creates a PricingClient calling the pricing service over HTTP at services.Pricing.
+// Calls are made with httpClient, or with a client built by NewHTTPClient when nil.
+func NewHTTPPricingClient(httpClient *http.Client, services config.Services) PricingClient {
+	return &httpPricingClient{
+		downstream: newDownstream("pricing", services.Pricing, httpClient, services),
+	}
+}
+
+// GetPricing requests the pricing of a product from the pricing service.
+//
+// Parameters:
+//   - productID: The ID of the product to price.
+//
+// Returns:
+//   - The pricing of the product.
+//   - ErrPricingServiceDown if the pricing service is unavailable, ErrDownstreamProtocol if it returns an invalid response, or another error if the request fails.
+func (c *httpPricingClient) GetPricing(ctx context.Context, productID int64) (*entity.Pricing, error) {
+	response, err := c.doWithBreaker(ctx, http.MethodGet, fmt.Sprintf("%s/product/%d/price", c.BaseURL, productID), nil)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to get product pricing")
+		return nil, fmt.Errorf("%w: %w", ErrPricingServiceDown, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		log.FromContext(ctx).Error().Int64("productID", productID).Int("statusCode", response.StatusCode).Msg("Failed to get product pricing")
+		if response.StatusCode >= http.StatusInternalServerError {
+			return nil, fmt.Errorf("%w: status code %d", ErrPricingServiceDown, response.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to get product pricing, status code: %d", response.StatusCode)
+	}
+
+	var pricing entity.Pricing
+	err = decodeJSON(ctx, response, &pricing)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to decode pricing response")
+		return nil, fmt.Errorf("failed to decode pricing response: %w", err)
+	}
+
+	return &pricing, nil
+}
+
+// GetPricingBatch requests the pricing of several products from the pricing service with a single request.
+//
+// Parameters:
+//   - productIDs: The IDs of the products to price.
+//
+// Returns:
+//   - The pricing keyed by product ID, with an entry for every requested product.
+//   - ErrPricingServiceDown if the pricing service is unavailable, ErrDownstreamProtocol if it returns an invalid response,
+//     or another error if the request fails, including when the pricing service has no batch endpoint.
+func (c *httpPricingClient) GetPricingBatch(ctx context.Context, productIDs []int64) (map[int64]entity.Pricing, error) {
+	body, err := json.Marshal(map[string][]int64{"product_ids": productIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pricing batch request: %w", err)
+	}
+
+	response, err := c.doWithBreaker(ctx, http.MethodPost, fmt.Sprintf("%s/product/price/batch", c.BaseURL), body)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int("products", len(productIDs)).Msg("Failed to get product pricing batch")
+		return nil, fmt.Errorf("%w: %w", ErrPricingServiceDown, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		log.FromContext(ctx).Error().Int("products", len(productIDs)).Int("statusCode", response.StatusCode).Msg("Failed to get product pricing batch")
+		if response.StatusCode >= http.StatusInternalServerError {
+			return nil, fmt.Errorf("%w: status code %d", ErrPricingServiceDown, response.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to get product pricing batch, status code: %d", response.StatusCode)
+	}
+
+	var pricingResponse struct {
+		Pricings []entity.Pricing `json:"pricings"`
+	}
+	err = decodeJSON(ctx, response, &pricingResponse)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int("products", len(productIDs)).Msg("Failed to decode pricing batch response")
+		return nil, fmt.Errorf("failed to decode pricing batch response: %w", err)
+	}
+
+	return pricingByProduct(ctx, productIDs, pricingResponse.Pricings)
+}
+
+// pricingByProduct keys pricings by product ID, failing with ErrDownstreamProtocol when the pricing of
+// one of productIDs is missing.
+func pricingByProduct(ctx context.Context, productIDs []int64, pricings []entity.Pricing) (map[int64]entity.Pricing, error) {
+	results := make(map[int64]entity.Pricing, len(pricings))
+	for _, pricing := range pricings {
+		results[pricing.ProductID] = pricing
+	}
+	for _, productID := range productIDs {
+		if _, exists := results[productID]; !exists {
+			log.FromContext(ctx).Warn().Int64("productID", productID).Msg("Pricing not found for product")
+			return nil, fmt.Errorf("%w: pricing not found for product ID %d", ErrDownstreamProtocol, productID)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"order-service/config"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/pb/pricingpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+type grpcPricingClient struct {
+	grpcDownstream
+	client pricingpb.PricingServiceClient
+}
+
+// NewGRPCPricingClient creates a PricingClient calling the pricing service over gRPC through conn.
+func NewGRPCPricingClient(conn *grpc.ClientConn, services config.Services) PricingClient {
+	return &grpcPricingClient{
+		grpcDownstream: newGRPCDownstream("pricing", conn, services),
+		client:         pricingpb.NewPricingServiceClient(conn),
+	}
+}
+
+// GetPricing requests the pricing of a product from the pricing service.
+//
+// Parameters:
+//   - productID: The ID of the product to price.
+//
+// Returns:
+//   - The pricing of the product.
+//   - ErrPricingServiceDown if the pricing service is unavailable, or another error if the request fails.
+func (c *grpcPricingClient) GetPricing(ctx context.Context, productID int64) (*entity.Pricing, error) {
+	var response *pricingpb.GetPricingResponse
+	err := c.invoke(ctx, "GetPricing", func(ctx context.Context) error {
+		var err error
+		response, err = c.client.GetPricing(ctx, &pricingpb.GetPricingRequest{ProductId: productID})
+		return err
+	})
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to get product pricing")
+		return nil, pricingServiceError("failed to get product pricing", err)
+	}
+
+	pricing := pricingFromResponse(response)
+	return &pricing, nil
+}
+
+// GetPricingBatch requests the pricing of several products from the pricing service with a single call.
+//
+// Parameters:
+//   - productIDs: The IDs of the products to price.
+//
+// Returns:
+//   - The pricing keyed by product ID, with an entry for every requested product.
+//   - ErrPricingServiceDown if the pricing service is unavailable, ErrDownstreamProtocol if it returns an invalid response,
+//     or another error if the request fails, including when the pricing service does not implement GetPricingBatch.
+func (c *grpcPricingClient) GetPricingBatch(ctx context.Context, productIDs []int64) (map[int64]entity.Pricing, error) {
+	var response *pricingpb.GetPricingBatchResponse
+	err := c.invoke(ctx, "GetPricingBatch", func(ctx context.Context) error {
+		var err error
+		response, err = c.client.GetPricingBatch(ctx, &pricingpb.GetPricingBatchRequest{ProductIds: productIDs})
+		return err
+	})
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int("products", len(productIDs)).Msg("Failed to get product pricing batch")
+		return nil, pricingServiceError("failed to get product pricing batch", err)
+	}
+
+	pricings := make([]entity.Pricing, 0, len(response.GetPricings()))
+	for _, pricing := range response.GetPricings() {
+		pricings = append(pricings, pricingFromResponse(pricing))
+	}
+	return pricingByProduct(ctx, productIDs, pricings)
+}
+
+// pricingFromResponse converts the pricing of a product returned by the pricing service.
+func pricingFromResponse(response *pricingpb.GetPricingResponse) entity.Pricing {
+	return entity.Pricing{
+		ProductID:  response.GetProductId(),
+		MarkUp:     response.GetMarkup(),
+		Discount:   response.GetDiscount(),
+		FinalPrice: response.GetFinalPrice(),
+		Currency:   response.GetCurrency(),
+	}
+}
+
+// pricingServiceError wraps a failed pricing service call in ErrPricingServiceDown when the
+// service is unavailable, matching the errors of the HTTP client.
+func pricingServiceError(message string, err error) error {
+	if _, ok := status.FromError(err); !ok || isTransientStatus(err) {
+		return fmt.Errorf("%w: %w", ErrPricingServiceDown, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"order-service/config"
+	"order-service/infrastructure/log"
+)
+
+// ProductClient checks and reserves product stock on the product service.
+type ProductClient interface {
+	GetStock(ctx context.Context, productID int64) (int64, error)
+	CheckStockBatch(ctx context.Context, requests []StockRequest) (map[int64]int64, error)
+	CheckStock(ctx context.Context, productID int64, quantity int64) (bool, error)
+	ReserveStock(ctx context.Context, productID int64, quantity int64) (string, error)
+	ReleaseStock(ctx context.Context, productID int64, reservationToken string) error
+}
+
+// StockRequest asks for the stock of a product an order needs quantity units of.
+type StockRequest struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int64 `json:"quantity"`
+}
+
+type httpProductClient struct {
+	downstream
+}
+
+// NewHTTPProductClient creates a ProductClient calling the product service over HTTP at services.Product.
+// Calls are made with httpClient, or with a client built by NewHTTPClient when nil.
+func NewHTTPProductClient(httpClient *http.Client, services config.Services) ProductClient {
+	return &httpProductClient{
+		downstream: newDownstream("product", services.Product, httpClient, services),
+	}
+}
+
+// GetStock returns the units of a product currently in stock.
+//
+// Parameters:
+//   - productID: The ID of the product to check.
+//
+// Returns:
+//   - The number of units in stock.
+//   - ErrProductServiceDown if the product service is unavailable, ErrDownstreamProtocol if it returns an invalid response, or another error if the check fails.
+func (c *httpProductClient) GetStock(ctx context.Context, productID int64) (int64, error) {
+	response, err := c.doWithBreaker(ctx, http.MethodGet, fmt.Sprintf("%s/product/%d/stock", c.BaseURL, productID), nil)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to check product stock")
+		return 0, fmt.Errorf("%w: %w", ErrProductServiceDown, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		log.FromContext(ctx).Error().Int64("productID", productID).Int("statusCode", response.StatusCode).Msg("Failed to check product stock")
+		if response.StatusCode >= http.StatusInternalServerError {
+			return 0, fmt.Errorf("%w: status code %d", ErrProductServiceDown, response.StatusCode)
+		}
+		return 0, fmt.Errorf("failed to check product stock, status code: %d", response.StatusCode)
+	}
+
+	var stockResponse map[string]int64
+	err = decodeJSON(ctx, response, &stockResponse)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to decode stock response")
+		return 0, fmt.Errorf("failed to decode stock response: %w", err)
+	}
+
+	productStock, exists := stockResponse["stock"]
+	if !exists {
+		log.FromContext(ctx).Warn().Int64("productID", productID).Msg("Stock information not found for product")
+		return 0, fmt.Errorf("%w: stock information not found for product ID %d", ErrDownstreamProtocol, productID)
+	}
+
+	return productStock, nil
+}
+
+// CheckStockBatch returns the units in stock of several products with a single request.
+//
+// Parameters:
+//   - requests: The products to check and the units needed of each.
+//
+// Returns:
+//   - The units in stock keyed by product ID, with an entry for every requested product.
+//   - ErrProductServiceDown if the product service is unavailable, ErrDownstreamProtocol if it returns an invalid response,
+//     or another error if the check fails, including when the product service has no batch endpoint.
+func (c *httpProductClient) CheckStockBatch(ctx context.Context, requests []StockRequest) (map[int64]int64, error) {
+	body, err := json.Marshal(map[string][]StockRequest{"items": requests})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode stock batch request: %w", err)
+	}
+
+	response, err := c.doWithBreaker(ctx, http.MethodPost, fmt.Sprintf("%s/product/stock/batch", c.BaseURL), body)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int("products", len(requests)).Msg("Failed to check product stock batch")
+		return nil, fmt.Errorf("%w: %w", ErrProductServiceDown, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		log.FromContext(ctx).Error().Int("products", len(requests)).Int("statusCode", response.StatusCode).Msg("Failed to check product stock batch")
+		if response.StatusCode >= http.StatusInternalServerError {
+			return nil, fmt.Errorf("%w: status code %d", ErrProductServiceDown, response.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to check product stock batch, status code: %d", response.StatusCode)
+	}
+
+	var stockResponse struct {
+		Stock map[int64]int64 `json:"stock"`
+	}
+	err = decodeJSON(ctx, response, &stockResponse)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int("products", len(requests)).Msg("Failed to decode stock batch response")
+		return nil, fmt.Errorf("failed to decode stock batch response: %w", err)
+	}
+
+	for _, request := range requests {
+		if _, exists := stockResponse.Stock[request.ProductID]; !exists {
+			log.FromContext(ctx).Warn().Int64("productID", request.ProductID).Msg("Stock information not found for product")
+			return nil, fmt.Errorf("%w: stock information not found for product ID %d", ErrDownstreamProtocol, request.ProductID)
+		}
+	}
+
+	return stockResponse.Stock, nil
+}
+
+// CheckStock reports whether a product has at least quantity units in stock.
+//
+// Parameters:
+//   - productID: The ID of the product to check.
+//   - quantity: The number of units required.
+//
+// Returns:
+//   - True if the product has enough stock.
+//   - ErrProductServiceDown if the product service is unavailable, or another error if the check fails.
+func (c *httpProductClient) CheckStock(ctx context.Context, productID int64, quantity int64) (bool, error) {
+	productStock, err := c.GetStock(ctx, productID)
+	if err != nil {
+		return false, err
+	}
+	return productStock >= quantity, nil
+}
+
+// ReserveStock atomically reserves quantity units of a product. Each call takes a new reservation,
+// so the request is sent once: a retry after a timeout could reserve the stock twice and lose
+// the token of the first reservation, which would then never be released.
+//
+// Parameters:
+//   - productID: The ID of the product to reserve.
+//   - quantity: The number of units to reserve.
+//
+// Returns:
+//   - The reservation token identifying the reservation, used to release it.
+//   - ErrInsufficientStock if the product does not have enough stock, or another error if the reservation fails.
+func (c *httpProductClient) ReserveStock(ctx context.Context, productID int64, quantity int64) (string, error) {
+	body, err := json.Marshal(map[string]int64{"quantity": quantity})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode reserve stock request: %w", err)
+	}
+
+	response, err := c.doOnceWithBreaker(ctx, http.MethodPost, fmt.Sprintf("%s/product/%d/reserve", c.BaseURL, productID), body)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to reserve product stock")
+		return "", fmt.Errorf("%w: %w", ErrProductServiceDown, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusConflict {
+		log.FromContext(ctx).Warn().Int64("productID", productID).Int64("quantity", quantity).Msg("Insufficient stock to reserve for product")
+		return "", fmt.Errorf("%w for product ID %d", ErrInsufficientStock, productID)
+	}
+	if response.StatusCode != http.StatusOK {
+		log.FromContext(ctx).Error().Int64("productID", productID).Int("statusCode", response.StatusCode).Msg("Failed to reserve product stock")
+		if response.StatusCode >= http.StatusInternalServerError {
+			return "", fmt.Errorf("%w: status code %d", ErrProductServiceDown, response.StatusCode)
+		}
+		return "", fmt.Errorf("failed to reserve product stock, status code: %d", response.StatusCode)
+	}
+
+	var reservation struct {
+		ReservationToken string `json:"reservation_token"`
+	}
+	err = decodeJSON(ctx, response, &reservation)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to decode reserve stock response")
+		return "", fmt.Errorf("failed to decode reserve stock response: %w", err)
+	}
+	if reservation.ReservationToken == "" {
+		return "", fmt.Errorf("%w: reservation token not found for product ID %d", ErrDownstreamProtocol, productID)
+	}
+
+	return reservation.ReservationToken, nil
+}
+
+// ReleaseStock releases a reservation previously taken with ReserveStock.
+//
+// Parameters:
+//   - productID: The ID of the reserved product.
+//   - reservationToken: The token returned when the stock was reserved.
+//
+// Returns:
+//   - An error if the release fails.
+func (c *httpProductClient) ReleaseStock(ctx context.Context, productID int64, reservationToken string) error {
+	body, err := json.Marshal(map[string]string{"reservation_token": reservationToken})
+	if err != nil {
+		return fmt.Errorf("failed to encode release stock request: %w", err)
+	}
+
+	response, err := c.doWithBreaker(ctx, http.MethodPost, fmt.Sprintf("%s/product/%d/release", c.BaseURL, productID), body)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrProductServiceDown, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to release product stock, status code: %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"order-service/config"
+	"order-service/infrastructure/log"
+	"order-service/internal/pb/productpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type grpcProductClient struct {
+	grpcDownstream
+	client productpb.ProductServiceClient
+}
+
+// NewGRPCProductClient creates a ProductClient calling the product service over gRPC through conn.
+func NewGRPCProductClient(conn *grpc.ClientConn, services config.Services) ProductClient {
+	return &grpcProductClient{
+		grpcDownstream: newGRPCDownstream("product", conn, services),
+		client:         productpb.NewProductServiceClient(conn),
+	}
+}
+
+// GetStock returns the units of a product currently in stock.
+//
+// Parameters:
+//   - productID: The ID of the product to check.
+//
+// Returns:
+//   - The number of units in stock.
+//   - ErrProductServiceDown if the product service is unavailable, or another error if the check fails.
+func (c *grpcProductClient) GetStock(ctx context.Context, productID int64) (int64, error) {
+	var response *productpb.GetStockResponse
+	err := c.invoke(ctx, "GetStock", func(ctx context.Context) error {
+		var err error
+		response, err = c.client.GetStock(ctx, &productpb.GetStockRequest{ProductId: productID})
+		return err
+	})
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to check product stock")
+		return 0, productServiceError("failed to check product stock", err)
+	}
+
+	return response.GetStock(), nil
+}
+
+// CheckStockBatch returns the units in stock of several products with a single call.
+//
+// Parameters:
+//   - requests: The products to check and the units needed of each.
+//
+// Returns:
+//   - The units in stock keyed by product ID, with an entry for every requested product.
+//   - ErrProductServiceDown if the product service is unavailable, ErrDownstreamProtocol if it returns an invalid response,
+//     or another error if the check fails, including when the product service does not implement GetStockBatch.
+func (c *grpcProductClient) CheckStockBatch(ctx context.Context, requests []StockRequest) (map[int64]int64, error) {
+	items := make([]*productpb.StockItem, 0, len(requests))
+	for _, request := range requests {
+		items = append(items, &productpb.StockItem{ProductId: request.ProductID, Quantity: request.Quantity})
+	}
+
+	var response *productpb.GetStockBatchResponse
+	err := c.invoke(ctx, "GetStockBatch", func(ctx context.Context) error {
+		var err error
+		response, err = c.client.GetStockBatch(ctx, &productpb.GetStockBatchRequest{Items: items})
+		return err
+	})
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int("products", len(requests)).Msg("Failed to check product stock batch")
+		return nil, productServiceError("failed to check product stock batch", err)
+	}
+
+	stock := make(map[int64]int64, len(response.GetStocks()))
+	for _, productStock := range response.GetStocks() {
+		stock[productStock.GetProductId()] = productStock.GetStock()
+	}
+	for _, request := range requests {
+		if _, exists := stock[request.ProductID]; !exists {
+			log.FromContext(ctx).Warn().Int64("productID", request.ProductID).Msg("Stock information not found for product")
+			return nil, fmt.Errorf("%w: stock information not found for product ID %d", ErrDownstreamProtocol, request.ProductID)
+		}
+	}
+
+	return stock, nil
+}
+
+// CheckStock reports whether a product has at least quantity units in stock.
+//
+// Parameters:
+//   - productID: The ID of the product to check.
+//   - quantity: The number of units required.
+//
+// Returns:
+//   - True if the product has enough stock.
+//   - ErrProductServiceDown if the product service is unavailable, or another error if the check fails.
+func (c *grpcProductClient) CheckStock(ctx context.Context, productID int64, quantity int64) (bool, error) {
+	productStock, err := c.GetStock(ctx, productID)
+	if err != nil {
+		return false, err
+	}
+	return productStock >= quantity, nil
+}
+
+// ReserveStock atomically reserves quantity units of a product. Each call takes a new reservation,
+// so the RPC is not retried: a retry after a timeout could reserve the stock twice and lose the
+// token of the first reservation, which would then never be released.
+//
+// Parameters:
+//   - productID: The ID of the product to reserve.
+//   - quantity: The number of units to reserve.
+//
+// Returns:
+//   - The reservation token identifying the reservation, used to release it.
+//   - ErrInsufficientStock if the product does not have enough stock, or another error if the reservation fails.
+func (c *grpcProductClient) ReserveStock(ctx context.Context, productID int64, quantity int64) (string, error) {
+	var response *productpb.ReserveStockResponse
+	err := c.invokeOnce(ctx, "ReserveStock", func(ctx context.Context) error {
+		var err error
+		response, err = c.client.ReserveStock(ctx, &productpb.ReserveStockRequest{ProductId: productID, Quantity: quantity})
+		return err
+	})
+	if status.Code(err) == codes.FailedPrecondition {
+		log.FromContext(ctx).Warn().Int64("productID", productID).Int64("quantity", quantity).Msg("Insufficient stock to reserve for product")
+		return "", fmt.Errorf("%w for product ID %d", ErrInsufficientStock, productID)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Int64("productID", productID).Msg("Failed to reserve product stock")
+		return "", productServiceError("failed to reserve product stock", err)
+	}
+	if response.GetReservationToken() == "" {
+		return "", fmt.Errorf("%w: reservation token not found for product ID %d", ErrDownstreamProtocol, productID)
+	}
+
+	return response.GetReservationToken(), nil
+}
+
+// ReleaseStock releases a reservation previously taken with ReserveStock.
+//
+// Parameters:
+//   - productID: The ID of the reserved product.
+//   - reservationToken: The token returned when the stock was reserved.
+//
+// Returns:
+//   - An error if the release fails.
+func (c *grpcProductClient) ReleaseStock(ctx context.Context, productID int64, reservationToken string) error {
+	err := c.invoke(ctx, "ReleaseStock", func(ctx context.Context) error {
+		_, err := c.client.ReleaseStock(ctx, &productpb.ReleaseStockRequest{ProductId: productID, ReservationToken: reservationToken})
+		return err
+	})
+	if err != nil {
+		return productServiceError("failed to release product stock", err)
+	}
+
+	return nil
+}
+
+// productServiceError wraps a failed product service call in ErrProductServiceDown when the
+// service is unavailable, matching the errors of the HTTP client.
+func productServiceError(message string, err error) error {
+	if _, ok := status.FromError(err); !ok || isTransientStatus(err) {
+		return fmt.Errorf("%w: %w", ErrProductServiceDown, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
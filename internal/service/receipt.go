@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"math"
+	"order-service/internal/entity"
+)
+
+// GetOrderReceipt returns a printable summary of an order. The markup and discount of each line are
+// derived from the percentages and final price stored on it when the order was priced, the final price
+// being the product price marked up first and discounted second, so the receipt does not call the
+// pricing service and keeps showing the prices the customer was charged.
+//
+// Parameters:
+//   - orderId: The ID of the order.
+//
+// Returns:
+//   - The order's receipt.
+//   - ErrOrderNotFound if the order does not exist, or another error if the retrieval process fails.
+func (s *orderService) GetOrderReceipt(ctx context.Context, orderId int64) (*entity.OrderReceipt, error) {
+	order, err := s.GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderReceipt(order), nil
+}
+
+// orderReceipt computes the receipt of an order from its lines.
+func orderReceipt(order *entity.Order) *entity.OrderReceipt {
+	receipt := &entity.OrderReceipt{
+		OrderID:          order.ID,
+		Status:           order.Status,
+		Currency:         order.Currency,
+		Lines:            make([]entity.ReceiptLine, 0, len(order.ProductRequests)),
+		PricingEstimated: order.PricingEstimated,
+		PaymentReference: order.PaymentReference,
+		CreatedAt:        order.CreatedAt,
+		UpdatedAt:        order.UpdatedAt,
+	}
+
+	for _, productRequest := range order.ProductRequests {
+		quantity := float64(productRequest.Quantity)
+		markupFactor := 1 + productRequest.MarkUp/100
+		discountFactor := 1 - productRequest.Discount/100
+
+		// A line discounted by 100% carries no trace of its product price, so it is reported as free.
+		basePrice := 0.0
+		if markupFactor > 0 && discountFactor > 0 {
+			basePrice = productRequest.FinalPrice / (markupFactor * discountFactor)
+		}
+		markedUpPrice := basePrice * markupFactor
+
+		line := entity.ReceiptLine{
+			ProductID:      productRequest.ProductID,
+			Quantity:       productRequest.Quantity,
+			BasePrice:      roundCents(basePrice),
+			UnitFinalPrice: roundCents(productRequest.FinalPrice),
+			Markup:         roundCents((markedUpPrice - basePrice) * quantity),
+			Discount:       roundCents((markedUpPrice - productRequest.FinalPrice) * quantity),
+			LineTotal:      roundCents(productRequest.LineTotal),
+		}
+		receipt.Lines = append(receipt.Lines, line)
+
+		receipt.Subtotal += roundCents(basePrice * quantity)
+		receipt.TotalMarkup += line.Markup
+		receipt.TotalDiscount += line.Discount
+		receipt.GrandTotal += line.LineTotal
+	}
+
+	// Summing cents in floating point can leave a trailing fraction, so the totals are rounded again.
+	receipt.Subtotal = roundCents(receipt.Subtotal)
+	receipt.TotalMarkup = roundCents(receipt.TotalMarkup)
+	receipt.TotalDiscount = roundCents(receipt.TotalDiscount)
+	receipt.GrandTotal = roundCents(receipt.GrandTotal)
+	return receipt
+}
+
+// roundCents rounds an amount to two decimal places.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"order-service/config"
+	"order-service/infrastructure/log"
+	"order-service/internal/breaker"
+	"order-service/internal/metrics"
+	"time"
+)
+
+const defaultRetryBaseDelay = 100 * time.Millisecond
+
+const (
+	// maxResponseBodySize bounds how much of a downstream response body is read.
+	maxResponseBodySize = 1 << 20
+	// loggedBodySize bounds how much of an invalid response body is logged.
+	loggedBodySize = 512
+)
+
+// downstream sends requests to one downstream service, retrying transient failures
+// behind the service's circuit breaker. The HTTP clients of each service embed it.
+type downstream struct {
+	BaseURL        string                  // Base URL of the downstream service
+	HTTPClient     *http.Client            // Client shared by all downstream calls
+	MaxRetries     int                     // Maximum retries for transient downstream failures
+	RetryBaseDelay time.Duration           // Initial backoff between downstream retries
+	Breaker        *breaker.CircuitBreaker // Breaker of the downstream service, named after it
+}
+
+// newDownstream creates the downstream of the named service at baseURL, building a client
+// with NewHTTPClient when httpClient is nil.
+func newDownstream(name, baseURL string, httpClient *http.Client, services config.Services) downstream {
+	if httpClient == nil {
+		httpClient = NewHTTPClient(services)
+	}
+	return downstream{
+		BaseURL:        baseURL,
+		HTTPClient:     httpClient,
+		MaxRetries:     services.MaxRetries,
+		RetryBaseDelay: services.BaseDelay,
+		Breaker:        newCircuitBreaker(name, services.CircuitBreaker),
+	}
+}
+
+// doWithRetry sends a request to url, retrying network errors and 5xx responses
+// up to maxRetries times with exponential backoff and jitter. Any other response,
+// including 4xx, is returned immediately for the caller to handle. The final 5xx
+// response is returned as-is once retries are exhausted.
+//
+// Parameters:
+//   - method: The HTTP method of the request.
+//   - url: The downstream URL to request.
+//   - body: The JSON request body, or nil for requests without a body.
+//   - maxRetries: How many times the request is retried, 0 to send it once.
+//
+// Returns:
+//   - The HTTP response, whose body must be closed by the caller.
+//   - An error if the request could not be completed or the context was cancelled.
+func (d *downstream) doWithRetry(ctx context.Context, method, url string, body []byte, maxRetries int) (*http.Response, error) {
+	baseDelay := d.RetryBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		var requestBody io.Reader
+		if body != nil {
+			requestBody = bytes.NewReader(body)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, method, url, requestBody)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		if requestID := log.RequestIDFromContext(ctx); requestID != "" {
+			request.Header.Set(log.RequestIDHeader, requestID)
+		}
+
+		response, err := d.HTTPClient.Do(request)
+		lastAttempt := attempt >= maxRetries
+		if err == nil && (response.StatusCode < http.StatusInternalServerError || lastAttempt) {
+			return response, nil
+		}
+		if err != nil && (lastAttempt || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+			return nil, err
+		}
+
+		if response != nil {
+			log.FromContext(ctx).Warn().Str("url", url).Int("statusCode", response.StatusCode).Int("attempt", attempt+1).Msg("Retrying downstream request")
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		} else {
+			log.FromContext(ctx).Warn().Err(err).Str("url", url).Int("attempt", attempt+1).Msg("Retrying downstream request")
+		}
+
+		timer := time.NewTimer(backoffDelay(baseDelay, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// doWithBreaker sends a request to url through doWithRetry, guarded by the service's circuit breaker.
+// Network errors and 5xx responses count as failures; caller cancellations count as neither a success
+// nor a failure.
+//
+// Parameters:
+//   - method: The HTTP method of the request.
+//   - url: The downstream URL to request.
+//   - body: The JSON request body, or nil for requests without a body.
+//
+// Returns:
+//   - The HTTP response, whose body must be closed by the caller.
+//   - breaker.ErrServiceUnavailable if the breaker is open, or the request error otherwise.
+func (d *downstream) doWithBreaker(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	return d.sendWithBreaker(ctx, method, url, body, d.MaxRetries)
+}
+
+// doOnceWithBreaker sends a request to url exactly once, guarded by the service's circuit breaker.
+// It is used for requests that are not idempotent, where a retry after a timeout or 5xx response
+// could repeat a change the first attempt already made.
+func (d *downstream) doOnceWithBreaker(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	return d.sendWithBreaker(ctx, method, url, body, 0)
+}
+
+// sendWithBreaker sends a request to url through doWithRetry with up to maxRetries retries, guarded by
+// the service's circuit breaker.
+func (d *downstream) sendWithBreaker(ctx context.Context, method, url string, body []byte, maxRetries int) (*http.Response, error) {
+	cb := d.Breaker
+	start := time.Now()
+	defer func() {
+		metrics.DownstreamRequestDuration.WithLabelValues(cb.Name()).Observe(time.Since(start).Seconds())
+	}()
+
+	var response *http.Response
+	var requestErr error
+	err := cb.Execute(func() error {
+		response, requestErr = d.doWithRetry(ctx, method, url, body, maxRetries)
+		if requestErr != nil {
+			if ctx.Err() != nil {
+				// A request cancelled by the caller says nothing about the service's health.
+				return fmt.Errorf("%w: %w", breaker.ErrOutcomeIgnored, ctx.Err())
+			}
+			return requestErr
+		}
+		if response.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s service responded with status code %d", cb.Name(), response.StatusCode)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, breaker.ErrOutcomeIgnored) {
+		metrics.DownstreamRequestErrors.WithLabelValues(cb.Name()).Inc()
+	}
+	if errors.Is(err, breaker.ErrServiceUnavailable) {
+		log.FromContext(ctx).Warn().Str("service", cb.Name()).Str("url", url).Msg("Circuit breaker open, skipping downstream request")
+		return nil, fmt.Errorf("%s service: %w", cb.Name(), err)
+	}
+
+	return response, requestErr
+}
+
+// backoffDelay returns the exponential delay for the given attempt with equal jitter,
+// i.e. a random duration between half and the full exponential delay.
+func backoffDelay(baseDelay time.Duration, attempt int) time.Duration {
+	delay := baseDelay << attempt
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// decodeJSON decodes the JSON body of a downstream response into v. A body that cannot be decoded is
+// logged, truncated to loggedBodySize bytes, and reported as ErrDownstreamProtocol so a downstream
+// returning garbage can be told apart from one that is unavailable.
+func decodeJSON(ctx context.Context, response *http.Response, v any) error {
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		log.FromContext(ctx).Error().Err(err).Str("url", response.Request.URL.String()).Str("body", truncateBody(body)).Msg("Downstream service returned malformed JSON")
+		return fmt.Errorf("%w: %w", ErrDownstreamProtocol, err)
+	}
+	return nil
+}
+
+// truncateBody returns body as a string of at most loggedBodySize bytes.
+func truncateBody(body []byte) string {
+	if len(body) > loggedBodySize {
+		return string(body[:loggedBodySize]) + "...(truncated)"
+	}
+	return string(body)
+}
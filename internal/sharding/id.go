@@ -0,0 +1,64 @@
+package sharding
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	idEpochMillis  = 1704067200000 // 2024-01-01T00:00:00Z
+	nodeIDBits     = 10
+	sequenceBits   = 12
+	maxNodeID      = 1<<nodeIDBits - 1
+	sequenceMask   = 1<<sequenceBits - 1
+	nodeIDShift    = sequenceBits
+	timestampShift = sequenceBits + nodeIDBits
+)
+
+// IDGenerator generates unique, roughly time-ordered 63-bit IDs made of a millisecond
+// timestamp, a node ID and a sequence number. IDs are assigned before insert so the
+// shard of a row is known up front. The sequence keeps counting across milliseconds
+// rather than resetting, so IDs issued in the same millisecond stay distinct.
+type IDGenerator struct {
+	mu         sync.Mutex
+	nodeID     int64
+	lastMillis int64
+	issued     int64 // IDs issued in lastMillis
+	sequence   int64
+}
+
+// NewIDGenerator creates an ID generator for the given node. Every running instance
+// must use a distinct node ID between 0 and 1023.
+func NewIDGenerator(nodeID int64) *IDGenerator {
+	return &IDGenerator{
+		nodeID: nodeID & maxNodeID,
+	}
+}
+
+// NextID returns the next unique ID.
+func (g *IDGenerator) NextID() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	if now < g.lastMillis {
+		// The clock moved backwards; keep issuing from the last seen millisecond.
+		now = g.lastMillis
+	}
+	if now == g.lastMillis && g.issued > sequenceMask {
+		// Every sequence number has been used this millisecond; wait for the next one.
+		for now <= g.lastMillis {
+			time.Sleep(100 * time.Microsecond)
+			now = time.Now().UnixMilli()
+		}
+	}
+	if now != g.lastMillis {
+		g.lastMillis = now
+		g.issued = 0
+	}
+
+	g.sequence = (g.sequence + 1) & sequenceMask
+	g.issued++
+
+	return (now-idEpochMillis)<<timestampShift | g.nodeID<<nodeIDShift | g.sequence
+}
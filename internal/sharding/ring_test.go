@@ -0,0 +1,77 @@
+package sharding
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+const testKeys = 100000
+
+func TestGetShardStringDistributesKeysEvenly(t *testing.T) {
+	for _, numShards := range []int{2, 4, 8} {
+		t.Run(strconv.Itoa(numShards), func(t *testing.T) {
+			router := NewShardRouter(numShards)
+
+			counts := make([]int, numShards)
+			for i := 0; i < testKeys; i++ {
+				counts[router.GetShardString(fmt.Sprintf("user-%d@example.com", i))]++
+			}
+
+			mean := float64(testKeys) / float64(numShards)
+			for shard, count := range counts {
+				deviation := (float64(count) - mean) / mean
+				if deviation < -0.25 || deviation > 0.25 {
+					t.Errorf("shard %d owns %d keys, %.1f%% off the mean of %.0f", shard, count, deviation*100, mean)
+				}
+			}
+		})
+	}
+}
+
+func TestAddingShardMovesAboutOneNthOfKeys(t *testing.T) {
+	for _, numShards := range []int{2, 4, 8} {
+		t.Run(strconv.Itoa(numShards), func(t *testing.T) {
+			before := NewShardRouter(numShards)
+			after := NewShardRouter(numShards + 1)
+
+			moved := 0
+			for i := 0; i < testKeys; i++ {
+				key := fmt.Sprintf("order-%d", i)
+				from, to := before.GetShardString(key), after.GetShardString(key)
+				if from == to {
+					continue
+				}
+				moved++
+				// Existing ring points stay in place, so keys may only move to the new shard.
+				if to != numShards {
+					t.Fatalf("key %q moved from shard %d to existing shard %d", key, from, to)
+				}
+			}
+
+			// The new shard should take its fair share, 1/(N+1) of the keys, with some slack for the ring.
+			fairShare := float64(testKeys) / float64(numShards+1)
+			if float64(moved) > fairShare*1.25 {
+				t.Errorf("%d of %d keys moved, want at most about %.0f", moved, testKeys, fairShare)
+			}
+			if float64(moved) < fairShare*0.75 {
+				t.Errorf("%d of %d keys moved, want about %.0f", moved, testKeys, fairShare)
+			}
+		})
+	}
+}
+
+func TestGetShardMatchesGetShardString(t *testing.T) {
+	router := NewShardRouter(4)
+	for _, id := range []int64{0, 1, 42, 1 << 40, 9223372036854775807} {
+		if got, want := router.GetShard(id), router.GetShardString(strconv.FormatInt(id, 10)); got != want {
+			t.Errorf("GetShard(%d) = %d, want %d", id, got, want)
+		}
+	}
+}
+
+func TestGetShardStringWithoutShards(t *testing.T) {
+	if shard := NewShardRouter(0).GetShardString("key"); shard != 0 {
+		t.Errorf("GetShardString with no shards = %d, want 0", shard)
+	}
+}
@@ -1,15 +1,99 @@
 package sharding
 
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// virtualNodesPerShard is the number of points each shard owns on the hash ring.
+// More points spread keys more evenly at the cost of a larger ring.
+const virtualNodesPerShard = 160
+
+// ShardRouter maps keys to shards using a consistent-hashing ring, so changing
+// the number of shards only moves about 1/N of the keys.
 type ShardRouter struct {
-	NumShards int
+	NumShards   int
+	IDGenerator *IDGenerator
+	ring        []ringPoint
+}
+
+type ringPoint struct {
+	hash  uint64
+	shard int
 }
 
 func NewShardRouter(numShard int) *ShardRouter {
 	return &ShardRouter{
-		NumShards: numShard,
+		NumShards:   numShard,
+		IDGenerator: NewIDGenerator(0),
+		ring:        buildRing(numShard),
 	}
 }
 
+// WithNodeID sets the node ID used to generate IDs, which must be unique per running instance.
+func (sr *ShardRouter) WithNodeID(nodeID int64) *ShardRouter {
+	sr.IDGenerator = NewIDGenerator(nodeID)
+	return sr
+}
+
+// GetShard returns the shard for an integer key such as an order ID.
 func (sr *ShardRouter) GetShard(key int64) int {
-	return int(key % int64(sr.NumShards))
+	return sr.GetShardString(strconv.FormatInt(key, 10))
+}
+
+// GetShardString returns the shard for a string key such as an email or a UUID.
+// The key is owned by the first ring point clockwise from its hash.
+func (sr *ShardRouter) GetShardString(key string) int {
+	if len(sr.ring) == 0 {
+		return 0
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(sr.ring), func(i int) bool {
+		return sr.ring[i].hash >= h
+	})
+	if i == len(sr.ring) {
+		i = 0
+	}
+	return sr.ring[i].shard
+}
+
+// NextID returns a new unique ID, used to pick the shard of a row before it is inserted.
+func (sr *ShardRouter) NextID() int64 {
+	return sr.IDGenerator.NextID()
+}
+
+// buildRing places virtualNodesPerShard points per shard on the ring, sorted by hash.
+// A shard's points depend only on its index, so adding a shard leaves existing points in place.
+func buildRing(numShards int) []ringPoint {
+	ring := make([]ringPoint, 0, numShards*virtualNodesPerShard)
+	for shard := 0; shard < numShards; shard++ {
+		for vnode := 0; vnode < virtualNodesPerShard; vnode++ {
+			ring = append(ring, ringPoint{
+				hash:  hashKey("shard-" + strconv.Itoa(shard) + "-vnode-" + strconv.Itoa(vnode)),
+				shard: shard,
+			})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	return ring
+}
+
+// hashKey hashes a key with FNV-1a followed by the murmur3 finalizer, which spreads
+// similar keys such as "shard-0-vnode-1" and "shard-0-vnode-2" across the whole ring.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
 }
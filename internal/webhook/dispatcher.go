@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"order-service/config"
+	"order-service/infrastructure/buildinfo"
+	"order-service/infrastructure/log"
+	"order-service/internal/entity"
+	"order-service/internal/repository"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	defaultPollInterval = 1 * time.Second
+	defaultBatchSize    = 100
+	defaultMaxAttempts  = 10
+	defaultBaseDelay    = 1 * time.Second
+	defaultTimeout      = 5 * time.Second
+	maxBackoff          = 1 * time.Hour
+
+	// SignatureHeader carries the hex HMAC-SHA256 of the request body keyed with the subscriber's
+	// secret, prefixed with "sha256=", so subscribers can verify the request came from us.
+	SignatureHeader = "X-Signature"
+	EventTypeHeader = "X-Event-Type"
+	DeliveryHeader  = "X-Delivery-ID"
+)
+
+// Dispatcher queues order events for webhook subscribers and delivers them, retrying failed
+// deliveries with exponential backoff.
+type Dispatcher struct {
+	WebhookRepository repository.WebhookRepository
+	Subscribers       map[string]config.WebhookSubscriber // Subscribers by name
+	HTTPClient        *http.Client
+	PollInterval      time.Duration
+	BatchSize         int
+	MaxAttempts       int
+	BaseDelay         time.Duration
+}
+
+// NewDispatcher creates a webhook dispatcher for the subscribers in cfg. Zero values fall back to
+// polling every second in batches of 100, a 5s request timeout, and up to 10 attempts per delivery
+// starting 1s apart.
+func NewDispatcher(webhookRepository repository.WebhookRepository, cfg config.Webhooks) *Dispatcher {
+	pollInterval := cfg.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	baseDelay := cfg.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultBaseDelay
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	subscribers := make(map[string]config.WebhookSubscriber, len(cfg.Subscribers))
+	for _, subscriber := range cfg.Subscribers {
+		subscribers[subscriber.Name] = subscriber
+	}
+
+	return &Dispatcher{
+		WebhookRepository: webhookRepository,
+		Subscribers:       subscribers,
+		HTTPClient: &http.Client{
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(buildinfo.IdentifyRequests(http.DefaultTransport)),
+		},
+		PollInterval: pollInterval,
+		BatchSize:    batchSize,
+		MaxAttempts:  maxAttempts,
+		BaseDelay:    baseDelay,
+	}
+}
+
+// Enqueue queues the event for delivery to every subscriber of its event type.
+func (d *Dispatcher) Enqueue(ctx context.Context, event *entity.OutboxEvent) error {
+	var envelope struct {
+		EventType string `json:"event_type"`
+	}
+	err := json.Unmarshal(event.Payload, &envelope)
+	if err != nil {
+		return fmt.Errorf("failed to decode event type: %w", err)
+	}
+
+	var deliveries []entity.WebhookDelivery
+	for name, subscriber := range d.Subscribers {
+		if len(subscriber.Events) > 0 && !slices.Contains(subscriber.Events, envelope.EventType) {
+			continue
+		}
+		deliveries = append(deliveries, entity.WebhookDelivery{
+			Subscriber: name,
+			EventKey:   event.EventKey,
+			EventType:  envelope.EventType,
+			Payload:    event.Payload,
+		})
+	}
+
+	return d.WebhookRepository.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+// Start polls for due deliveries and delivers them until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain delivers due deliveries batch by batch until none are left. Each batch is claimed for
+// long enough to send every delivery in it, even if each one runs into the request timeout.
+func (d *Dispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now()
+		leaseUntil := now.Add(time.Duration(d.BatchSize) * d.HTTPClient.Timeout)
+		deliveries, err := d.WebhookRepository.ClaimDueWebhookDeliveries(ctx, now, d.BatchSize, leaseUntil)
+		if err != nil {
+			return
+		}
+
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			err := d.deliver(ctx, delivery)
+			if err != nil {
+				log.FromContext(ctx).Warn().Err(err).Int64("deliveryID", delivery.ID).Str("subscriber", delivery.Subscriber).Int("attempt", delivery.Attempts+1).Msg("Failed to deliver webhook")
+				nextAttemptAt := time.Now().Add(backoff(d.BaseDelay, delivery.Attempts))
+				_ = d.WebhookRepository.MarkWebhookAttemptFailed(ctx, delivery, err, nextAttemptAt, d.MaxAttempts)
+				continue
+			}
+
+			_ = d.WebhookRepository.MarkWebhookDelivered(ctx, delivery.ID)
+		}
+
+		if len(deliveries) < d.BatchSize {
+			return
+		}
+	}
+}
+
+// deliver POSTs the delivery's payload to its subscriber, signed with the subscriber's secret.
+// Any response other than 2xx is an error.
+func (d *Dispatcher) deliver(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	subscriber, ok := d.Subscribers[delivery.Subscriber]
+	if !ok {
+		return fmt.Errorf("unknown webhook subscriber %q", delivery.Subscriber)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscriber.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, Sign(subscriber.Secret, delivery.Payload))
+	request.Header.Set(EventTypeHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+
+	response, err := d.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("subscriber responded with status code %d", response.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the X-Signature header value for body: "sha256=" followed by the hex
+// HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay before the attempt following the given number of failed attempts,
+// doubling from baseDelay up to an hour.
+func backoff(baseDelay time.Duration, attempts int) time.Duration {
+	delay := baseDelay
+	for i := 0; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"order-service/config"
+	"order-service/infrastructure/log"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	defaultCORSHeaders = []string{echo.HeaderAuthorization, echo.HeaderContentType, "Idempotency-Key", "If-None-Match", TimeoutHeader, log.RequestIDHeader}
+)
+
+// GetCORSConfig builds the CORS configuration from the app config. Without any allowed
+// origins every cross-origin request is denied, rather than echo's default of allowing all.
+func GetCORSConfig(cfg config.CORS) middleware.CORSConfig {
+	corsConfig := middleware.CORSConfig{
+		Skipper:          middleware.DefaultSkipper,
+		AllowOrigins:     trimAll(cfg.AllowOrigins),
+		AllowMethods:     trimAll(cfg.AllowMethods),
+		AllowHeaders:     trimAll(cfg.AllowHeaders),
+		AllowCredentials: cfg.AllowCredentials,
+		ExposeHeaders:    []string{log.RequestIDHeader, "ETag"},
+	}
+
+	if len(corsConfig.AllowMethods) == 0 {
+		corsConfig.AllowMethods = defaultCORSMethods
+	}
+	if len(corsConfig.AllowHeaders) == 0 {
+		corsConfig.AllowHeaders = defaultCORSHeaders
+	}
+	if len(corsConfig.AllowOrigins) == 0 {
+		corsConfig.AllowOriginFunc = func(origin string) (bool, error) {
+			return false, nil
+		}
+	}
+
+	return corsConfig
+}
+
+// trimAll trims surrounding spaces from every value and drops empty ones, so lists written as
+// "a, b" in the environment behave like their YAML equivalent.
+func trimAll(values []string) []string {
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
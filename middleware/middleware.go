@@ -1,19 +1,90 @@
 package middleware
 
 import (
+	"order-service/config"
+	"time"
+
+	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"golang.org/x/time/rate"
-	"time"
 )
 
-func GetRateLimiter() middleware.RateLimiterConfig {
+// Route groups with their own rate limit. Each is configured under app.rateLimits.groups by name.
+const (
+	RateLimitGroupWrite = "write" // Endpoints that create or change orders and touch stock
+	RateLimitGroupRead  = "read"  // Endpoints that only read orders
+	RateLimitGroupAdmin = "admin" // Admin-only endpoints
+	RateLimitGroupOps   = "ops"   // Probes and metrics
+)
+
+const (
+	defaultRateLimit          = 1
+	defaultRateLimitBurst     = 5
+	defaultRateLimitExpiresIn = time.Minute
+)
+
+// RateLimiters holds one rate limiter middleware per route group. Every group counts requests in its
+// own store, so a client that used up its write limit can still read.
+type RateLimiters struct {
+	cfg      config.RateLimits
+	limiters map[string]echo.MiddlewareFunc
+}
+
+// NewRateLimiters creates the rate limiters of the route groups. A group without its own settings
+// is limited like the default, which falls back to 1 request per second with a burst of 5.
+func NewRateLimiters(cfg config.RateLimits) *RateLimiters {
+	return &RateLimiters{
+		cfg:      cfg,
+		limiters: make(map[string]echo.MiddlewareFunc),
+	}
+}
+
+// Group returns the rate limiter middleware of the named route group. Calling it again for the
+// same group returns the same limiter, so routes registered separately share the group's limit.
+func (r *RateLimiters) Group(name string) echo.MiddlewareFunc {
+	if limiter, ok := r.limiters[name]; ok {
+		return limiter
+	}
+
+	limit := r.cfg.Default
+	if groupLimit, ok := r.cfg.Groups[name]; ok {
+		limit = mergeRateLimit(groupLimit, limit)
+	}
+	limiter := middleware.RateLimiterWithConfig(GetRateLimiter(limit))
+	r.limiters[name] = limiter
+	return limiter
+}
+
+// GetRateLimiter builds the config of a rate limiter keyed by client IP from limit. Unset fields fall
+// back to 1 request per second, a burst of 5 and clients forgotten after a minute.
+func GetRateLimiter(limit config.RateLimit) middleware.RateLimiterConfig {
+	limit = mergeRateLimit(limit, config.RateLimit{
+		Rate:      defaultRateLimit,
+		Burst:     defaultRateLimitBurst,
+		ExpiresIn: defaultRateLimitExpiresIn,
+	})
+
 	return middleware.RateLimiterConfig{
 		Skipper: middleware.DefaultSkipper,
 		Store: middleware.NewRateLimiterMemoryStoreWithConfig(
 			middleware.RateLimiterMemoryStoreConfig{
-				Rate:      rate.Limit(1),
-				Burst:     5,
-				ExpiresIn: 1 * time.Minute,
+				Rate:      rate.Limit(limit.Rate),
+				Burst:     limit.Burst,
+				ExpiresIn: limit.ExpiresIn,
 			}),
 	}
 }
+
+// mergeRateLimit fills the unset fields of limit from fallback.
+func mergeRateLimit(limit, fallback config.RateLimit) config.RateLimit {
+	if limit.Rate <= 0 {
+		limit.Rate = fallback.Rate
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = fallback.Burst
+	}
+	if limit.ExpiresIn <= 0 {
+		limit.ExpiresIn = fallback.ExpiresIn
+	}
+	return limit
+}
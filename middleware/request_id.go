@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"order-service/infrastructure/log"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog"
+)
+
+// RequestID reads the X-Request-ID header, or generates an ID when it is missing, and stores it
+// in the request context together with a logger tagged with it. The ID is echoed back in the
+// response header so clients can quote it when reporting a problem.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requestID := c.Request().Header.Get(log.RequestIDHeader)
+			if requestID == "" {
+				requestID = uuid.NewString()
+			}
+
+			ctx := log.WithRequestID(c.Request().Context(), requestID)
+			c.SetRequest(c.Request().WithContext(ctx))
+			c.Response().Header().Set(log.RequestIDHeader, requestID)
+
+			return next(c)
+		}
+	}
+}
+
+// RequestLogger logs one structured line per request through the request-scoped logger,
+// so access logs carry the same request ID as the service and repository logs.
+// It must be registered after RequestID.
+func RequestLogger() echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogMethod:   true,
+		LogURI:      true,
+		LogStatus:   true,
+		LogLatency:  true,
+		LogRemoteIP: true,
+		LogError:    true,
+		HandleError: true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			logger := log.FromContext(c.Request().Context())
+			var event *zerolog.Event
+			if v.Error != nil {
+				event = logger.Error().Err(v.Error)
+			} else {
+				event = logger.Info()
+			}
+
+			event.
+				Str("method", v.Method).
+				Str("uri", v.URI).
+				Int("status", v.Status).
+				Dur("latency", v.Latency).
+				Str("remoteIP", v.RemoteIP).
+				Msg("Request handled")
+			return nil
+		},
+	})
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"order-service/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TimeoutHeader lets a client bound its request with a deadline in milliseconds, clamped to the configured maximum.
+const TimeoutHeader = "X-Timeout-Ms"
+
+const (
+	defaultRequestTimeout = 15 * time.Second
+	defaultMaxTimeout     = 60 * time.Second
+)
+
+// RequestTimeout returns middleware that bounds each request's context with a deadline, so every
+// context-aware call made for the request gives up once it passes. The deadline is taken from the
+// X-Timeout-Ms header when present, otherwise from the timeout configured for the route, e.g.
+// "POST /orders/batch", otherwise from the default; it never exceeds the maximum. Requests failing
+// with context.DeadlineExceeded are answered with 503.
+func RequestTimeout(cfg config.Timeouts) echo.MiddlewareFunc {
+	defaultTimeout := cfg.Default
+	if defaultTimeout <= 0 {
+		defaultTimeout = defaultRequestTimeout
+	}
+	maxTimeout := cfg.Max
+	if maxTimeout <= 0 {
+		maxTimeout = defaultMaxTimeout
+	}
+	// Viper lowercases map keys, so routes are matched case-insensitively.
+	routeTimeouts := make(map[string]time.Duration, len(cfg.Routes))
+	for route, timeout := range cfg.Routes {
+		routeTimeouts[strings.ToLower(route)] = timeout
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			timeout := defaultTimeout
+			if routeTimeout, ok := routeTimeouts[strings.ToLower(c.Request().Method+" "+c.Path())]; ok {
+				timeout = routeTimeout
+			}
+			if header := c.Request().Header.Get(TimeoutHeader); header != "" {
+				ms, err := strconv.ParseInt(header, 10, 64)
+				if err != nil || ms <= 0 {
+					return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+TimeoutHeader+" header")
+				}
+				timeout = time.Duration(ms) * time.Millisecond
+			}
+			timeout = min(timeout, maxTimeout)
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+			if err != nil && errors.Is(err, context.DeadlineExceeded) {
+				return echo.ErrServiceUnavailable.WithInternal(err)
+			}
+			return err
+		}
+	}
+}
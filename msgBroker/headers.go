@@ -0,0 +1,11 @@
+package msgBroker
+
+// Kafka message headers set on published events so consumers can correlate them with the
+// request that caused them. The W3C trace context headers are set alongside them.
+const (
+	HeaderRequestID   = "request_id"
+	HeaderTraceID     = "trace_id"
+	HeaderContentType = "content-type"
+
+	ContentTypeJSON = "application/json"
+)
@@ -0,0 +1,29 @@
+package msgBroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/config"
+)
+
+// Ping checks that at least one of the brokers accepts a connection with the configured TLS and SASL
+// settings. Readers and writers connect lazily, so this is how startup finds out Kafka is unreachable.
+func Ping(ctx context.Context, cfg config.Kafka) error {
+	dialer, err := newDialer(cfg)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, broker := range cfg.Brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("broker %s: %w", broker, err))
+			continue
+		}
+		_ = conn.Close()
+		return nil
+	}
+	return fmt.Errorf("failed to connect to Kafka: %w", errors.Join(errs...))
+}
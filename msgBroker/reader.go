@@ -0,0 +1,23 @@
+package msgBroker
+
+import (
+	"order-service/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// NewKafkaReader creates a consumer group reader for cfg.ConsumerGroup subscribed to all the given
+// topics, connecting with the TLS and SASL settings in cfg.
+func NewKafkaReader(cfg config.Kafka, topics []string) (*kafka.Reader, error) {
+	dialer, err := newDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     cfg.Brokers,
+		GroupTopics: topics,
+		GroupID:     cfg.ConsumerGroup,
+		Dialer:      dialer,
+	}), nil
+}
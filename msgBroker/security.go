@@ -0,0 +1,70 @@
+package msgBroker
+
+import (
+	"crypto/tls"
+	"fmt"
+	"order-service/config"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const dialTimeout = 10 * time.Second
+
+// newTLSConfig returns the TLS settings for broker connections, or nil when TLS is disabled.
+func newTLSConfig(cfg config.Kafka) *tls.Config {
+	if !cfg.TLS {
+		return nil
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// newSASLMechanism builds the SASL mechanism named by cfg.SASLMechanism. It returns nil when no
+// mechanism is configured, so connections are not authenticated.
+func newSASLMechanism(cfg config.Kafka) (sasl.Mechanism, error) {
+	switch strings.ToUpper(cfg.SASLMechanism) {
+	case "":
+		return nil, nil
+	case "PLAIN":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unsupported kafka SASL mechanism %q", cfg.SASLMechanism)
+	}
+}
+
+// newTransport returns the transport used by writers to reach the brokers with TLS and SASL applied.
+func newTransport(cfg config.Kafka) (*kafka.Transport, error) {
+	mechanism, err := newSASLMechanism(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		DialTimeout: dialTimeout,
+		TLS:         newTLSConfig(cfg),
+		SASL:        mechanism,
+	}, nil
+}
+
+// newDialer returns the dialer used by readers to reach the brokers with TLS and SASL applied.
+func newDialer(cfg config.Kafka) (*kafka.Dialer, error) {
+	mechanism, err := newSASLMechanism(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Dialer{
+		Timeout:       dialTimeout,
+		DualStack:     true,
+		TLS:           newTLSConfig(cfg),
+		SASLMechanism: mechanism,
+	}, nil
+}
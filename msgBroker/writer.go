@@ -1,12 +1,83 @@
 package msgBroker
 
-import "github.com/segmentio/kafka-go"
+import (
+	"fmt"
+	"order-service/config"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// batchTimeout bounds how long a write waits for more messages to batch with. The publisher
+// writes synchronously, so kafka-go's 1s default would add up to a second to every write.
+const batchTimeout = 10 * time.Millisecond
+
+const (
+	defaultMaxAttempts     = 10
+	defaultRetryBackoffMin = 100 * time.Millisecond
+	defaultRetryBackoffMax = time.Second
+)
+
+// NewKafkaWriter creates a synchronous writer that by default waits for every in-sync replica to
+// acknowledge a message, so an event is only marked published once it is durable.
+// Writes are never async, keeping events in the order the outbox publisher sends them.
+// It connects to cfg.Brokers with the TLS and SASL settings in cfg.
+//
+// Failed writes are retried with backoff up to cfg.MaxAttempts times. kafka-go does not support
+// idempotent production, so a retry after a lost acknowledgment may write an event twice;
+// consumers must deduplicate events by their ID.
+func NewKafkaWriter(cfg config.Kafka, topic string) (*kafka.Writer, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	requiredAcks, err := parseRequiredAcks(cfg.RequiredAcks)
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	retryBackoffMin := cfg.RetryBackoffMin
+	if retryBackoffMin <= 0 {
+		retryBackoffMin = defaultRetryBackoffMin
+	}
+	retryBackoffMax := cfg.RetryBackoffMax
+	if retryBackoffMax <= 0 {
+		retryBackoffMax = defaultRetryBackoffMax
+	}
+	if retryBackoffMax < retryBackoffMin {
+		retryBackoffMax = retryBackoffMin
+	}
 
-func NewKafkaWriter(brokers []string, topic string) *kafka.Writer {
 	return &kafka.Writer{
-		Addr:                   kafka.TCP(brokers...),
+		Addr:                   kafka.TCP(cfg.Brokers...),
 		Topic:                  topic,
 		Balancer:               &kafka.LeastBytes{},
+		RequiredAcks:           requiredAcks,
+		MaxAttempts:            maxAttempts,
+		WriteBackoffMin:        retryBackoffMin,
+		WriteBackoffMax:        retryBackoffMax,
+		BatchTimeout:           batchTimeout,
+		Async:                  false,
 		AllowAutoTopicCreation: true,
+		Transport:              transport,
+	}, nil
+}
+
+// parseRequiredAcks maps the configured acknowledgment level to kafka-go's, defaulting to all.
+func parseRequiredAcks(requiredAcks string) (kafka.RequiredAcks, error) {
+	switch strings.ToLower(requiredAcks) {
+	case "", "all":
+		return kafka.RequireAll, nil
+	case "one":
+		return kafka.RequireOne, nil
+	case "none":
+		return kafka.RequireNone, nil
+	default:
+		return 0, fmt.Errorf("unsupported kafka required acks %q", requiredAcks)
 	}
 }
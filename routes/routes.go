@@ -3,10 +3,51 @@ package routes
 import (
 	"github.com/labstack/echo/v4"
 	"order-service/internal/api"
+	"order-service/internal/metrics"
+	reqMiddleware "order-service/middleware"
 )
 
-func SetupRoutes(e *echo.Echo, oh api.OrderHandler) {
-	e.POST("/order", oh.CreateOrder)       // Create a new order
-	e.PUT("/order", oh.UpdateOrder)        // Update an existing order
-	e.DELETE("/order/:id", oh.CancelOrder) // Cancel an order by ID
+// SetupRoutes registers all routes. Operational endpoints are public, while order
+// endpoints are grouped behind the given JWT middleware. Every route is rate limited by its
+// route group, so the write path can be protected harder than reads.
+func SetupRoutes(e *echo.Echo, jwtMiddleware echo.MiddlewareFunc, limits *reqMiddleware.RateLimiters, oh api.OrderHandler, hh api.HealthHandler, outh api.OutboxHandler) {
+	opsLimit := limits.Group(reqMiddleware.RateLimitGroupOps)
+	e.GET("/healthz", hh.Liveness, opsLimit)       // Liveness probe
+	e.GET("/readyz", hh.Readiness, opsLimit)       // Readiness probe checking dependencies
+	e.GET("/metrics", metrics.Handler(), opsLimit) // Prometheus metrics
+
+	requireAdmin := api.RequireRole(api.RoleAdmin)
+	setActor := api.SetActor()
+	scopeTenant := api.ScopeTenant()
+	writeLimit := limits.Group(reqMiddleware.RateLimitGroupWrite)
+	readLimit := limits.Group(reqMiddleware.RateLimitGroupRead)
+	adminLimit := limits.Group(reqMiddleware.RateLimitGroupAdmin)
+
+	order := e.Group("/order", jwtMiddleware, setActor, scopeTenant)
+	order.POST("", oh.CreateOrder, writeLimit)                          // Create a new order
+	order.POST("/quote", oh.QuoteOrder, writeLimit)                     // Price an order without creating it
+	order.PUT("", oh.UpdateOrder, writeLimit)                           // Update an existing order
+	order.PATCH("/:id", oh.PatchOrder, writeLimit)                      // Partially update an order by ID
+	order.POST("/:id/pay", oh.PayOrder, writeLimit)                     // Confirm payment of an order by ID
+	order.POST("/:id/reprice", oh.RepriceOrder, writeLimit)             // Recompute an unpaid order's totals from current pricing
+	order.POST("/:id/release", oh.ReleaseOrder, writeLimit)             // Give back an unpaid order's reserved stock, keeping it as a draft
+	order.DELETE("/:id", oh.CancelOrder, writeLimit)                    // Cancel an order by ID
+	order.DELETE("/:id/soft", oh.DeleteOrder, writeLimit)               // Soft delete an order by ID, hiding it without changing its status
+	order.GET("/:id", oh.GetOrder, readLimit)                           // Get an order by ID
+	order.GET("/:id/history", oh.GetOrderHistory, readLimit)            // Get an order's status transitions
+	order.GET("/:id/receipt", oh.GetOrderReceipt, readLimit)            // Get a printable summary of an order's lines and totals
+	order.DELETE("/:id/purge", oh.PurgeOrder, adminLimit, requireAdmin) // Permanently delete an order (admin only)
+
+	orders := e.Group("/orders", jwtMiddleware, setActor, scopeTenant)
+	orders.GET("", oh.ListOrders, readLimit)                         // List orders with filtering and pagination (admins see every user's orders)
+	orders.POST("/batch", oh.CreateOrders, writeLimit, requireAdmin) // Create a batch of orders (admin only)
+
+	pricing := e.Group("/pricing", jwtMiddleware, setActor, scopeTenant, requireAdmin, adminLimit)
+	pricing.GET("/:productId", oh.GetPricing) // Inspect a product's effective pricing and cache state (admin only)
+
+	admin := e.Group("/admin", jwtMiddleware, setActor, scopeTenant, requireAdmin, adminLimit)
+	admin.GET("/orders", oh.ListAllOrders)              // Browse every user's orders with cursor pagination
+	admin.POST("/orders/cancel", oh.CancelOrders)       // Cancel every order matching a filter, e.g. to abort a sale
+	admin.GET("/outbox/failed", outh.CountFailedOutbox) // Count outbox events that gave up publishing
+	admin.POST("/outbox/redrive", outh.RedriveOutbox)   // Requeue failed outbox events and wake the publishers
 }